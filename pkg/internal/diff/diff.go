@@ -0,0 +1,393 @@
+// Package diff computes differences between texts with the Myers algorithm
+// and formats them as unified diffs.
+package diff
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	defaultContext = 3
+	// sniffLen is the number of bytes checked by [IsBinary], as in git.
+	sniffLen = 8000
+)
+
+// Op is the operation of an [Edit].
+type Op int
+
+const (
+	// Equal means that the element is in both sequences.
+	Equal Op = iota
+	// Delete means that the element is only in the old sequence.
+	Delete
+	// Insert means that the element is only in the new sequence.
+	Insert
+)
+
+// Edit is an element of the edit script returned by [Diff].
+type Edit struct {
+	Op   Op
+	Text string
+}
+
+// Diff returns the shortest edit script turning a into b, found with the Myers
+// algorithm in linear space. Deletions come before insertions in every changed
+// region.
+func Diff(a, b []string) []Edit {
+	return reorder(diff(make([]Edit, 0, len(a)+len(b)), a, b))
+}
+
+// diff appends the edit script turning a into b to edits. The common prefix
+// and suffix are trimmed, and the rest is split at the middle of the shortest
+// path, so that only the furthest reaching paths of the current step are kept.
+func diff(edits []Edit, a, b []string) []Edit {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	edits = appendEdits(edits, Equal, a[:prefix])
+	ma, mb := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	if x, y, ok := middle(ma, mb); ok {
+		edits = diff(edits, ma[:x], mb[:y])
+		edits = diff(edits, ma[x:], mb[y:])
+	} else {
+		edits = appendEdits(edits, Delete, ma)
+		edits = appendEdits(edits, Insert, mb)
+	}
+	return appendEdits(edits, Equal, a[len(a)-suffix:])
+}
+
+func appendEdits(edits []Edit, op Op, texts []string) []Edit {
+	for _, s := range texts {
+		edits = append(edits, Edit{Op: op, Text: s})
+	}
+	return edits
+}
+
+// middle finds a point of the shortest path from the start to the end of a
+// and b, running the Myers algorithm forward from the start and backward from
+// the end until the paths overlap. It returns false if a or b is empty or they
+// have nothing in common, so that the script is all deletions and insertions.
+func middle(a, b []string) (x, y int, ok bool) {
+	n, m := len(a), len(b)
+	if n == 0 || m == 0 {
+		return 0, 0, false
+	}
+
+	maxD := (n + m + 1) / 2
+	offset := maxD
+	// forward[offset+k] is the furthest x reached on the diagonal k = x - y
+	// from the start, backward is the same from the end.
+	forward, backward := make([]int, 2*maxD+2), make([]int, 2*maxD+2)
+	for i := range forward {
+		forward[i], backward[i] = -1, -1
+	}
+	forward[offset+1], backward[offset+1] = 0, 0
+
+	delta := n - m
+	// If delta is odd, the paths overlap on a forward step, otherwise on
+	// a backward one.
+	odd := delta%2 != 0
+	// The diagonals running off the edges are skipped.
+	var fStart, fEnd, bStart, bEnd int
+	for d := range maxD {
+		for k := -d + fStart; k <= d-fEnd; k += 2 {
+			i := offset + k
+			var fx int
+			if k == -d || k != d && forward[i-1] < forward[i+1] {
+				fx = forward[i+1]
+			} else {
+				fx = forward[i-1] + 1
+			}
+			fy := fx - k
+			for fx < n && fy < m && a[fx] == b[fy] {
+				fx, fy = fx+1, fy+1
+			}
+			forward[i] = fx
+
+			switch j := offset + delta - k; {
+			case fx > n:
+				fEnd += 2
+			case fy > m:
+				fStart += 2
+			case odd && j >= 0 && j < len(backward) && backward[j] != -1 && fx >= n-backward[j]:
+				return fx, fy, true
+			}
+		}
+
+		for k := -d + bStart; k <= d-bEnd; k += 2 {
+			i := offset + k
+			var bx int
+			if k == -d || k != d && backward[i-1] < backward[i+1] {
+				bx = backward[i+1]
+			} else {
+				bx = backward[i-1] + 1
+			}
+			by := bx - k
+			for bx < n && by < m && a[n-bx-1] == b[m-by-1] {
+				bx, by = bx+1, by+1
+			}
+			backward[i] = bx
+
+			switch j := offset + delta - k; {
+			case bx > n:
+				bEnd += 2
+			case by > m:
+				bStart += 2
+			case !odd && j >= 0 && j < len(forward) && forward[j] != -1 && forward[j] >= n-bx:
+				fx := forward[j]
+				return fx, fx - (delta - k), true
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// reorder moves deletions before insertions within every changed region.
+func reorder(edits []Edit) []Edit {
+	for start := 0; start < len(edits); {
+		if edits[start].Op == Equal {
+			start++
+			continue
+		}
+		end := start
+		for end < len(edits) && edits[end].Op != Equal {
+			end++
+		}
+		region := edits[start:end]
+		var deletes, inserts []Edit
+		for _, e := range region {
+			if e.Op == Delete {
+				deletes = append(deletes, e)
+			} else {
+				inserts = append(inserts, e)
+			}
+		}
+		copy(region, append(deletes, inserts...))
+		start = end
+	}
+	return edits
+}
+
+// SplitLines splits data into lines keeping the line endings.
+func SplitLines(data []byte) []string {
+	var lines []string
+	for len(data) > 0 {
+		i := bytes.IndexByte(data, '\n') + 1
+		if i == 0 {
+			i = len(data)
+		}
+		lines = append(lines, string(data[:i]))
+		data = data[i:]
+	}
+	return lines
+}
+
+// SplitWords splits s into words and runs of whitespace, including Unicode
+// whitespace.
+func SplitWords(s string) []string {
+	var words []string
+	start := 0
+	for i, r := range s {
+		first, _ := utf8.DecodeRuneInString(s[start:])
+		if i > start && unicode.IsSpace(r) != unicode.IsSpace(first) {
+			words = append(words, s[start:i])
+			start = i
+		}
+	}
+	if start < len(s) {
+		words = append(words, s[start:])
+	}
+	return words
+}
+
+// IsBinary reports whether data looks like binary content, using the same
+// heuristic as git: the presence of a NUL byte in the first 8000 bytes.
+func IsBinary(data []byte) bool {
+	return bytes.IndexByte(data[:min(len(data), sniffLen)], 0) >= 0
+}
+
+// Option is an option for [Unified].
+type Option func(*config)
+
+type config struct {
+	context int
+	words   bool
+}
+
+// WithContext sets the number of unchanged lines shown around the changes,
+// the default is 3.
+func WithContext(n int) Option {
+	return func(c *config) {
+		c.context = n
+	}
+}
+
+// WithWords shows the changed lines of every hunk merged, with the changed
+// words marked as [-deleted-]{+inserted+}, like git diff --word-diff=plain.
+func WithWords() Option {
+	return func(c *config) {
+		c.words = true
+	}
+}
+
+// Unified returns the unified diff of the old and the new content with the
+// "---" and "+++" headers, or an empty string if they are equal. If either
+// content is binary, see [IsBinary], only "Binary files OLD and NEW differ"
+// is returned.
+func Unified(oldName, newName string, oldData, newData []byte, options ...Option) string {
+	if bytes.Equal(oldData, newData) {
+		return ""
+	}
+	if IsBinary(oldData) || IsBinary(newData) {
+		return fmt.Sprintf("Binary files %s and %s differ\n", oldName, newName)
+	}
+
+	cfg := config{context: defaultContext}
+	for _, o := range options {
+		o(&cfg)
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", oldName, newName)
+	edits := Diff(SplitLines(oldData), SplitLines(newData))
+	for _, h := range hunks(edits, cfg.context) {
+		h.write(&sb, edits, cfg.words)
+	}
+	return sb.String()
+}
+
+// Words returns the text with the changed words of b against a marked as
+// [-deleted-]{+inserted+}.
+func Words(a, b string) string {
+	var sb strings.Builder
+	edits := Diff(SplitWords(a), SplitWords(b))
+	for i, e := range edits {
+		switch e.Op {
+		case Equal:
+			sb.WriteString(e.Text)
+		case Delete:
+			if i == 0 || edits[i-1].Op != Delete {
+				sb.WriteString("[-")
+			}
+			sb.WriteString(e.Text)
+			if i == len(edits)-1 || edits[i+1].Op != Delete {
+				sb.WriteString("-]")
+			}
+		case Insert:
+			if i == 0 || edits[i-1].Op != Insert {
+				sb.WriteString("{+")
+			}
+			sb.WriteString(e.Text)
+			if i == len(edits)-1 || edits[i+1].Op != Insert {
+				sb.WriteString("+}")
+			}
+		}
+	}
+	return sb.String()
+}
+
+// hunk is a range of edits with the line numbers of its start.
+type hunk struct {
+	start, end       int
+	oldLine, newLine int
+}
+
+// hunks groups the changes separated by at most 2*context equal lines.
+func hunks(edits []Edit, context int) []hunk {
+	var result []hunk
+	oldLine, newLine := 0, 0
+	for i := 0; i < len(edits); {
+		if edits[i].Op == Equal {
+			oldLine, newLine = oldLine+1, newLine+1
+			i++
+			continue
+		}
+
+		back := min(context, i)
+		h := hunk{start: i - back, oldLine: oldLine - back, newLine: newLine - back}
+		end, equal := i, 0
+		for ; end < len(edits) && equal <= 2*context; end++ {
+			if edits[end].Op == Equal {
+				equal++
+			} else {
+				equal = 0
+			}
+		}
+		// The trailing equal lines beyond the context are not in the hunk.
+		h.end = end - max(equal-context, 0)
+
+		for _, e := range edits[i:h.end] {
+			if e.Op != Insert {
+				oldLine++
+			}
+			if e.Op != Delete {
+				newLine++
+			}
+		}
+		result = append(result, h)
+		i = h.end
+	}
+	return result
+}
+
+func (h hunk) write(sb *strings.Builder, edits []Edit, words bool) {
+	var oldCount, newCount int
+	for _, e := range edits[h.start:h.end] {
+		if e.Op != Insert {
+			oldCount++
+		}
+		if e.Op != Delete {
+			newCount++
+		}
+	}
+	fmt.Fprintf(sb, "@@ -%s +%s @@\n", hunkRange(h.oldLine, oldCount), hunkRange(h.newLine, newCount))
+
+	for i := h.start; i < h.end; {
+		e := edits[i]
+		if e.Op == Equal || !words {
+			writeLine(sb, map[Op]string{Equal: " ", Delete: "-", Insert: "+"}[e.Op], e.Text)
+			i++
+			continue
+		}
+
+		var oldText, newText strings.Builder
+		for ; i < h.end && edits[i].Op != Equal; i++ {
+			if edits[i].Op == Delete {
+				oldText.WriteString(edits[i].Text)
+			} else {
+				newText.WriteString(edits[i].Text)
+			}
+		}
+		writeLine(sb, "", Words(oldText.String(), newText.String()))
+	}
+}
+
+// hunkRange formats the range of a hunk, the start of an empty range is the
+// line before it.
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprint(start + 1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+func writeLine(sb *strings.Builder, prefix, line string) {
+	sb.WriteString(prefix)
+	sb.WriteString(line)
+	if !strings.HasSuffix(line, "\n") {
+		sb.WriteString("\n\\ No newline at end of file\n")
+	}
+}
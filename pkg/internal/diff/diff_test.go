@@ -0,0 +1,260 @@
+package diff_test
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go.mws.cloud/util-toolset/pkg/internal/diff"
+)
+
+func TestDiff(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{name: "Equal", a: "abc", b: "abc", want: "=a=b=c"},
+		{name: "Empty", a: "", b: "", want: ""},
+		{name: "Insert", a: "", b: "ab", want: "+a+b"},
+		{name: "Delete", a: "ab", b: "", want: "-a-b"},
+		{name: "Replace", a: "abc", b: "axc", want: "=a-b+x=c"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var sb strings.Builder
+			for _, e := range diff.Diff(strings.Split(tc.a, ""), strings.Split(tc.b, "")) {
+				sb.WriteString(map[diff.Op]string{diff.Equal: "=", diff.Delete: "-", diff.Insert: "+"}[e.Op] + e.Text)
+			}
+			require.Equal(t, tc.want, sb.String())
+		})
+	}
+}
+
+// requireScript checks that the edits turn a into b and returns the number of
+// changes.
+func requireScript(t *testing.T, a, b []string, edits []diff.Edit) int {
+	t.Helper()
+
+	var oldSide, newSide []string
+	changes := 0
+	for _, e := range edits {
+		if e.Op != diff.Insert {
+			oldSide = append(oldSide, e.Text)
+		}
+		if e.Op != diff.Delete {
+			newSide = append(newSide, e.Text)
+		}
+		if e.Op != diff.Equal {
+			changes++
+		}
+	}
+	require.Equal(t, a, oldSide)
+	require.Equal(t, b, newSide)
+	return changes
+}
+
+// lcsChanges returns the number of changes of the shortest edit script,
+// computed through the longest common subsequence.
+func lcsChanges(a, b []string) int {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	return len(a) + len(b) - 2*lcs[0][0]
+}
+
+func TestDiffShortest(t *testing.T) {
+	a, b := strings.Split("abcabba", ""), strings.Split("cbabac", "")
+	require.Equal(t, 5, requireScript(t, a, b, diff.Diff(a, b)))
+
+	r := rand.New(rand.NewPCG(1, 2))
+	randomText := func() []string {
+		var text []string
+		for range r.IntN(20) {
+			text = append(text, string(rune('a'+r.IntN(3))))
+		}
+		return text
+	}
+	for range 1000 {
+		a, b := randomText(), randomText()
+		require.Equal(t, lcsChanges(a, b), requireScript(t, a, b, diff.Diff(a, b)), "%q -> %q", a, b)
+	}
+}
+
+func TestDiffLarge(t *testing.T) {
+	const n = 3000
+	a, b := make([]string, n), make([]string, n)
+	for i := range n {
+		a[i], b[i] = fmt.Sprintf("a%d\n", i), fmt.Sprintf("b%d\n", i)
+	}
+	b[n/2] = a[n/2]
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	edits := diff.Diff(a, b)
+	runtime.ReadMemStats(&after)
+
+	require.Equal(t, 2*n-2, requireScript(t, a, b, edits))
+	// The memory is linear in the size of the input.
+	require.Less(t, after.TotalAlloc-before.TotalAlloc, uint64(16<<20))
+}
+
+func numberedLines(from, to int, replace map[int]string) []byte {
+	var sb strings.Builder
+	for i := from; i <= to; i++ {
+		if s, ok := replace[i]; ok {
+			sb.WriteString(s)
+			continue
+		}
+		fmt.Fprintf(&sb, "%d\n", i)
+	}
+	return []byte(sb.String())
+}
+
+func TestUnified(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		old, new []byte
+		options  []diff.Option
+		want     string
+	}{
+		{
+			name: "Equal",
+			old:  []byte("a\n"),
+			new:  []byte("a\n"),
+			want: "",
+		},
+		{
+			name: "Hunks",
+			old:  numberedLines(1, 20, nil),
+			new:  numberedLines(1, 20, map[int]string{2: "two\n", 18: ""}),
+			want: `--- old
++++ new
+@@ -1,5 +1,5 @@
+ 1
+-2
++two
+ 3
+ 4
+ 5
+@@ -15,6 +15,5 @@
+ 15
+ 16
+ 17
+-18
+ 19
+ 20
+`,
+		},
+		{
+			name: "MergedHunks",
+			old:  numberedLines(1, 10, nil),
+			new:  numberedLines(1, 10, map[int]string{3: "three\n", 8: "eight\n"}),
+			want: `--- old
++++ new
+@@ -1,10 +1,10 @@
+ 1
+ 2
+-3
++three
+ 4
+ 5
+ 6
+ 7
+-8
++eight
+ 9
+ 10
+`,
+		},
+		{
+			name:    "Context",
+			old:     numberedLines(1, 5, nil),
+			new:     numberedLines(1, 5, map[int]string{3: ""}),
+			options: []diff.Option{diff.WithContext(1)},
+			want: `--- old
++++ new
+@@ -2,3 +2,2 @@
+ 2
+-3
+ 4
+`,
+		},
+		{
+			name:    "InsertWithoutContext",
+			old:     []byte("a\nc\n"),
+			new:     []byte("a\nb\nc\n"),
+			options: []diff.Option{diff.WithContext(0)},
+			want: `--- old
++++ new
+@@ -1,0 +2 @@
++b
+`,
+		},
+		{
+			name: "NoNewline",
+			old:  []byte("a\nb"),
+			new:  []byte("a\nb\n"),
+			want: `--- old
++++ new
+@@ -1,2 +1,2 @@
+ a
+-b
+\ No newline at end of file
++b
+`,
+		},
+		{
+			name:    "Words",
+			old:     []byte("keep\nthe quick brown fox\n"),
+			new:     []byte("keep\nthe slow brown dog\n"),
+			options: []diff.Option{diff.WithWords()},
+			want: `--- old
++++ new
+@@ -1,2 +1,2 @@
+ keep
+the [-quick-]{+slow+} brown [-fox-]{+dog+}
+`,
+		},
+		{
+			name: "Binary",
+			old:  []byte("a\x00b"),
+			new:  []byte("a\x00c"),
+			want: "Binary files old and new differ\n",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.want, diff.Unified("old", "new", tc.old, tc.new, tc.options...))
+		})
+	}
+}
+
+func TestSplitWords(t *testing.T) {
+	require.Equal(t, []string{"foo", "\u3000", "bar", " ", "baz"}, diff.SplitWords("foo\u3000bar baz"))
+	require.Equal(t, []string{"\u3000 ", "x"}, diff.SplitWords("\u3000 x"))
+	require.Empty(t, diff.SplitWords(""))
+}
+
+func TestWords(t *testing.T) {
+	require.Equal(t, "a [-b c-]{+d+} e", diff.Words("a b c e", "a d e"))
+	require.Equal(t, "{+new +}text", diff.Words("text", "new text"))
+}
+
+func TestIsBinary(t *testing.T) {
+	require.False(t, diff.IsBinary([]byte("text\n")))
+	require.True(t, diff.IsBinary([]byte("a\x00")))
+	require.False(t, diff.IsBinary(append([]byte(strings.Repeat("a", 8000)), 0)))
+}
@@ -0,0 +1,227 @@
+package fs
+
+import (
+	"cmp"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ArchiveWriter is a [WriteOnlyFS] writing files into an archive.
+// Close must be called to finish the archive, it does not close
+// the underlying [io.Writer].
+type ArchiveWriter interface {
+	WriteOnlyFS
+	io.Closer
+}
+
+// ArchiveOption is an option for archive writers, e.g. [NewZipWriter].
+type ArchiveOption func(*archiveConfig)
+
+// defaultArchiveModTime is the earliest time representable in zip archives.
+var defaultArchiveModTime = time.Date(1980, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+type archiveConfig struct {
+	modTime time.Time
+	mode    func(perm fs.FileMode, isDir bool) fs.FileMode
+	stream  bool
+	gzip    bool
+}
+
+// WithArchiveModTime sets the modification time of all the archive entries,
+// the default is 1980-01-01 00:00:00 UTC. The time is truncated to seconds.
+func WithArchiveModTime(t time.Time) ArchiveOption {
+	return func(c *archiveConfig) {
+		c.modTime = t
+	}
+}
+
+// WithArchiveStreaming makes the writer stream the entries in the order they
+// are written, instead of keeping them in memory and writing them sorted by
+// path on Close. It saves memory, but the archive is reproducible only if
+// the files are written in the same order, e.g. not concurrently.
+func WithArchiveStreaming() ArchiveOption {
+	return func(c *archiveConfig) {
+		c.stream = true
+	}
+}
+
+// WithArchiveGzip compresses the tar archive with gzip, see [NewTarWriter].
+func WithArchiveGzip() ArchiveOption {
+	return func(c *archiveConfig) {
+		c.gzip = true
+	}
+}
+
+// archiveEntry is a file or a directory (if data is nil) of an archive.
+type archiveEntry struct {
+	name    string
+	data    []byte
+	perm    fs.FileMode
+	modTime time.Time
+}
+
+// archiveFormat writes entries in a specific archive format.
+type archiveFormat interface {
+	writeEntry(e archiveEntry) error
+	close() error
+}
+
+// archiveWriter implements [ArchiveWriter] on top of [archiveFormat].
+// Data written to an archive cannot be changed, so every path can be
+// written only once, while Rename, Remove and RemoveAll are not supported.
+type archiveWriter struct {
+	mu      sync.Mutex
+	format  archiveFormat
+	cfg     archiveConfig
+	written map[string]struct{}
+	pending []archiveEntry
+	closed  bool
+}
+
+var _ ArchiveWriter = (*archiveWriter)(nil)
+
+func newArchiveWriter(format archiveFormat, options []ArchiveOption) *archiveWriter {
+	cfg := archiveConfig{
+		modTime: defaultArchiveModTime,
+		mode: func(perm fs.FileMode, _ bool) fs.FileMode {
+			return perm.Perm()
+		},
+	}
+	for _, o := range options {
+		o(&cfg)
+	}
+	cfg.modTime = cfg.modTime.Truncate(time.Second).UTC()
+
+	return &archiveWriter{format: format, cfg: cfg, written: map[string]struct{}{}}
+}
+
+func (a *archiveWriter) OpenFile(name string, flag int, perm fs.FileMode) (WritableFile, error) {
+	if flag&os.O_CREATE == 0 || flag&(os.O_WRONLY|os.O_RDWR) == 0 {
+		return nil, &fs.PathError{Op: "openfile", Path: name, Err: errors.ErrUnsupported}
+	}
+
+	p := cleanRelPath(name)
+	if err := a.reserve("openfile", p); err != nil {
+		return nil, err
+	}
+
+	return &bufferFile{name: name, onClose: func(data []byte) error {
+		// Copy the data, as the buffer may be reused after Close.
+		return a.write(archiveEntry{name: p, data: append([]byte{}, data...), perm: perm})
+	}}, nil
+}
+
+// MkdirAll adds entries for the directory and all its parents which were not
+// added before.
+func (a *archiveWriter) MkdirAll(name string, perm fs.FileMode) error {
+	p := cleanRelPath(name)
+	if p == "." {
+		return nil
+	}
+
+	var dirs []string
+	for dir := p; dir != "."; dir = path.Dir(dir) {
+		dirs = append(dirs, dir)
+	}
+
+	for _, dir := range slices.Backward(dirs) {
+		if err := a.reserve("mkdir", dir); err != nil {
+			if errors.Is(err, fs.ErrExist) {
+				continue
+			}
+			return err
+		}
+		if err := a.write(archiveEntry{name: dir, perm: perm}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *archiveWriter) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	p := cleanRelPath(name)
+	if err := a.reserve("write_file", p); err != nil {
+		return err
+	}
+	if data == nil {
+		data = []byte{}
+	}
+	return a.write(archiveEntry{name: p, data: data, perm: perm})
+}
+
+func (*archiveWriter) Rename(src, dst string) error {
+	return &os.LinkError{Op: "rename", Old: src, New: dst, Err: errors.ErrUnsupported}
+}
+
+func (*archiveWriter) Remove(name string) error {
+	return &fs.PathError{Op: "remove", Path: name, Err: errors.ErrUnsupported}
+}
+
+func (*archiveWriter) RemoveAll(path string) error {
+	return &fs.PathError{Op: "remove_all", Path: path, Err: errors.ErrUnsupported}
+}
+
+func (a *archiveWriter) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.closed {
+		return fs.ErrClosed
+	}
+	a.closed = true
+
+	slices.SortFunc(a.pending, func(l, r archiveEntry) int {
+		return cmp.Compare(l.name, r.name)
+	})
+	for _, e := range a.pending {
+		if err := a.format.writeEntry(e); err != nil {
+			return err
+		}
+	}
+	a.pending = nil
+
+	return a.format.close()
+}
+
+// reserve registers the path, so it cannot be written twice.
+func (a *archiveWriter) reserve(op, p string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, ok := a.written[p]; ok {
+		return &fs.PathError{Op: op, Path: p, Err: fs.ErrExist}
+	}
+	a.written[p] = struct{}{}
+	return nil
+}
+
+func (a *archiveWriter) write(e archiveEntry) error {
+	e.perm = a.cfg.mode(e.perm, e.data == nil)
+	e.modTime = a.cfg.modTime
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	switch {
+	case a.closed:
+		return &fs.PathError{Op: "write", Path: e.name, Err: fs.ErrClosed}
+	case a.cfg.stream:
+		return a.format.writeEntry(e)
+	default:
+		a.pending = append(a.pending, e)
+		return nil
+	}
+}
+
+// archiveDirName returns the name of a directory entry as stored in zip
+// and tar archives.
+func archiveDirName(name string) string {
+	return strings.TrimSuffix(name, "/") + "/"
+}
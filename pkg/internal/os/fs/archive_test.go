@@ -0,0 +1,97 @@
+package fs_test
+
+import (
+	"bytes"
+	iofs "io/fs"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"go.mws.cloud/util-toolset/pkg/internal/os/fs"
+	"go.mws.cloud/util-toolset/pkg/internal/testing/fstest"
+)
+
+func writeArchive(t *testing.T, w fs.ArchiveWriter, reversed bool) {
+	t.Helper()
+
+	steps := []func(){
+		func() { require.NoError(t, w.MkdirAll("dir/empty", os.ModePerm)) },
+		func() { require.NoError(t, w.WriteFile("a.txt", []byte("a"), 0o644)) },
+		func() {
+			f, err := w.OpenFile("dir/b.sh", os.O_CREATE|os.O_WRONLY, 0o755)
+			require.NoError(t, err)
+			_, err = f.WriteString("b")
+			require.NoError(t, err)
+			require.NoError(t, f.Close())
+		},
+	}
+	if reversed {
+		for i := len(steps) - 1; i >= 0; i-- {
+			steps[i]()
+		}
+	} else {
+		for _, step := range steps {
+			step()
+		}
+	}
+
+	require.ErrorIs(t, w.WriteFile("a.txt", nil, 0o644), iofs.ErrExist)
+	require.NoError(t, w.Close())
+}
+
+func expectedArchiveFS(t *testing.T) fs.FS {
+	t.Helper()
+
+	f := fs.NewFS(fs.NewMapFS(), fs.WithDirCreate(os.ModePerm))
+	require.NoError(t, f.MkdirAll("dir/empty", os.ModePerm))
+	require.NoError(t, f.WriteFile("a.txt", []byte("a"), 0o644))
+	require.NoError(t, f.WriteFile("dir/b.sh", []byte("b"), 0o755))
+	return f
+}
+
+func TestZip(t *testing.T) {
+	var b, reversed, streamed bytes.Buffer
+	writeArchive(t, fs.NewZipWriter(&b), false)
+	writeArchive(t, fs.NewZipWriter(&reversed), true)
+	require.Equal(t, b.Bytes(), reversed.Bytes())
+	writeArchive(t, fs.NewZipWriter(&streamed, fs.WithArchiveStreaming()), true)
+	require.NotEqual(t, b.Bytes(), streamed.Bytes())
+
+	zipFS, err := fs.NewZipFS(bytes.NewReader(b.Bytes()), int64(b.Len()))
+	require.NoError(t, err)
+	fstest.CompareDirs(t, expectedArchiveFS(t), zipFS, ".", ".")
+
+	info, err := iofs.Stat(zipFS, "dir/b.sh")
+	require.NoError(t, err)
+	require.Equal(t, iofs.FileMode(0o755), info.Mode())
+	require.Equal(t, time.Date(1980, time.January, 1, 0, 0, 0, 0, time.UTC), info.ModTime().UTC())
+
+	require.ErrorIs(t, zipFS.WriteFile("a.txt", nil, 0o644), fs.ErrReadOnly)
+}
+
+func TestTar(t *testing.T) {
+	modTime := time.Date(2024, time.May, 1, 12, 0, 0, 0, time.UTC)
+
+	for _, gzip := range []bool{false, true} {
+		options := []fs.ArchiveOption{fs.WithArchiveModTime(modTime)}
+		if gzip {
+			options = append(options, fs.WithArchiveGzip())
+		}
+
+		var first, second bytes.Buffer
+		writeArchive(t, fs.NewTarWriter(&first, options...), false)
+		writeArchive(t, fs.NewTarWriter(&second, options...), false)
+		require.Equal(t, first.Bytes(), second.Bytes())
+
+		tarFS, err := fs.NewTarFS(&first)
+		require.NoError(t, err)
+		fstest.CompareDirs(t, expectedArchiveFS(t), tarFS, ".", ".")
+
+		info, err := iofs.Stat(tarFS, "dir/b.sh")
+		require.NoError(t, err)
+		require.Equal(t, iofs.FileMode(0o755), info.Mode())
+		require.Equal(t, modTime, info.ModTime().UTC())
+	}
+}
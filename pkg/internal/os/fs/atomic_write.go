@@ -1,14 +1,28 @@
 package fs
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"io/fs"
+	"os"
 	"path"
+	"sync"
 )
 
 type atomicWrite struct {
-	FS
+	wrapped
 	dir string
+
+	mu       sync.Mutex
+	watchers map[*atomicWatcher]struct{}
+}
+
+// atomicWatcher counts the temporary files written while it watches, so that
+// only their events are dropped. A file is counted until its Rename or Remove
+// is reported.
+type atomicWatcher struct {
+	temps map[string]int
 }
 
 // WithAtomicWrite is an option for [NewFS] that wraps the [FS] so that WriteFile
@@ -17,7 +31,7 @@ type atomicWrite struct {
 func WithAtomicWrite() Option {
 	return func(fs FS) FS {
 		return &atomicWrite{
-			FS: fs,
+			wrapped: wrapped{fs},
 		}
 	}
 }
@@ -32,8 +46,8 @@ func WithAtomicWrite() Option {
 func WithAtomicWriteCustomDir(dir string) Option {
 	return func(fs FS) FS {
 		return &atomicWrite{
-			FS:  fs,
-			dir: dir,
+			wrapped: wrapped{fs},
+			dir:     dir,
 		}
 	}
 }
@@ -42,6 +56,8 @@ func (a *atomicWrite) WriteFile(name string, data []byte, perm fs.FileMode) erro
 	tmpName := name + ".tmp"
 	if a.dir != "" {
 		tmpName = path.Join(a.dir, tmpName)
+	} else {
+		a.track(tmpName)
 	}
 	if err := a.FS.WriteFile(tmpName, data, perm); err != nil {
 		return errors.Join(err, a.Remove(tmpName))
@@ -54,3 +70,133 @@ func (a *atomicWrite) WriteFile(name string, data []byte, perm fs.FileMode) erro
 
 	return nil
 }
+
+// PendingFile is a file written by [WithAtomicWrite] to a temporary file,
+// whose name is given once it is complete, e.g. a blob named by the hash of
+// its content. It is created by [CreatePending].
+type PendingFile struct {
+	WritableFile
+	a    *atomicWrite
+	tmp  string
+	done bool
+}
+
+// CreatePending creates [PendingFile] in dir of f, which must be wrapped with
+// [WithAtomicWrite] as the outermost option, otherwise it fails with
+// [errors.ErrUnsupported]. The temporary file is named PENDING.RANDOM.tmp,
+// and it is in the custom directory of [WithAtomicWriteCustomDir] if set.
+func CreatePending(f FS, dir string, perm fs.FileMode) (*PendingFile, error) {
+	a, ok := baseLayer(f).(*atomicWrite)
+	if !ok {
+		return nil, &fs.PathError{Op: "create", Path: dir, Err: errors.ErrUnsupported}
+	}
+
+	tmpName := tempName(path.Join(dir, "pending"))
+	if a.dir != "" {
+		tmpName = path.Join(a.dir, path.Base(tmpName))
+	} else {
+		a.track(tmpName)
+	}
+	w, err := a.FS.OpenFile(tmpName, os.O_CREATE|os.O_EXCL|os.O_WRONLY, perm)
+	if err != nil {
+		return nil, err
+	}
+	return &PendingFile{WritableFile: w, a: a, tmp: tmpName}, nil
+}
+
+// Commit closes the file and moves it to name, replacing an existing file.
+// The temporary file is removed if it fails.
+func (p *PendingFile) Commit(name string) error {
+	if p.done {
+		return &fs.PathError{Op: "commit", Path: name, Err: fs.ErrClosed}
+	}
+	p.done = true
+
+	err := p.WritableFile.Close()
+	if err == nil {
+		err = p.a.Rename(p.tmp, name)
+	}
+	if err != nil {
+		return errors.Join(err, p.a.Remove(p.tmp))
+	}
+	return nil
+}
+
+// Close closes and removes the file, unless it is committed.
+func (p *PendingFile) Close() error {
+	if p.done {
+		return nil
+	}
+	p.done = true
+
+	return errors.Join(p.WritableFile.Close(), p.a.Remove(p.tmp))
+}
+
+// Watch does not report the temporary files, so a written file is reported
+// as created by Rename. Other files named NAME.tmp are reported.
+func (a *atomicWrite) Watch(ctx context.Context, name string, recursive bool) (<-chan WatchEvent, error) {
+	w := &atomicWatcher{temps: map[string]int{}}
+	a.mu.Lock()
+	if a.watchers == nil {
+		a.watchers = map[*atomicWatcher]struct{}{}
+	}
+	a.watchers[w] = struct{}{}
+	a.mu.Unlock()
+
+	unwatch := func() {
+		a.mu.Lock()
+		delete(a.watchers, w)
+		a.mu.Unlock()
+	}
+	events, err := watchFS(ctx, a.FS, name, recursive)
+	if err != nil {
+		unwatch()
+		return nil, err
+	}
+	context.AfterFunc(ctx, unwatch)
+
+	return mapWatchEvents(ctx, events, func(e *WatchEvent) bool {
+		if a.dir != "" {
+			_, ok := relWatchPath(a.dir, e.Path)
+			return !ok
+		}
+		return !a.temporary(w, e)
+	}), nil
+}
+
+// track counts the temporary file for the active watchers.
+func (a *atomicWrite) track(tmpName string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for w := range a.watchers {
+		w.temps[cleanRelPath(tmpName)]++
+	}
+}
+
+// temporary reports whether the event is of a temporary file counted by w.
+func (a *atomicWrite) temporary(w *atomicWatcher, e *WatchEvent) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	p := cleanRelPath(e.Path)
+	n := w.temps[p]
+	if n == 0 {
+		return false
+	}
+	if e.Op&(WatchRename|WatchRemove) != 0 {
+		if n == 1 {
+			delete(w.temps, p)
+		} else {
+			w.temps[p] = n - 1
+		}
+	}
+	return true
+}
+
+func (a *atomicWrite) String() string {
+	if a.dir != "" {
+		return fmt.Sprintf("WithAtomicWriteCustomDir(%q)", a.dir)
+	}
+	return "WithAtomicWrite()"
+}
@@ -1,7 +1,9 @@
 package fs_test
 
 import (
+	"errors"
 	"io"
+	iofs "io/fs"
 	"os"
 	"testing"
 
@@ -78,3 +80,41 @@ func (s *atomicWriteTestSuite) TestMapFS() {
 	_, err = f.Open("test.txt.tmp")
 	s.ErrorIs(err, os.ErrNotExist)
 }
+
+func (s *atomicWriteTestSuite) TestPendingCommit() {
+	f := fs.NewFS(fs.NewMapFS(), fs.WithAtomicWrite(), fs.WithDirCreate(os.ModePerm))
+	p, err := fs.CreatePending(f, "dir", os.ModePerm)
+	s.Require().NoError(err)
+	_, err = io.WriteString(p, "hello")
+	s.Require().NoError(err)
+	s.Require().NoError(p.Commit("dir/a.txt"))
+	s.Require().NoError(p.Close())
+	s.ErrorIs(p.Commit("dir/b.txt"), os.ErrClosed)
+
+	data, err := iofs.ReadFile(f, "dir/a.txt")
+	s.Require().NoError(err)
+	s.Equal("hello", string(data))
+
+	entries, err := iofs.ReadDir(f, "dir")
+	s.Require().NoError(err)
+	s.Len(entries, 1)
+}
+
+func (s *atomicWriteTestSuite) TestPendingClose() {
+	f := fs.NewFS(fs.NewMapFS(), fs.WithAtomicWrite(), fs.WithDirCreate(os.ModePerm))
+	p, err := fs.CreatePending(f, "dir", os.ModePerm)
+	s.Require().NoError(err)
+	_, err = io.WriteString(p, "hello")
+	s.Require().NoError(err)
+	s.Require().NoError(p.Close())
+	s.ErrorIs(p.Commit("dir/a.txt"), os.ErrClosed)
+
+	entries, err := iofs.ReadDir(f, "dir")
+	s.Require().NoError(err)
+	s.Empty(entries)
+}
+
+func (s *atomicWriteTestSuite) TestPendingUnsupported() {
+	_, err := fs.CreatePending(fs.NewMapFS(), "dir", os.ModePerm)
+	s.ErrorIs(err, errors.ErrUnsupported)
+}
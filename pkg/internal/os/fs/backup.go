@@ -0,0 +1,348 @@
+package fs
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mws.cloud/util-toolset/pkg/utils/consterr"
+)
+
+const (
+	// ErrNotBound is returned by the methods of [Backup] and [Trash] called
+	// before [WithBackup] or [WithTrash] is applied.
+	ErrNotBound = consterr.Error("not bound to a file system")
+	// ErrAlreadyBound is returned by the writes of [FS] wrapped with
+	// [WithBackup] or [WithTrash] if the backup or the trash is already used
+	// by another [FS].
+	ErrAlreadyBound = consterr.Error("already bound to another file system")
+)
+
+const (
+	backupSuffix = ".bak"
+	// versionTimeLayout is the layout of version timestamps, it sorts
+	// in chronological order.
+	versionTimeLayout = "20060102T150405.000000000Z"
+	backupDirPerm     = 0o755
+)
+
+// BackupOption is an option for [NewBackup].
+type BackupOption func(*Backup)
+
+// WithBackupDir places the backups under dir, which is a path in the wrapped
+// [FS], keeping the paths of the files. By default, backups are placed next to
+// the files.
+func WithBackupDir(dir string) BackupOption {
+	return func(b *Backup) {
+		b.dir = dir
+	}
+}
+
+// WithBackupVersions keeps every previous content as a version named
+// NAME.TIMESTAMP.bak instead of a single NAME.bak.
+func WithBackupVersions() BackupOption {
+	return func(b *Backup) {
+		b.versioned = true
+	}
+}
+
+// WithBackupMaxCount keeps at most n versions of every file, the oldest ones
+// are removed when a new version is saved. It implies [WithBackupVersions].
+func WithBackupMaxCount(n int) BackupOption {
+	return func(b *Backup) {
+		b.versioned = true
+		b.maxCount = n
+	}
+}
+
+// WithBackupMaxAge removes the versions older than d when a new version of
+// the file is saved, the newest version is always kept. It implies
+// [WithBackupVersions].
+func WithBackupMaxAge(d time.Duration) BackupOption {
+	return func(b *Backup) {
+		b.versioned = true
+		b.maxAge = d
+	}
+}
+
+// BackupVersion is a saved previous content of a file.
+type BackupVersion struct {
+	// Path is the path of the backup in the wrapped [FS].
+	Path string
+	// Time is the time the version was saved.
+	Time time.Time
+}
+
+// Backup saves the previous content of files overwritten or removed through
+// [WithBackup], and lists and restores it. A backup must be used by a single [FS].
+type Backup struct {
+	dir       string
+	versioned bool
+	maxCount  int
+	maxAge    time.Duration
+	now       func() time.Time
+
+	binding
+}
+
+// binding is [FS] which [Backup] or [Trash] is bound to by its option.
+type binding struct {
+	mu sync.Mutex
+	fs FS
+}
+
+// bind binds f, unless another [FS] is bound.
+func (b *binding) bind(f FS) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.fs != nil {
+		return ErrAlreadyBound
+	}
+	b.fs = f
+	return nil
+}
+
+// bound returns the bound [FS].
+func (b *binding) bound() (FS, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.fs == nil {
+		return nil, ErrNotBound
+	}
+	return b.fs, nil
+}
+
+// NewBackup returns [Backup] with the options.
+func NewBackup(options ...BackupOption) *Backup {
+	b := &Backup{now: time.Now}
+	for _, o := range options {
+		o(b)
+	}
+	return b
+}
+
+// Versions returns the saved versions of the named file, the newest first.
+// The name is the path in the [FS] passed to [WithBackup].
+func (b *Backup) Versions(name string) ([]BackupVersion, error) {
+	f, err := b.bound()
+	if err != nil {
+		return nil, err
+	}
+	return b.versions(f, name)
+}
+
+func (b *Backup) versions(f FS, name string) ([]BackupVersion, error) {
+	dir, prefix := b.location(name)
+	entries, err := f.ReadDir(dir)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return nil, nil
+	case err != nil:
+		return nil, err
+	}
+
+	var versions []BackupVersion
+	for _, e := range entries {
+		v, ok := b.version(dir, prefix, e)
+		if ok {
+			versions = append(versions, v)
+		}
+	}
+	slices.SortFunc(versions, func(l, r BackupVersion) int {
+		return r.Time.Compare(l.Time)
+	})
+	return versions, nil
+}
+
+// Restore writes the content of the version back to the named file. The
+// current content is saved as a version first, so a restore can be undone.
+func (b *Backup) Restore(name string, v BackupVersion) error {
+	f, err := b.bound()
+	if err != nil {
+		return err
+	}
+	data, err := fs.ReadFile(f, v.Path)
+	if err != nil {
+		return err
+	}
+	info, err := fs.Stat(f, v.Path)
+	if err != nil {
+		return err
+	}
+	if err := b.save(f, name); err != nil {
+		return err
+	}
+	return f.WriteFile(name, data, info.Mode().Perm())
+}
+
+// location returns the directory of the backups of the named file
+// and the prefix of their names.
+func (b *Backup) location(name string) (string, string) {
+	p := path.Clean(name)
+	if b.dir != "" {
+		p = path.Join(b.dir, cleanRelPath(p))
+	}
+	return path.Dir(p), path.Base(p)
+}
+
+func (b *Backup) version(dir, prefix string, e fs.DirEntry) (BackupVersion, bool) {
+	v := BackupVersion{Path: path.Join(dir, e.Name())}
+	rest, ok := strings.CutPrefix(e.Name(), prefix+".")
+	if e.IsDir() || !ok {
+		return v, false
+	}
+
+	if !b.versioned {
+		if rest != backupSuffix[1:] {
+			return v, false
+		}
+		info, err := e.Info()
+		if err != nil {
+			return v, false
+		}
+		v.Time = info.ModTime()
+		return v, true
+	}
+
+	stamp, ok := strings.CutSuffix(rest, backupSuffix)
+	if !ok {
+		return v, false
+	}
+	t, err := time.Parse(versionTimeLayout, stamp)
+	if err != nil {
+		return v, false
+	}
+	v.Time = t
+	return v, true
+}
+
+// save copies the current content of the named file of f, if it is a regular
+// file, to a new backup and applies the retention.
+func (b *Backup) save(f FS, name string) error {
+	info, err := fs.Stat(f, name)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return nil
+	case err != nil:
+		return err
+	case !info.Mode().IsRegular():
+		return nil
+	}
+
+	data, err := fs.ReadFile(f, name)
+	if err != nil {
+		return err
+	}
+
+	dir, prefix := b.location(name)
+	target := path.Join(dir, prefix+backupSuffix)
+	if b.versioned {
+		target = path.Join(dir, prefix+"."+b.now().UTC().Format(versionTimeLayout)+backupSuffix)
+	}
+	if err := f.MkdirAll(dir, backupDirPerm); err != nil {
+		return err
+	}
+	if err := f.WriteFile(target, data, info.Mode().Perm()); err != nil {
+		return err
+	}
+
+	if b.versioned {
+		return b.prune(f, name)
+	}
+	return nil
+}
+
+// prune removes the versions exceeding the retention limits.
+func (b *Backup) prune(f FS, name string) error {
+	if b.maxCount <= 0 && b.maxAge <= 0 {
+		return nil
+	}
+	versions, err := b.versions(f, name)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for i, v := range versions {
+		expired := i > 0 && b.maxAge > 0 && b.now().Sub(v.Time) > b.maxAge
+		if expired || b.maxCount > 0 && i >= b.maxCount {
+			if err := f.Remove(v.Path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+type backupWrite struct {
+	wrapped
+	backup *Backup
+	// err is [ErrAlreadyBound] if the backup is used by another [FS].
+	err error
+}
+
+// WithBackup is an option for [NewFS] that wraps the [FS] so that the previous
+// content of a file is saved to the backup before WriteFile changes it, Rename
+// replaces it or Remove removes it. Files written with OpenFile and removed with
+// RemoveAll are not saved.
+//
+// The backups are written with the wrapped [FS]. With [WithAtomicWrite] applied
+// inside of this option, both the backups and the files are written atomically
+// and a file is saved before it is written; applied outside, a file is saved when
+// the written temporary file is renamed over it. A WriteFile which does not
+// change the content does not save a backup.
+//
+// A backup is bound to the first [FS] the option is applied to; the writes of
+// other ones fail with [ErrAlreadyBound].
+func WithBackup(b *Backup) Option {
+	return func(fs FS) FS {
+		return &backupWrite{wrapped: wrapped{fs}, backup: b, err: b.bind(fs)}
+	}
+}
+
+func (b *backupWrite) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	actual, err := fs.ReadFile(b.FS, name)
+	if err == nil && bytes.Equal(actual, data) {
+		return b.FS.WriteFile(name, data, perm)
+	}
+	if err := b.save(name); err != nil {
+		return err
+	}
+	return b.FS.WriteFile(name, data, perm)
+}
+
+func (b *backupWrite) Rename(src, dst string) error {
+	if err := b.save(dst); err != nil {
+		return err
+	}
+	return b.FS.Rename(src, dst)
+}
+
+func (b *backupWrite) Remove(name string) error {
+	if err := b.save(name); err != nil {
+		return err
+	}
+	return b.FS.Remove(name)
+}
+
+func (b *backupWrite) save(name string) error {
+	err := b.err
+	if err == nil {
+		err = b.backup.save(b.FS, name)
+	}
+	if err != nil {
+		return &fs.PathError{Op: "backup", Path: name, Err: err}
+	}
+	return nil
+}
+
+func (*backupWrite) String() string {
+	return "WithBackup()"
+}
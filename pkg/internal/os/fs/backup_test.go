@@ -0,0 +1,162 @@
+package fs_test
+
+import (
+	iofs "io/fs"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"go.mws.cloud/util-toolset/pkg/internal/os/fs"
+)
+
+func TestBackup(t *testing.T) {
+	base := fs.NewFS(fs.NewMapFS(), fs.WithDirCreate(os.ModePerm))
+	b := fs.NewBackup()
+	f := fs.NewFS(base, fs.WithBackup(b))
+
+	require.NoError(t, f.WriteFile("dir/a.txt", []byte("v1"), 0o600))
+	_, err := iofs.Stat(base, "dir/a.txt.bak")
+	require.ErrorIs(t, err, iofs.ErrNotExist)
+
+	require.NoError(t, f.WriteFile("dir/a.txt", []byte("v2"), 0o600))
+	require.NoError(t, f.WriteFile("dir/a.txt", []byte("v2"), 0o600))
+	require.Equal(t, "v1", readString(t, base, "dir/a.txt.bak"))
+	info, err := iofs.Stat(base, "dir/a.txt.bak")
+	require.NoError(t, err)
+	require.Equal(t, iofs.FileMode(0o600), info.Mode().Perm())
+
+	require.NoError(t, f.WriteFile("dir/b.txt", []byte("new"), 0o644))
+	require.NoError(t, f.Rename("dir/b.txt", "dir/a.txt"))
+	require.Equal(t, "v2", readString(t, base, "dir/a.txt.bak"))
+
+	require.NoError(t, f.Remove("dir/a.txt"))
+	require.Equal(t, "new", readString(t, base, "dir/a.txt.bak"))
+
+	versions, err := b.Versions("dir/a.txt")
+	require.NoError(t, err)
+	require.Len(t, versions, 1)
+	require.Equal(t, "dir/a.txt.bak", versions[0].Path)
+
+	require.NoError(t, b.Restore("dir/a.txt", versions[0]))
+	require.Equal(t, "new", readString(t, f, "dir/a.txt"))
+
+	versions, err = b.Versions("missing/a.txt")
+	require.NoError(t, err)
+	require.Empty(t, versions)
+}
+
+func TestBackupBinding(t *testing.T) {
+	b := fs.NewBackup()
+	_, err := b.Versions("a.txt")
+	require.ErrorIs(t, err, fs.ErrNotBound)
+	require.ErrorIs(t, b.Restore("a.txt", fs.BackupVersion{Path: "a.txt.bak"}), fs.ErrNotBound)
+
+	first, second := fs.NewMapFS(), fs.NewMapFS()
+	f := fs.NewFS(first, fs.WithBackup(b))
+	other := fs.NewFS(second, fs.WithBackup(b))
+	require.NoError(t, f.WriteFile("a.txt", []byte("v1"), 0o644))
+	require.NoError(t, f.WriteFile("a.txt", []byte("v2"), 0o644))
+	require.ErrorIs(t, other.WriteFile("a.txt", []byte("v1"), 0o644), fs.ErrAlreadyBound)
+	require.ErrorIs(t, other.Remove("a.txt"), fs.ErrAlreadyBound)
+
+	versions, err := b.Versions("a.txt")
+	require.NoError(t, err)
+	require.Len(t, versions, 1)
+	require.Equal(t, "v1", readString(t, first, versions[0].Path))
+}
+
+func TestBackupVersions(t *testing.T) {
+	base := fs.NewFS(fs.NewMapFS(), fs.WithDirCreate(os.ModePerm))
+	b := fs.NewBackup(fs.WithBackupDir("backups"), fs.WithBackupMaxCount(2))
+	f := fs.NewFS(base, fs.WithBackup(b), fs.WithAtomicWrite())
+
+	for _, content := range []string{"v1", "v2", "v3", "v4"} {
+		require.NoError(t, f.WriteFile("dir/a.txt", []byte(content), 0o644))
+	}
+
+	versions, err := b.Versions("dir/a.txt")
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+	require.Equal(t, "v3", readString(t, base, versions[0].Path))
+	require.Equal(t, "v2", readString(t, base, versions[1].Path))
+	require.Regexp(t, `^backups/dir/a\.txt\.\d{8}T\d{6}\.\d{9}Z\.bak$`, versions[0].Path)
+	require.True(t, versions[0].Time.After(versions[1].Time))
+
+	files, err := fs.ListDir(base, "dir")
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+
+	require.NoError(t, b.Restore("dir/a.txt", versions[1]))
+	require.Equal(t, "v2", readString(t, f, "dir/a.txt"))
+	versions, err = b.Versions("dir/a.txt")
+	require.NoError(t, err)
+	require.Equal(t, "v4", readString(t, base, versions[0].Path))
+}
+
+func TestBackupAtomicWriteOutside(t *testing.T) {
+	base := fs.NewFS(fs.NewMapFS(), fs.WithDirCreate(os.ModePerm))
+	b := fs.NewBackup(fs.WithBackupVersions())
+	f := fs.NewFS(base, fs.WithAtomicWrite(), fs.WithBackup(b))
+
+	require.NoError(t, f.WriteFile("a.txt", []byte("v1"), 0o644))
+	require.NoError(t, f.WriteFile("a.txt", []byte("v2"), 0o644))
+
+	versions, err := b.Versions("a.txt")
+	require.NoError(t, err)
+	require.Len(t, versions, 1)
+	require.Equal(t, "v1", readString(t, base, versions[0].Path))
+}
+
+func TestBackupMaxAge(t *testing.T) {
+	base := fs.NewMapFS()
+	c := newClock()
+	b := fs.NewBackup(fs.WithBackupMaxAge(20*time.Minute), fs.WithBackupClock(c.Now))
+	f := fs.NewFS(base, fs.WithBackup(b))
+
+	require.NoError(t, f.WriteFile("a.txt", []byte("v1"), 0o644))
+	require.NoError(t, f.WriteFile("a.txt", []byte("v2"), 0o644))
+	c.Advance(10 * time.Minute)
+	require.NoError(t, f.WriteFile("a.txt", []byte("v3"), 0o644))
+	c.Advance(11 * time.Minute)
+	require.NoError(t, f.WriteFile("a.txt", []byte("v4"), 0o644))
+
+	versions, err := b.Versions("a.txt")
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+	require.Equal(t, "v3", readString(t, base, versions[0].Path))
+	require.Equal(t, "v2", readString(t, base, versions[1].Path))
+
+	c.Advance(21 * time.Minute)
+	require.NoError(t, f.Remove("a.txt"))
+	versions, err = b.Versions("a.txt")
+	require.NoError(t, err)
+	require.Len(t, versions, 1)
+	require.Equal(t, "v4", readString(t, base, versions[0].Path))
+}
+
+// clock is a fake clock for the time based retention.
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newClock() *clock {
+	return &clock{now: time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)}
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+}
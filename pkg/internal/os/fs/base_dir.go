@@ -1,15 +1,18 @@
 package fs
 
 import (
+	"context"
+	"fmt"
 	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 )
 
 // baseDir option adds prefix dir to all names.
 type baseDir struct {
-	FS
+	wrapped
 	dir string
 }
 
@@ -18,12 +21,38 @@ type baseDir struct {
 func WithBaseDir(dir string) Option {
 	return func(fs FS) FS {
 		return &baseDir{
-			FS:  fs,
-			dir: dir,
+			wrapped: wrapped{fs},
+			dir:     dir,
 		}
 	}
 }
 
+// Sub returns [FS] corresponding to the subtree rooted at fsys's dir. Unlike
+// [fs.Sub], the result keeps write capabilities, and all paths are confined
+// to dir the same way as with [WithBaseDir]. If fsys implements [fs.SubFS]
+// and returns [FS], its Sub method is used, so nested calls do not stack
+// wrappers.
+func Sub(fsys FS, dir string) (FS, error) {
+	if !fs.ValidPath(dir) {
+		return nil, &os.PathError{Op: "sub", Path: dir, Err: fs.ErrInvalid}
+	}
+	if dir == "." {
+		return fsys, nil
+	}
+
+	if s, ok := fsys.(fs.SubFS); ok {
+		sub, err := s.Sub(dir)
+		if err != nil {
+			return nil, err
+		}
+		if f, ok := sub.(FS); ok {
+			return f, nil
+		}
+	}
+
+	return wrap(&baseDir{wrapped: wrapped{fsys}, dir: dir}, fsys), nil
+}
+
 func (b *baseDir) Open(name string) (_ fs.File, err error) {
 	if name, err = b.path(name); err != nil {
 		return nil, &os.PathError{Op: "open", Path: name, Err: err}
@@ -85,6 +114,57 @@ func (b *baseDir) RemoveAll(name string) (err error) {
 	return b.FS.RemoveAll(name)
 }
 
+func (b *baseDir) Chmod(name string, mode fs.FileMode) (err error) {
+	if name, err = b.path(name); err != nil {
+		return &os.PathError{Op: "chmod", Path: name, Err: err}
+	}
+	return Chmod(b.FS, name, mode)
+}
+
+func (b *baseDir) Chtimes(name string, atime, mtime time.Time) (err error) {
+	if name, err = b.path(name); err != nil {
+		return &os.PathError{Op: "chtimes", Path: name, Err: err}
+	}
+	return Chtimes(b.FS, name, atime, mtime)
+}
+
+func (b *baseDir) Lock(ctx context.Context, name string, mode LockMode) (_ Unlocker, err error) {
+	if name, err = b.path(name); err != nil {
+		return nil, &os.PathError{Op: "lock", Path: name, Err: err}
+	}
+	return Lock(ctx, b.FS, name, mode)
+}
+
+func (b *baseDir) Watch(ctx context.Context, name string, recursive bool) (<-chan WatchEvent, error) {
+	inner, err := b.path(name)
+	if err != nil {
+		return nil, &os.PathError{Op: "watch", Path: name, Err: err}
+	}
+	events, err := watchFS(ctx, b.FS, inner, recursive)
+	if err != nil || b.dir == "." || b.dir == "" {
+		return events, err
+	}
+
+	return mapWatchEvents(ctx, events, func(e *WatchEvent) bool {
+		var ok bool
+		e.Path, ok = relWatchPath(b.dir, e.Path)
+		return ok
+	}), nil
+}
+
+// Sub returns a view of the subtree rooted at dir, nested views share the
+// same underlying [FS] instead of stacking wrappers.
+func (b *baseDir) Sub(dir string) (fs.FS, error) {
+	if !fs.ValidPath(dir) {
+		return nil, &os.PathError{Op: "sub", Path: dir, Err: fs.ErrInvalid}
+	}
+	if dir == "." {
+		return b, nil
+	}
+
+	return wrap(&baseDir{wrapped: wrapped{b.FS}, dir: filepath.Join(b.dir, dir)}, b.FS), nil
+}
+
 func (b *baseDir) path(name string) (path string, err error) {
 	if b.dir == "." || b.dir == "" {
 		return name, nil
@@ -92,9 +172,20 @@ func (b *baseDir) path(name string) (path string, err error) {
 
 	bpath := filepath.Clean(b.dir)
 	path = filepath.Clean(filepath.Join(bpath, name))
-	if !strings.HasPrefix(path, bpath) {
+	// The separator is appended, so "/foo" does not match "/foobar".
+	prefix := strings.TrimSuffix(bpath, string(filepath.Separator)) + string(filepath.Separator)
+	if path != bpath && !strings.HasPrefix(path, prefix) {
 		return name, os.ErrNotExist
 	}
 
 	return path, nil
 }
+
+// basePath returns the directory of the wrapped [FS] all paths are confined to.
+func (b *baseDir) basePath() string {
+	return filepath.Clean(b.dir)
+}
+
+func (b *baseDir) String() string {
+	return fmt.Sprintf("WithBaseDir(%q)", b.dir)
+}
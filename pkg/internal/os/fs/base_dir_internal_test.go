@@ -0,0 +1,21 @@
+package fs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubDoesNotStackWrappers(t *testing.T) {
+	m := NewMapFS()
+
+	sub, err := Sub(m, "foo")
+	require.NoError(t, err)
+	nested, err := Sub(sub, "bar/baz")
+	require.NoError(t, err)
+
+	b, ok := nested.(*baseDir)
+	require.True(t, ok)
+	require.Same(t, m, b.FS)
+	require.Equal(t, "foo/bar/baz", b.dir)
+}
@@ -1,6 +1,7 @@
 package fs_test
 
 import (
+	iofs "io/fs"
 	"os"
 	"testing"
 
@@ -36,3 +37,29 @@ func TestBaseDir(t *testing.T) {
 	mock.EXPECT().RemoveAll("/foo/y.txt")
 	_ = baseDir.RemoveAll("../bar/../foo/y.txt")
 }
+
+func TestSub(t *testing.T) {
+	gm := gomock.NewController(t)
+	mock := fsmock.NewMockFS(gm)
+
+	sub, err := fs.Sub(mock, "foo")
+	require.NoError(t, err)
+	nested, err := fs.Sub(sub, "bar")
+	require.NoError(t, err)
+
+	mock.EXPECT().WriteFile("foo/bar/x.txt", gomock.Any(), gomock.Any())
+	require.NoError(t, nested.WriteFile("x.txt", nil, os.ModePerm))
+
+	mock.EXPECT().Rename("foo/bar/x.txt", "foo/bar/y/x.txt")
+	require.NoError(t, nested.Rename("/x.txt", "y/../y/x.txt"))
+
+	require.Error(t, nested.WriteFile("../x.txt", nil, os.ModePerm))
+	require.Error(t, sub.WriteFile("../foobar/x.txt", nil, os.ModePerm))
+
+	_, err = fs.Sub(mock, "../foo")
+	require.ErrorIs(t, err, iofs.ErrInvalid)
+
+	same, err := fs.Sub(mock, ".")
+	require.NoError(t, err)
+	require.Same(t, mock, same)
+}
@@ -0,0 +1,433 @@
+package fs
+
+import (
+	"bytes"
+	"container/list"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultCacheMaxBytes   = 64 << 20
+	defaultCacheMaxEntries = 4096
+	// cacheDirEntrySize is the approximate size of a cached directory entry
+	// in addition to its name.
+	cacheDirEntrySize = 64
+)
+
+// CacheStats are the statistics of [Cache].
+type CacheStats struct {
+	// Hits is the number of reads served from the cache.
+	Hits uint64
+	// Misses is the number of reads passed to the wrapped [FS].
+	Misses uint64
+	// Evictions is the number of entries removed to fit the limits
+	// or because they are expired.
+	Evictions uint64
+	// Entries is the current number of cached files and directories.
+	Entries int
+	// Bytes is the current size of the cached data.
+	Bytes int64
+}
+
+// CacheOption is an option for [NewCache].
+type CacheOption func(*Cache)
+
+// WithCacheMaxBytes limits the total size of the cached data, the default is
+// 64 MiB. Files larger than the limit are never cached.
+func WithCacheMaxBytes(n int64) CacheOption {
+	return func(c *Cache) {
+		c.maxBytes = n
+	}
+}
+
+// WithCacheMaxEntries limits the number of cached files and directories,
+// the default is 4096.
+func WithCacheMaxEntries(n int) CacheOption {
+	return func(c *Cache) {
+		c.maxEntries = n
+	}
+}
+
+// WithCacheTTL makes the cached entries expire after d, so that changes made
+// bypassing the [FS] are eventually seen.
+func WithCacheTTL(d time.Duration) CacheOption {
+	return func(c *Cache) {
+		c.ttl = d
+	}
+}
+
+// WithCacheModTimeCheck makes every cache hit check with Stat that the
+// modification time and the size of the file have not changed, so that changes
+// made bypassing the [FS] are seen immediately. It saves reading the file only.
+func WithCacheModTimeCheck() CacheOption {
+	return func(c *Cache) {
+		c.modTimeCheck = true
+	}
+}
+
+// Cache is an LRU cache of file contents and directory listings for [WithCache].
+// A cache must be used by a single [FS].
+type Cache struct {
+	maxBytes     int64
+	maxEntries   int
+	ttl          time.Duration
+	modTimeCheck bool
+	now          func() time.Time
+
+	mu      sync.Mutex
+	lru     *list.List
+	entries map[string]*list.Element
+	// gen is incremented on every invalidation, so that the data read
+	// concurrently with a change is not cached.
+	gen   uint64
+	stats CacheStats
+}
+
+type cacheEntry struct {
+	key     string
+	info    fs.FileInfo
+	data    []byte
+	dir     []fs.DirEntry
+	size    int64
+	expires time.Time
+}
+
+// NewCache returns [Cache] with the options.
+func NewCache(options ...CacheOption) *Cache {
+	c := &Cache{
+		maxBytes:   defaultCacheMaxBytes,
+		maxEntries: defaultCacheMaxEntries,
+		lru:        list.New(),
+		entries:    map[string]*list.Element{},
+		now:        time.Now,
+	}
+	for _, o := range options {
+		o(c)
+	}
+	return c
+}
+
+// Stats returns the current statistics of the cache.
+func (c *Cache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Entries = c.lru.Len()
+	return stats
+}
+
+// get returns the entry of a file or a directory listing if it is cached and
+// not expired, and the current generation to be passed to put on a miss.
+func (c *Cache) get(key string, dir bool) (*cacheEntry, uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, c.gen
+	}
+
+	e := el.Value.(*cacheEntry) //nolint:forcetypeassert // only entries are stored
+	if !e.expires.IsZero() && c.now().After(e.expires) {
+		c.remove(el)
+		c.stats.Evictions++
+		return nil, c.gen
+	}
+	if (e.dir != nil) != dir {
+		return nil, c.gen
+	}
+
+	c.lru.MoveToFront(el)
+	return e, c.gen
+}
+
+// record counts a hit or a miss, removing the stale entry on a miss.
+func (c *Cache) record(e *cacheEntry, hit bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if hit {
+		c.stats.Hits++
+		return
+	}
+
+	c.stats.Misses++
+	if e == nil {
+		return
+	}
+	if el, ok := c.entries[e.key]; ok && el.Value == e {
+		c.remove(el)
+	}
+}
+
+// put caches the entry, unless the cache was invalidated since gen.
+func (c *Cache) put(e *cacheEntry, gen uint64) {
+	if e.size > c.maxBytes {
+		return
+	}
+	if c.ttl > 0 {
+		e.expires = c.now().Add(c.ttl)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if gen != c.gen {
+		return
+	}
+	if el, ok := c.entries[e.key]; ok {
+		c.remove(el)
+	}
+	c.entries[e.key] = c.lru.PushFront(e)
+	c.stats.Bytes += e.size
+
+	for c.stats.Bytes > c.maxBytes || c.maxEntries > 0 && c.lru.Len() > c.maxEntries {
+		c.remove(c.lru.Back())
+		c.stats.Evictions++
+	}
+}
+
+func (c *Cache) remove(el *list.Element) {
+	e := c.lru.Remove(el).(*cacheEntry) //nolint:forcetypeassert // only entries are stored
+	delete(c.entries, e.key)
+	c.stats.Bytes -= e.size
+}
+
+// invalidate removes the entries of the paths, the entries of everything
+// under them, and the listings of all their ancestors, as the directories may
+// be created along with them.
+func (c *Cache) invalidate(names ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gen++
+	for _, name := range names {
+		p := path.Clean(name)
+		for dir := p; dir != "." && dir != "/"; {
+			dir = path.Dir(dir)
+			if el, ok := c.entries[dir]; ok {
+				c.remove(el)
+			}
+		}
+		for key, el := range c.entries {
+			if key == p || p == "." || strings.HasPrefix(key, p+"/") {
+				c.remove(el)
+			}
+		}
+	}
+}
+
+type cached struct {
+	wrapped
+	cache *Cache
+}
+
+var _ fs.ReadFileFS = (*cached)(nil)
+
+// WithCache is an option for [NewFS] that wraps the [FS] so that the contents
+// of regular files read with Open and ReadFile and the listings read with
+// ReadDir are kept in the cache. Writes, renames and removes made through the
+// wrapped [FS] invalidate the affected entries; use [WithCacheTTL] or
+// [WithCacheModTimeCheck] if the files can be changed otherwise.
+//
+// Files written with OpenFile are invalidated both when opened and closed.
+// It is safe for concurrent use, if the wrapped [FS] is.
+func WithCache(c *Cache) Option {
+	return func(fs FS) FS {
+		return &cached{wrapped: wrapped{fs}, cache: c}
+	}
+}
+
+func (c *cached) Open(name string) (fs.File, error) {
+	e, f, err := c.file(name)
+	switch {
+	case err != nil:
+		return nil, err
+	case f != nil:
+		return f, nil
+	}
+	return &memFile{info: e.info, Reader: bytes.NewReader(e.data)}, nil
+}
+
+func (c *cached) ReadFile(name string) (_ []byte, rErr error) {
+	e, f, err := c.file(name)
+	switch {
+	case err != nil:
+		return nil, err
+	case f != nil:
+		defer closeWithErr(f, &rErr)
+		return io.ReadAll(f)
+	}
+	return bytes.Clone(e.data), nil
+}
+
+// file returns the cached regular file, reading it on a miss. Directories,
+// other non-regular files and files larger than the cache are not cached,
+// and the opened file is returned instead, to be closed by the caller.
+func (c *cached) file(name string) (_ *cacheEntry, _ fs.File, rErr error) {
+	key := path.Clean(name)
+	e, gen := c.cache.get(key, false)
+	if e != nil && c.valid(e) {
+		c.cache.record(e, true)
+		return e, nil, nil
+	}
+	c.cache.record(e, false)
+
+	f, err := c.FS.Open(name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, nil, errors.Join(err, f.Close())
+	}
+	if !info.Mode().IsRegular() || info.Size() > c.cache.maxBytes {
+		return nil, f, nil
+	}
+	defer closeWithErr(f, &rErr)
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, nil, err
+	}
+	e = &cacheEntry{key: key, info: snapshotInfo(info), data: data, size: int64(len(key) + len(data))}
+	c.cache.put(e, gen)
+	return e, nil, nil
+}
+
+func (c *cached) ReadDir(name string) ([]fs.DirEntry, error) {
+	key := path.Clean(name)
+	e, gen := c.cache.get(key, true)
+	if e != nil && c.valid(e) {
+		c.cache.record(e, true)
+		return append([]fs.DirEntry{}, e.dir...), nil
+	}
+	c.cache.record(e, false)
+
+	var info fs.FileInfo
+	if c.cache.modTimeCheck {
+		stat, err := fs.Stat(c.FS, name)
+		if err != nil {
+			return nil, err
+		}
+		info = snapshotInfo(stat)
+	}
+	entries, err := c.FS.ReadDir(name)
+	if err != nil {
+		return nil, err
+	}
+
+	size := int64(len(key))
+	for _, d := range entries {
+		size += int64(len(d.Name()) + cacheDirEntrySize)
+	}
+	// The listing is never nil, so that it is distinguished from a file.
+	c.cache.put(&cacheEntry{key: key, info: info, dir: append([]fs.DirEntry{}, entries...), size: size}, gen)
+	return entries, nil
+}
+
+// valid checks that the entry is not changed, if [WithCacheModTimeCheck] is used.
+func (c *cached) valid(e *cacheEntry) bool {
+	if !c.cache.modTimeCheck {
+		return true
+	}
+	info, err := fs.Stat(c.FS, e.key)
+	return err == nil && e.info != nil && info.ModTime().Equal(e.info.ModTime()) && info.Size() == e.info.Size()
+}
+
+func (c *cached) OpenFile(name string, flag int, perm fs.FileMode) (WritableFile, error) {
+	if flag&writeFlags == 0 {
+		return c.FS.OpenFile(name, flag, perm)
+	}
+
+	c.cache.invalidate(name)
+	f, err := c.FS.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return &closeHookFile{WritableFile: f, onClose: func() error {
+		c.cache.invalidate(name)
+		return nil
+	}}, nil
+}
+
+func (c *cached) MkdirAll(name string, perm fs.FileMode) error {
+	defer c.cache.invalidate(name)
+	return c.FS.MkdirAll(name, perm)
+}
+
+func (c *cached) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	defer c.cache.invalidate(name)
+	return c.FS.WriteFile(name, data, perm)
+}
+
+func (c *cached) Rename(src, dst string) error {
+	defer c.cache.invalidate(src, dst)
+	return c.FS.Rename(src, dst)
+}
+
+func (c *cached) Remove(name string) error {
+	defer c.cache.invalidate(name)
+	return c.FS.Remove(name)
+}
+
+func (c *cached) RemoveAll(name string) error {
+	defer c.cache.invalidate(name)
+	return c.FS.RemoveAll(name)
+}
+
+func (c *cached) Chmod(name string, mode fs.FileMode) error {
+	defer c.cache.invalidate(name)
+	return Chmod(c.FS, name, mode)
+}
+
+func (c *cached) Chtimes(name string, atime, mtime time.Time) error {
+	defer c.cache.invalidate(name)
+	return Chtimes(c.FS, name, atime, mtime)
+}
+
+// cachedInfo is a copy of [fs.FileInfo], as some implementations return
+// the live state of the file.
+type cachedInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func snapshotInfo(info fs.FileInfo) fs.FileInfo {
+	return &cachedInfo{name: info.Name(), size: info.Size(), mode: info.Mode(), modTime: info.ModTime()}
+}
+
+func (i *cachedInfo) Name() string       { return i.name }
+func (i *cachedInfo) Size() int64        { return i.size }
+func (i *cachedInfo) Mode() fs.FileMode  { return i.mode }
+func (i *cachedInfo) ModTime() time.Time { return i.modTime }
+func (i *cachedInfo) IsDir() bool        { return i.mode.IsDir() }
+func (*cachedInfo) Sys() any             { return nil }
+
+// memFile is a read-only [fs.File] with the contents in memory.
+type memFile struct {
+	*bytes.Reader
+	info fs.FileInfo
+}
+
+func (f *memFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (*memFile) Close() error {
+	return nil
+}
+
+func (*cached) String() string {
+	return "WithCache()"
+}
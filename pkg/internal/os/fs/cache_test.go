@@ -0,0 +1,189 @@
+package fs_test
+
+import (
+	"fmt"
+	"io"
+	iofs "io/fs"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"go.mws.cloud/util-toolset/pkg/internal/os/fs"
+)
+
+// countingFS counts the reads passed to the wrapped FS.
+type countingFS struct {
+	fs.FS
+	opens    atomic.Int64
+	readDirs atomic.Int64
+}
+
+func (c *countingFS) Open(name string) (iofs.File, error) {
+	c.opens.Add(1)
+	return c.FS.Open(name)
+}
+
+func (c *countingFS) ReadDir(name string) ([]iofs.DirEntry, error) {
+	c.readDirs.Add(1)
+	return c.FS.ReadDir(name)
+}
+
+func readString(t *testing.T, f fs.ReadOnlyFS, name string) string {
+	t.Helper()
+
+	data, err := fs.ReadFile(f, name)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestCache(t *testing.T) {
+	base := &countingFS{FS: fs.NewFS(fs.NewMapFS(), fs.WithDirCreate(os.ModePerm))}
+	c := fs.NewCache()
+	f := fs.NewFS(base, fs.WithCache(c))
+
+	require.NoError(t, f.WriteFile("dir/a.txt", []byte("a"), 0o644))
+	require.Equal(t, "a", readString(t, f, "dir/a.txt"))
+	require.Equal(t, "a", readString(t, f, "dir/a.txt"))
+	require.EqualValues(t, 1, base.opens.Load())
+
+	file, err := f.Open("dir/a.txt")
+	require.NoError(t, err)
+	info, err := file.Stat()
+	require.NoError(t, err)
+	require.EqualValues(t, 1, info.Size())
+	require.NoError(t, file.Close())
+	require.EqualValues(t, 1, base.opens.Load())
+
+	entries, err := f.ReadDir("dir")
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	_, err = f.ReadDir("dir")
+	require.NoError(t, err)
+	require.EqualValues(t, 1, base.readDirs.Load())
+
+	require.Equal(t, fs.CacheStats{Hits: 3, Misses: 2, Entries: 2, Bytes: c.Stats().Bytes}, c.Stats())
+
+	// Writes through the FS invalidate the file and the listing.
+	require.NoError(t, f.WriteFile("dir/a.txt", []byte("aa"), 0o644))
+	require.NoError(t, f.WriteFile("dir/b.txt", []byte("b"), 0o644))
+	require.Equal(t, "aa", readString(t, f, "dir/a.txt"))
+	entries, err = f.ReadDir("dir")
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+
+	w, err := f.OpenFile("dir/a.txt", os.O_WRONLY|os.O_APPEND, 0o644)
+	require.NoError(t, err)
+	_, err = w.WriteString("a")
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	require.Equal(t, "aaa", readString(t, f, "dir/a.txt"))
+
+	require.NoError(t, f.Rename("dir", "moved"))
+	_, err = fs.ReadFile(f, "dir/a.txt")
+	require.ErrorIs(t, err, iofs.ErrNotExist)
+	require.Equal(t, "b", readString(t, f, "moved/b.txt"))
+
+	require.NoError(t, f.RemoveAll("moved"))
+	_, err = fs.ReadFile(f, "moved/b.txt")
+	require.ErrorIs(t, err, iofs.ErrNotExist)
+	_, err = f.ReadDir("moved")
+	require.ErrorIs(t, err, iofs.ErrNotExist)
+}
+
+func TestCacheLimits(t *testing.T) {
+	base := &countingFS{FS: fs.NewMapFS()}
+	for i := range 3 {
+		require.NoError(t, base.WriteFile(fmt.Sprint(i), []byte("12345"), 0o644))
+	}
+	require.NoError(t, base.WriteFile("big", make([]byte, 100), 0o644))
+
+	c := fs.NewCache(fs.WithCacheMaxEntries(2), fs.WithCacheMaxBytes(50))
+	f := fs.NewFS(base, fs.WithCache(c))
+	for _, name := range []string{"0", "1", "0", "2", "big", "big"} {
+		readString(t, f, name)
+	}
+
+	stats := c.Stats()
+	require.Equal(t, 2, stats.Entries)
+	require.EqualValues(t, 12, stats.Bytes)
+	require.EqualValues(t, 1, stats.Evictions)
+	require.EqualValues(t, 1, stats.Hits)
+	require.EqualValues(t, 5, stats.Misses)
+
+	// A file which is not cached is opened once.
+	opens := base.opens.Load()
+	file, err := f.Open("big")
+	require.NoError(t, err)
+	data, err := io.ReadAll(file)
+	require.NoError(t, err)
+	require.Len(t, data, 100)
+	require.NoError(t, file.Close())
+	require.Equal(t, opens+1, base.opens.Load())
+}
+
+func TestCacheAncestors(t *testing.T) {
+	f := fs.NewFS(fs.NewMapFS(), fs.WithCache(fs.NewCache()), fs.WithDirCreate(os.ModePerm))
+	require.NoError(t, f.MkdirAll("a", os.ModePerm))
+	entries, err := f.ReadDir("a")
+	require.NoError(t, err)
+	require.Empty(t, entries)
+
+	// The directories created along with the entries are listed.
+	require.NoError(t, f.WriteFile("a/b/c.txt", nil, 0o644))
+	require.NoError(t, f.MkdirAll("a/x/y", os.ModePerm))
+	entries, err = f.ReadDir("a")
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+}
+
+func TestCacheExternalChanges(t *testing.T) {
+	base := fs.NewMapFS()
+	require.NoError(t, base.WriteFile("a.txt", []byte("a"), 0o644))
+
+	c := newClock()
+	ttl := fs.NewFS(base, fs.WithCache(fs.NewCache(fs.WithCacheTTL(time.Minute), fs.WithCacheClock(c.Now))))
+	modTime := fs.NewFS(base, fs.WithCache(fs.NewCache(fs.WithCacheModTimeCheck())))
+	require.Equal(t, "a", readString(t, ttl, "a.txt"))
+	require.Equal(t, "a", readString(t, modTime, "a.txt"))
+
+	require.NoError(t, base.WriteFile("a.txt", []byte("b"), 0o644))
+	require.NoError(t, fs.Chtimes(base, "a.txt", time.Now(), time.Now().Add(time.Hour)))
+	require.Equal(t, "b", readString(t, modTime, "a.txt"))
+	require.Equal(t, "a", readString(t, ttl, "a.txt"))
+
+	c.Advance(time.Minute)
+	require.Equal(t, "a", readString(t, ttl, "a.txt"))
+	c.Advance(time.Nanosecond)
+	require.Equal(t, "b", readString(t, ttl, "a.txt"))
+}
+
+func TestCacheConcurrent(t *testing.T) {
+	f := fs.NewFS(fs.NewMapFS(), fs.WithCache(fs.NewCache(fs.WithCacheMaxEntries(4))))
+
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Go(func() {
+			name := fmt.Sprint(i % 4)
+			for j := range 50 {
+				data := []byte(fmt.Sprint(j))
+				if err := f.WriteFile(name, data, 0o644); err != nil {
+					t.Error(err)
+				}
+				if _, err := fs.ReadFile(f, name); err != nil {
+					t.Error(err)
+				}
+				if _, err := f.ReadDir("."); err != nil {
+					t.Error(err)
+				}
+			}
+		})
+	}
+	wg.Wait()
+
+	require.NoError(t, f.WriteFile("0", []byte("last"), 0o644))
+	require.Equal(t, "last", readString(t, f, "0"))
+}
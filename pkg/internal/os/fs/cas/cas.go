@@ -0,0 +1,362 @@
+// Package cas provides a content-addressable blob store on top of [fs.FS].
+package cas
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	iofs "io/fs"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"go.mws.cloud/util-toolset/pkg/internal/os/fs"
+	"go.mws.cloud/util-toolset/pkg/utils/consterr"
+)
+
+const (
+	// ErrInvalidDigest is returned for digests which are malformed or do not
+	// belong to the algorithm of the store.
+	ErrInvalidDigest = consterr.Error("invalid digest")
+	// ErrCorrupted is returned when the content of a blob does not match
+	// its digest.
+	ErrCorrupted = consterr.Error("blob content does not match its digest")
+)
+
+const (
+	defaultShardDepth = 1
+	// shardWidth is the number of hex digits in a shard directory name.
+	shardWidth = 2
+	tmpSuffix  = ".tmp"
+	// tmpMinAge is the age of the temporary files after which GC considers
+	// them left by interrupted writes.
+	tmpMinAge = time.Hour
+)
+
+// Algorithm is a hash algorithm of [Store].
+type Algorithm struct {
+	// Name is the prefix of digests and the name of the store directory.
+	Name string
+	// New returns a new hash.
+	New func() hash.Hash
+}
+
+// SHA256 is the default [Algorithm].
+var SHA256 = Algorithm{Name: "sha256", New: sha256.New}
+
+// Digest identifies a blob, it is formatted as "ALGORITHM:HEX",
+// e.g. "sha256:e3b0c442...".
+type Digest string
+
+// ParseDigest checks that s is a well-formed digest.
+func ParseDigest(s string) (Digest, error) {
+	d := Digest(s)
+	alg, h, ok := strings.Cut(s, ":")
+	if !ok || alg == "" || h == "" || len(h)%2 != 0 || strings.ToLower(h) != h {
+		return "", &iofs.PathError{Op: "parse", Path: s, Err: ErrInvalidDigest}
+	}
+	if _, err := hex.DecodeString(h); err != nil {
+		return "", &iofs.PathError{Op: "parse", Path: s, Err: errors.Join(ErrInvalidDigest, err)}
+	}
+	return d, nil
+}
+
+// Algorithm returns the name of the algorithm of the digest.
+func (d Digest) Algorithm() string {
+	alg, _, _ := strings.Cut(string(d), ":")
+	return alg
+}
+
+// Hex returns the hex-encoded hash of the digest.
+func (d Digest) Hex() string {
+	_, h, _ := strings.Cut(string(d), ":")
+	return h
+}
+
+func (d Digest) String() string {
+	return string(d)
+}
+
+// Option is an option for [New].
+type Option func(*Store)
+
+// WithAlgorithm sets the hash algorithm, the default is [SHA256].
+func WithAlgorithm(alg Algorithm) Option {
+	return func(s *Store) {
+		s.alg = alg
+	}
+}
+
+// WithShardDepth sets the number of directory levels the blobs are spread
+// over, every level is named by the next two hex digits of the hash.
+// The default is 1, e.g. "sha256/e3/e3b0c442...".
+func WithShardDepth(n int) Option {
+	return func(s *Store) {
+		s.shardDepth = n
+	}
+}
+
+// WithPerm sets the permissions of the blob files, the default is 0o644.
+func WithPerm(perm iofs.FileMode) Option {
+	return func(s *Store) {
+		s.perm = perm
+	}
+}
+
+// Store is a content-addressable blob store. Blobs are kept in the root of
+// the [fs.FS] under the directory named by the algorithm, use [fs.WithBaseDir]
+// to place the store elsewhere. It is safe for concurrent use, if the [fs.FS] is.
+type Store struct {
+	fs         fs.FS
+	alg        Algorithm
+	shardDepth int
+	perm       iofs.FileMode
+}
+
+// New returns [Store] on top of f. Blobs are written with [fs.WithAtomicWrite]
+// to temporary files in the store directory and renamed, so readers never see
+// a partially written blob.
+func New(f fs.FS, options ...Option) *Store {
+	s := &Store{
+		alg:        SHA256,
+		shardDepth: defaultShardDepth,
+		perm:       0o644,
+	}
+	for _, o := range options {
+		o(s)
+	}
+	s.fs = fs.NewFS(f, fs.WithAtomicWrite(), fs.WithDirCreate(os.ModePerm))
+	return s
+}
+
+// Put stores the content read from r and returns its digest. Storing
+// the content which is already in the store is a no-op. The content is
+// streamed to a pending file of [fs.WithAtomicWrite] while it is hashed,
+// so it is never kept in memory.
+func (s *Store) Put(r io.Reader) (_ Digest, rErr error) {
+	f, err := fs.CreatePending(s.fs, s.alg.Name, s.perm)
+	if err != nil {
+		return "", err
+	}
+	// The pending file is removed if the blob is already stored or on errors.
+	defer func() {
+		rErr = errors.Join(rErr, f.Close())
+	}()
+
+	h := s.alg.New()
+	if _, err = io.Copy(f, io.TeeReader(r, h)); err != nil {
+		return "", err
+	}
+	d := s.digest(h)
+
+	ok, err := s.Has(d)
+	switch {
+	case err != nil:
+		return "", err
+	case ok:
+		return d, nil
+	}
+	if err = f.Commit(s.path(d)); err != nil {
+		// The same blob may be put concurrently.
+		if ok, _ := s.Has(d); ok {
+			return d, nil
+		}
+		return "", err
+	}
+	return d, nil
+}
+
+// PutBytes stores the data and returns its digest, see [Store.Put].
+func (s *Store) PutBytes(data []byte) (Digest, error) {
+	return s.Put(bytes.NewReader(data))
+}
+
+// Get opens the blob. The content is verified while it is read: reading past
+// the end of a corrupted blob fails with [ErrCorrupted].
+func (s *Store) Get(d Digest) (io.ReadCloser, error) {
+	if err := s.check(d); err != nil {
+		return nil, err
+	}
+	name := s.path(d)
+	f, err := s.fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &verifyingReader{File: f, name: name, want: d.Hex(), hash: s.alg.New()}, nil
+}
+
+// ReadBytes reads and verifies the whole blob.
+func (s *Store) ReadBytes(d Digest) (_ []byte, rErr error) {
+	r, err := s.Get(d)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		rErr = errors.Join(rErr, r.Close())
+	}()
+	return io.ReadAll(r)
+}
+
+// Verify reads the blob and checks that its content matches the digest.
+func (s *Store) Verify(d Digest) (rErr error) {
+	r, err := s.Get(d)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		rErr = errors.Join(rErr, r.Close())
+	}()
+	_, err = io.Copy(io.Discard, r)
+	return err
+}
+
+// Has reports whether the blob is in the store. The content is not verified.
+func (s *Store) Has(d Digest) (bool, error) {
+	if err := s.check(d); err != nil {
+		return false, err
+	}
+	_, err := iofs.Stat(s.fs, s.path(d))
+	switch {
+	case errors.Is(err, iofs.ErrNotExist):
+		return false, nil
+	case err != nil:
+		return false, err
+	}
+	return true, nil
+}
+
+// Delete removes the blob, deleting a missing blob is not an error.
+func (s *Store) Delete(d Digest) error {
+	if err := s.check(d); err != nil {
+		return err
+	}
+	if err := s.fs.Remove(s.path(d)); err != nil && !errors.Is(err, iofs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// List returns the digests of all the blobs in the store, sorted.
+func (s *Store) List() ([]Digest, error) {
+	var digests []Digest
+	err := s.walk(func(info iofs.FileInfo) error {
+		if d, ok := s.parsePath(info.Name()); ok {
+			digests = append(digests, d)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	slices.Sort(digests)
+	return digests, nil
+}
+
+// GC removes all the blobs which are not in the live set and the temporary
+// files left by interrupted writes, and returns the digests of the removed
+// blobs. Temporary files younger than an hour are kept, as they may be
+// written concurrently. Blobs put concurrently with GC may be removed, if they
+// are not live.
+func (s *Store) GC(live []Digest) ([]Digest, error) {
+	keep := make(map[Digest]struct{}, len(live))
+	for _, d := range live {
+		keep[d] = struct{}{}
+	}
+
+	var removed []Digest
+	err := s.walk(func(info iofs.FileInfo) error {
+		name := info.Name()
+		d, ok := s.parsePath(name)
+		if ok {
+			if _, ok := keep[d]; ok {
+				return nil
+			}
+		} else if !strings.HasSuffix(name, tmpSuffix) || time.Since(info.ModTime()) < tmpMinAge {
+			return nil
+		}
+
+		if err := s.fs.Remove(name); err != nil && !errors.Is(err, iofs.ErrNotExist) {
+			return err
+		}
+		if ok {
+			removed = append(removed, d)
+		}
+		return nil
+	})
+	return removed, err
+}
+
+// walk calls fn for every file of the store directory, Name of the info is
+// the path of the file.
+func (s *Store) walk(fn func(info iofs.FileInfo) error) error {
+	files, err := fs.ListDir(s.fs, s.alg.Name)
+	switch {
+	case errors.Is(err, iofs.ErrNotExist):
+		return nil
+	case err != nil:
+		return err
+	}
+
+	for _, f := range files {
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Store) digest(h hash.Hash) Digest {
+	return Digest(s.alg.Name + ":" + hex.EncodeToString(h.Sum(nil)))
+}
+
+// check validates the digest for the store.
+func (s *Store) check(d Digest) error {
+	if _, err := ParseDigest(string(d)); err != nil {
+		return err
+	}
+	if d.Algorithm() != s.alg.Name || len(d.Hex()) != 2*s.alg.New().Size() {
+		return &iofs.PathError{Op: "check", Path: string(d), Err: ErrInvalidDigest}
+	}
+	return nil
+}
+
+// path returns the path of the blob, e.g. "sha256/e3/e3b0c442...".
+func (s *Store) path(d Digest) string {
+	h := d.Hex()
+	parts := []string{s.alg.Name}
+	for i := range s.shardDepth {
+		parts = append(parts, h[i*shardWidth:(i+1)*shardWidth])
+	}
+	return path.Join(append(parts, h)...)
+}
+
+// parsePath returns the digest of the blob path, if it is one.
+func (s *Store) parsePath(name string) (Digest, bool) {
+	d := Digest(s.alg.Name + ":" + path.Base(name))
+	if s.check(d) != nil || s.path(d) != name {
+		return "", false
+	}
+	return d, true
+}
+
+// verifyingReader hashes the content as it is read and checks it at EOF.
+type verifyingReader struct {
+	iofs.File
+	name string
+	want string
+	hash hash.Hash
+}
+
+func (r *verifyingReader) Read(p []byte) (int, error) {
+	n, err := r.File.Read(p)
+	r.hash.Write(p[:n])
+	if errors.Is(err, io.EOF) && hex.EncodeToString(r.hash.Sum(nil)) != r.want {
+		return n, &iofs.PathError{Op: "read", Path: r.name, Err: ErrCorrupted}
+	}
+	return n, err
+}
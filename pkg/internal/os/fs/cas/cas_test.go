@@ -0,0 +1,169 @@
+package cas_test
+
+import (
+	"crypto/md5" //nolint:gosec // not used for security
+	"io"
+	iofs "io/fs"
+	"strings"
+	"sync"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"go.mws.cloud/util-toolset/pkg/internal/os/fs"
+	"go.mws.cloud/util-toolset/pkg/internal/os/fs/cas"
+	"go.mws.cloud/util-toolset/pkg/internal/testing/fstest"
+)
+
+const helloDigest = "sha256:2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+
+func TestStore(t *testing.T) {
+	fstest.RunFS(t, func(t *testing.T, f fs.FS) {
+		s := cas.New(f)
+
+		d, err := s.Put(strings.NewReader("hello"))
+		require.NoError(t, err)
+		require.Equal(t, cas.Digest(helloDigest), d)
+		require.Equal(t, "sha256", d.Algorithm())
+
+		d, err = s.PutBytes([]byte("hello"))
+		require.NoError(t, err)
+		require.Equal(t, cas.Digest(helloDigest), d)
+
+		data, err := fs.ReadFile(f, "sha256/2c/"+d.Hex())
+		require.NoError(t, err)
+		require.Equal(t, "hello", string(data))
+
+		ok, err := s.Has(d)
+		require.NoError(t, err)
+		require.True(t, ok)
+
+		data, err = s.ReadBytes(d)
+		require.NoError(t, err)
+		require.Equal(t, "hello", string(data))
+		require.NoError(t, s.Verify(d))
+
+		digests, err := s.List()
+		require.NoError(t, err)
+		require.Equal(t, []cas.Digest{d}, digests)
+
+		require.NoError(t, s.Delete(d))
+		require.NoError(t, s.Delete(d))
+		ok, err = s.Has(d)
+		require.NoError(t, err)
+		require.False(t, ok)
+
+		_, err = s.Get(d)
+		require.ErrorIs(t, err, iofs.ErrNotExist)
+	})
+}
+
+func TestStoreCorrupted(t *testing.T) {
+	f := fs.NewMapFS()
+	s := cas.New(f)
+	d, err := s.PutBytes([]byte("hello"))
+	require.NoError(t, err)
+
+	require.NoError(t, f.WriteFile("sha256/2c/"+d.Hex(), []byte("hellO"), 0o644))
+	require.ErrorIs(t, s.Verify(d), cas.ErrCorrupted)
+
+	r, err := s.Get(d)
+	require.NoError(t, err)
+	_, err = io.ReadAll(r)
+	require.ErrorIs(t, err, cas.ErrCorrupted)
+	require.NoError(t, r.Close())
+}
+
+func TestStoreInvalidDigest(t *testing.T) {
+	s := cas.New(fs.NewMapFS())
+	for _, d := range []string{"", "sha256", "sha256:", "sha256:abc", "sha256:ABCD", "sha256:zz", "md5:" + strings.Repeat("0", 32), "sha256:00"} {
+		_, err := s.Has(cas.Digest(d))
+		require.ErrorIs(t, err, cas.ErrInvalidDigest, d)
+	}
+
+	_, err := cas.ParseDigest("sha256:zz")
+	require.ErrorIs(t, err, cas.ErrInvalidDigest)
+	d, err := cas.ParseDigest(helloDigest)
+	require.NoError(t, err)
+	require.Equal(t, helloDigest, d.String())
+}
+
+func TestStoreOptions(t *testing.T) {
+	f := fs.NewMapFS()
+	s := cas.New(f, cas.WithAlgorithm(cas.Algorithm{Name: "md5", New: md5.New}), cas.WithShardDepth(2), cas.WithPerm(0o600))
+
+	d, err := s.PutBytes([]byte("hello"))
+	require.NoError(t, err)
+	require.Equal(t, cas.Digest("md5:5d41402abc4b2a76b9719d911017c592"), d)
+
+	info, err := iofs.Stat(f, "md5/5d/41/5d41402abc4b2a76b9719d911017c592")
+	require.NoError(t, err)
+	require.Equal(t, iofs.FileMode(0o600), info.Mode().Perm())
+}
+
+func TestStoreGC(t *testing.T) {
+	f := fs.NewMapFS()
+	s := cas.New(f)
+
+	var digests []cas.Digest
+	for _, content := range []string{"a", "b", "c"} {
+		d, err := s.PutBytes([]byte(content))
+		require.NoError(t, err)
+		digests = append(digests, d)
+	}
+	require.NoError(t, f.WriteFile("sha256/ca/leftover.tmp", []byte("x"), 0o644))
+	old := time.Now().Add(-2 * time.Hour)
+	require.NoError(t, fs.Chtimes(f, "sha256/ca/leftover.tmp", old, old))
+	// A temporary file of a Put in progress is kept.
+	require.NoError(t, f.WriteFile("sha256/writing.tmp", []byte("x"), 0o644))
+	require.NoError(t, f.WriteFile("sha256/other", []byte("x"), 0o644))
+
+	removed, err := s.GC(digests[1:2])
+	require.NoError(t, err)
+	require.ElementsMatch(t, []cas.Digest{digests[0], digests[2]}, removed)
+
+	left, err := s.List()
+	require.NoError(t, err)
+	require.Equal(t, digests[1:2], left)
+
+	names, err := fs.ListDir(f, "sha256")
+	require.NoError(t, err)
+	require.Len(t, names, 3)
+	require.Equal(t, "sha256/other", names[1].Name())
+	require.Equal(t, "sha256/writing.tmp", names[2].Name())
+
+	removed, err = cas.New(fs.NewMapFS()).GC(nil)
+	require.NoError(t, err)
+	require.Empty(t, removed)
+}
+
+func TestStoreConcurrentPut(t *testing.T) {
+	s := cas.New(fs.NewFS(fs.NewRealFS(), fs.WithBaseDir(t.TempDir())))
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Go(func() {
+			d, err := s.PutBytes([]byte("hello"))
+			if err != nil || d != helloDigest {
+				t.Error(d, err)
+			}
+		})
+	}
+	wg.Wait()
+	require.NoError(t, s.Verify(helloDigest))
+}
+
+func TestStorePutError(t *testing.T) {
+	f := fs.NewMapFS()
+	s := cas.New(f)
+
+	_, err := s.Put(io.MultiReader(strings.NewReader("partial"), iotest.ErrReader(iofs.ErrClosed)))
+	require.ErrorIs(t, err, iofs.ErrClosed)
+
+	// The temporary file is removed.
+	files, err := fs.List(f)
+	require.NoError(t, err)
+	require.Empty(t, files)
+}
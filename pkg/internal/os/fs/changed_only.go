@@ -2,28 +2,336 @@ package fs
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
 	"io/fs"
 	"os"
+	"path"
+	"strconv"
+	"strings"
 )
 
-type changedOnly struct{ FS }
+// changedOnlyChunk is the size of the chunks the existing content is compared
+// and hashed by.
+const changedOnlyChunk = 64 << 10
+
+// ChangedOnlyOption is an option for [WithChangedOnly].
+type ChangedOnlyOption func(*changedOnly)
+
+// WithChangedOnlyDigestDir keeps the SHA-256 digests of the written files in
+// sidecar files under dir, which is a path in the wrapped [FS], e.g.
+// "DIR/NAME.sha256". A digest is used instead of reading the file while the
+// size and the modification time of the file match the recorded ones.
+func WithChangedOnlyDigestDir(dir string) ChangedOnlyOption {
+	return func(c *changedOnly) {
+		c.digestDir = dir
+	}
+}
+
+type changedOnly struct {
+	wrapped
+	digestDir string
+}
 
 // WithChangedOnly is an option for [NewFS] that wraps the [FS] so that WriteFile
-// only writes if the content has changed.
-func WithChangedOnly() Option {
+// only writes if the content has changed. The existing file is compared in
+// chunks, unless the [FS] implements [fs.ReadFileFS], e.g. [WithCache].
+//
+// OpenFile with [os.O_TRUNC] writes to a temporary file next to the file,
+// hashing the data, and on Close the temporary file either replaces the file,
+// keeping its mode, or, if the content is the same, is removed, so the
+// modification time of an unchanged file is kept. Other OpenFile calls are
+// passed through.
+func WithChangedOnly(options ...ChangedOnlyOption) Option {
 	return func(fs FS) FS {
-		return &changedOnly{FS: fs}
+		c := &changedOnly{wrapped: wrapped{fs}}
+		for _, o := range options {
+			o(c)
+		}
+		return c
 	}
 }
 
 func (c *changedOnly) WriteFile(name string, data []byte, m fs.FileMode) error {
-	actual, err := fs.ReadFile(c.FS, name)
-	if err != nil && !os.IsNotExist(err) {
-		return err
+	_, err := c.writeFile(name, data, m)
+	return err
+}
+
+// writeFile implements [resultWriter], so that [WithReport] applied outside
+// does not read the file again.
+func (c *changedOnly) writeFile(name string, data []byte, m fs.FileMode) (WriteResult, error) {
+	var sum []byte
+	if c.digestDir != "" {
+		h := sha256.Sum256(data)
+		sum = h[:]
+	}
+
+	exists, same, err := c.compare(name, data, sum)
+	switch {
+	case err != nil:
+		return 0, err
+	case same:
+		return WriteUnchanged, nil
+	}
+
+	if err := c.FS.WriteFile(name, data, m); err != nil {
+		return 0, err
+	}
+	if err := c.storeDigest(name, sum); err != nil {
+		return 0, err
+	}
+	if exists {
+		return WriteModified, nil
+	}
+	return WriteCreated, nil
+}
+
+// compare reports whether the named file exists and has the content data,
+// comparing the digests if sum is set.
+func (c *changedOnly) compare(name string, data, sum []byte) (bool, bool, error) {
+	if sum != nil {
+		actual, err := c.digest(name)
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, false, nil
+		}
+		return err == nil, bytes.Equal(actual, sum), err
+	}
+
+	if r, ok := c.FS.(fs.ReadFileFS); ok {
+		actual, err := r.ReadFile(name)
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, false, nil
+		}
+		return err == nil, bytes.Equal(actual, data), err
+	}
+
+	same, err := c.equal(name, data)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, false, nil
+	}
+	return err == nil, same, err
+}
+
+// equal compares the named file with data in chunks.
+func (c *changedOnly) equal(name string, data []byte) (_ bool, rErr error) {
+	f, err := c.FS.Open(name)
+	if err != nil {
+		return false, err
+	}
+	defer closeWithErr(f, &rErr)
+
+	info, err := f.Stat()
+	if err != nil {
+		return false, err
+	}
+	if !info.Mode().IsRegular() || info.Size() != int64(len(data)) {
+		return false, nil
+	}
+
+	buf := make([]byte, min(changedOnlyChunk, len(data)+1))
+	for {
+		n, err := f.Read(buf)
+		if n > len(data) || !bytes.Equal(buf[:n], data[:n]) {
+			return false, nil
+		}
+		data = data[n:]
+		switch {
+		case errors.Is(err, io.EOF):
+			return len(data) == 0, nil
+		case err != nil:
+			return false, err
+		}
+	}
+}
+
+// digest returns the SHA-256 digest of the named file, from the sidecar file
+// if it is up to date.
+func (c *changedOnly) digest(name string) ([]byte, error) {
+	info, err := fs.Stat(c.FS, name)
+	if err != nil {
+		return nil, err
+	}
+	if c.digestDir != "" {
+		if sum, ok := c.loadDigest(name, info); ok {
+			return sum, nil
+		}
 	}
 
-	if bytes.Equal(actual, data) {
+	sum, err := hashFile(c.FS, name)
+	if err != nil {
+		return nil, err
+	}
+	return sum, c.storeDigest(name, sum)
+}
+
+// hashFile returns the SHA-256 hash of the named file.
+func hashFile(f fs.FS, name string) (_ []byte, rErr error) {
+	r, err := f.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer closeWithErr(r, &rErr)
+
+	h := sha256.New()
+	if _, err = io.Copy(h, r); err != nil {
+		return nil, &fs.PathError{Op: "hash", Path: name, Err: err}
+	}
+	return h.Sum(nil), nil
+}
+
+func (c *changedOnly) digestPath(name string) string {
+	return path.Join(c.digestDir, cleanRelPath(name)) + ".sha256"
+}
+
+// loadDigest reads the sidecar file: "HEX SIZE MTIME_UNIX_NANO".
+func (c *changedOnly) loadDigest(name string, info fs.FileInfo) ([]byte, bool) {
+	data, err := fs.ReadFile(c.FS, c.digestPath(name))
+	if err != nil {
+		return nil, false
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) != 3 || fields[1] != strconv.FormatInt(info.Size(), 10) ||
+		fields[2] != strconv.FormatInt(info.ModTime().UnixNano(), 10) {
+		return nil, false
+	}
+	sum, err := hex.DecodeString(fields[0])
+	return sum, err == nil
+}
+
+// storeDigest writes the sidecar file of the named file, if sum is set
+// and the digest directory is used.
+func (c *changedOnly) storeDigest(name string, sum []byte) error {
+	if c.digestDir == "" || sum == nil {
 		return nil
 	}
-	return c.FS.WriteFile(name, data, m)
+	info, err := fs.Stat(c.FS, name)
+	if err != nil {
+		return err
+	}
+
+	p := c.digestPath(name)
+	if err := c.FS.MkdirAll(path.Dir(p), 0o755); err != nil {
+		return err
+	}
+	line := fmt.Sprintf("%x %d %d\n", sum, info.Size(), info.ModTime().UnixNano())
+	return c.FS.WriteFile(p, []byte(line), 0o644)
+}
+
+func (c *changedOnly) OpenFile(name string, flag int, perm fs.FileMode) (WritableFile, error) {
+	f, _, err := c.openFile(name, flag, perm, func(WriteResult) {})
+	return f, err
+}
+
+// openFile implements [resultWriter]: done is called with the result on Close
+// of the file written to a temporary one, and false is returned for the files
+// which are passed through.
+func (c *changedOnly) openFile(name string, flag int, perm fs.FileMode, done func(WriteResult)) (WritableFile, bool, error) {
+	if flag&os.O_TRUNC == 0 || flag&(os.O_APPEND|os.O_RDWR|os.O_EXCL) != 0 {
+		f, err := c.FS.OpenFile(name, flag, perm)
+		return f, false, err
+	}
+
+	// The file is replaced by Rename, so its mode is kept explicitly.
+	info, err := fs.Stat(c.FS, name)
+	switch {
+	case err == nil:
+		perm = info.Mode().Perm()
+	case !errors.Is(err, fs.ErrNotExist) || flag&os.O_CREATE == 0:
+		return nil, false, err
+	}
+
+	tmp := tempName(name)
+	f, err := c.FS.OpenFile(tmp, flag|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return nil, false, err
+	}
+	return &hashingFile{WritableFile: f, hash: sha256.New(), onClose: func(sum []byte) error {
+		result, err := c.commit(tmp, name, sum)
+		if err == nil {
+			done(result)
+		}
+		return err
+	}}, true, nil
+}
+
+// commit replaces the named file with the written temporary file, or removes
+// the temporary file if the content is the same.
+func (c *changedOnly) commit(tmp, name string, sum []byte) (WriteResult, error) {
+	if sum == nil {
+		var err error
+		if sum, err = hashFile(c.FS, tmp); err != nil {
+			return 0, errors.Join(err, c.FS.Remove(tmp))
+		}
+	}
+
+	actual, err := c.digest(name)
+	switch {
+	case err == nil && bytes.Equal(actual, sum):
+		return WriteUnchanged, c.FS.Remove(tmp)
+	case err != nil && !errors.Is(err, fs.ErrNotExist):
+		return 0, errors.Join(err, c.FS.Remove(tmp))
+	}
+
+	if err := c.FS.Rename(tmp, name); err != nil {
+		return 0, errors.Join(err, c.FS.Remove(tmp))
+	}
+	if actual != nil {
+		return WriteModified, c.storeDigest(name, sum)
+	}
+	return WriteCreated, c.storeDigest(name, sum)
+}
+
+func (*changedOnly) String() string {
+	return "WithChangedOnly()"
+}
+
+// hashingFile hashes the data written sequentially and passes the digest to
+// onClose. After random access writes the digest is nil, so that the file
+// is hashed by reading it.
+type hashingFile struct {
+	WritableFile
+	hash    hash.Hash
+	onClose func(sum []byte) error
+}
+
+func (f *hashingFile) Write(p []byte) (int, error) {
+	n, err := f.WritableFile.Write(p)
+	if f.hash != nil {
+		f.hash.Write(p[:n])
+	}
+	return n, err
+}
+
+func (f *hashingFile) WriteString(s string) (int, error) {
+	return f.Write([]byte(s))
+}
+
+func (f *hashingFile) WriteAt(p []byte, off int64) (int, error) {
+	f.hash = nil
+	return f.WritableFile.WriteAt(p, off)
+}
+
+func (f *hashingFile) Seek(offset int64, whence int) (int64, error) {
+	f.hash = nil
+	return f.WritableFile.Seek(offset, whence)
+}
+
+func (f *hashingFile) Truncate(size int64) error {
+	f.hash = nil
+	return f.WritableFile.Truncate(size)
+}
+
+func (f *hashingFile) Close() error {
+	if err := f.WritableFile.Close(); err != nil {
+		return err
+	}
+	var sum []byte
+	if f.hash != nil {
+		sum = f.hash.Sum(nil)
+	}
+	return f.onClose(sum)
 }
@@ -1,8 +1,12 @@
 package fs_test
 
 import (
+	"bytes"
+	"io"
+	iofs "io/fs"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 	"go.uber.org/mock/gomock"
@@ -29,3 +33,126 @@ func TestChangedOnly(t *testing.T) {
 	mock.EXPECT().WriteFile("test.txt", []byte("world"), gomock.Any())
 	require.NoError(t, changedOnly.WriteFile("test.txt", []byte("world"), os.ModePerm))
 }
+
+func TestChangedOnlyStreaming(t *testing.T) {
+	for name, options := range map[string][]fs.ChangedOnlyOption{
+		"compare":    nil,
+		"digest dir": {fs.WithChangedOnlyDigestDir(".digests")},
+	} {
+		t.Run(name, func(t *testing.T) {
+			base := fs.NewFS(fs.NewRealFS(), fs.WithBaseDir(t.TempDir()), fs.WithDirCreate(os.ModePerm))
+			report := fs.NewReport()
+			f := fs.NewFS(base, fs.WithReport(report), fs.WithChangedOnly(options...))
+
+			large := bytes.Repeat([]byte("0123456789"), 20000)
+			require.NoError(t, f.WriteFile("dir/large.bin", large, 0o644))
+			old := time.Now().Add(-time.Hour).Truncate(time.Second)
+			require.NoError(t, fs.Chtimes(base, "dir/large.bin", old, old))
+
+			require.NoError(t, f.WriteFile("dir/large.bin", large, 0o644))
+			changed := bytes.Clone(large)
+			changed[len(changed)-1] = 'x'
+			require.NoError(t, f.WriteFile("dir/short.bin", large[:10], 0o644))
+			require.NoError(t, f.WriteFile("dir/short.bin", large[:11], 0o644))
+
+			info, err := iofs.Stat(base, "dir/large.bin")
+			require.NoError(t, err)
+			require.Equal(t, old, info.ModTime())
+
+			write := func(data []byte, seek bool) {
+				t.Helper()
+				w, err := f.OpenFile("dir/large.bin", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+				require.NoError(t, err)
+				if seek {
+					_, err = w.WriteAt(data[1:], 1)
+					require.NoError(t, err)
+					_, err = w.WriteAt(data[:1], 0)
+				} else {
+					_, err = io.Copy(w, bytes.NewReader(data))
+				}
+				require.NoError(t, err)
+				require.NoError(t, w.Close())
+			}
+
+			write(large, false)
+			write(large, true)
+			info, err = iofs.Stat(base, "dir/large.bin")
+			require.NoError(t, err)
+			require.Equal(t, old, info.ModTime())
+
+			write(changed, false)
+			actual, err := fs.ReadFile(base, "dir/large.bin")
+			require.NoError(t, err)
+			require.Equal(t, changed, actual)
+			write(large, true)
+			actual, err = fs.ReadFile(base, "dir/large.bin")
+			require.NoError(t, err)
+			require.Equal(t, large, actual)
+
+			files, err := fs.ListDir(base, "dir")
+			require.NoError(t, err)
+			require.Len(t, files, 2)
+			if options != nil {
+				files, err = fs.ListDir(base, ".digests")
+				require.NoError(t, err)
+				require.Len(t, files, 2)
+				require.Equal(t, ".digests/dir/large.bin.sha256", files[0].Name())
+			}
+
+			require.Equal(t, []fs.ReportEntry{
+				{Path: "dir/large.bin", Result: fs.WriteCreated},
+				{Path: "dir/short.bin", Result: fs.WriteCreated},
+			}, report.Entries())
+		})
+	}
+}
+
+func TestChangedOnlyOpenFile(t *testing.T) {
+	base := fs.NewFS(fs.NewRealFS(), fs.WithBaseDir(t.TempDir()))
+	report := fs.NewReport()
+	f := fs.NewFS(base, fs.WithReport(report), fs.WithChangedOnly())
+
+	_, err := f.OpenFile("missing.txt", os.O_WRONLY|os.O_TRUNC, 0o644)
+	require.ErrorIs(t, err, iofs.ErrNotExist)
+
+	require.NoError(t, base.WriteFile("same.txt", []byte("same"), 0o644))
+	require.NoError(t, base.WriteFile("private.txt", []byte("old"), 0o600))
+	write := func(name, data string) {
+		t.Helper()
+		w, err := f.OpenFile(name, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+		require.NoError(t, err)
+		_, err = w.WriteString(data)
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+	}
+	write("same.txt", "same")
+	write("private.txt", "new")
+	write("new.txt", "new")
+
+	// The mode of the replaced file is kept.
+	info, err := iofs.Stat(base, "private.txt")
+	require.NoError(t, err)
+	require.Equal(t, iofs.FileMode(0o600), info.Mode().Perm())
+
+	// Concurrent writes of the same file use separate temporary files.
+	w1, err := f.OpenFile("new.txt", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	require.NoError(t, err)
+	w2, err := f.OpenFile("new.txt", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	require.NoError(t, err)
+	_, err = w1.WriteString("1")
+	require.NoError(t, err)
+	_, err = w2.WriteString("2")
+	require.NoError(t, err)
+	require.NoError(t, w1.Close())
+	require.NoError(t, w2.Close())
+	require.Equal(t, "2", readString(t, base, "new.txt"))
+
+	files, err := fs.List(base)
+	require.NoError(t, err)
+	require.Len(t, files, 3)
+	require.Equal(t, []fs.ReportEntry{
+		{Path: "new.txt", Result: fs.WriteCreated},
+		{Path: "private.txt", Result: fs.WriteModified},
+		{Path: "same.txt", Result: fs.WriteUnchanged},
+	}, report.Entries())
+}
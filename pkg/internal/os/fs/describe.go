@@ -0,0 +1,149 @@
+package fs
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.mws.cloud/util-toolset/pkg/utils/consterr"
+)
+
+// ErrInvalidOptions is returned by [Validate] for wrappers applied in a wrong
+// order or several times.
+const ErrInvalidOptions = consterr.Error("invalid options")
+
+// Wrapper is implemented by [FS] returned by the options of this package and
+// should be implemented by custom wrappers, so that [Describe] and [Validate]
+// see through them.
+type Wrapper interface {
+	// Unwrap returns the wrapped [FS].
+	Unwrap() FS
+}
+
+// Unwrap returns the [FS] wrapped by f, or nil if f is not [Wrapper].
+func Unwrap(f ReadOnlyFS) FS {
+	if w, ok := f.(Wrapper); ok {
+		return w.Unwrap()
+	}
+	return nil
+}
+
+// Describe returns the stack of wrappers of f, one per line from the outermost
+// to the innermost, e.g. for NewFS(NewRealFS(), WithAtomicWrite(), WithBaseDir("out")):
+//
+//	WithAtomicWrite()
+//	WithBaseDir("out")
+//	RealFS
+//
+// The layers of [NewMountFS] and [NewOverlayFS] are indented under them.
+// Wrappers are described with String if they implement [fmt.Stringer].
+func Describe(f ReadOnlyFS) string {
+	var sb strings.Builder
+	describe(&sb, f, "")
+	return sb.String()
+}
+
+func describe(sb *strings.Builder, f ReadOnlyFS, indent string) {
+	for ; f != nil; f = Unwrap(f) {
+		if s, ok := f.(fmt.Stringer); ok {
+			sb.WriteString(indent + s.String() + "\n")
+		} else {
+			fmt.Fprintf(sb, "%s%T\n", indent, f)
+		}
+
+		switch f := f.(type) {
+		case *mountFS:
+			for _, mp := range f.mounts {
+				fmt.Fprintf(sb, "%s  %q:\n", indent, mp.prefix)
+				describe(sb, mp.fs, indent+"    ")
+			}
+		case *OverlayFS:
+			sb.WriteString(indent + "  upper:\n")
+			describe(sb, f.upper, indent+"    ")
+			sb.WriteString(indent + "  base:\n")
+			describe(sb, f.base, indent+"    ")
+		}
+	}
+}
+
+// orderRules are the known wrong orders of wrappers: inner applied inside outer.
+var orderRules = []struct {
+	outer, inner func(ReadOnlyFS) bool
+	reason       string
+}{
+	{
+		outer:  isLayer[*atomicWrite],
+		inner:  isLayer[*changedOnly],
+		reason: "WithChangedOnly is applied inside WithAtomicWrite, so it compares NAME.tmp files and always writes; apply it outside",
+	},
+	{
+		outer:  isLayer[*reproducible],
+		inner:  isLayer[*dirCreate],
+		reason: "WithDirCreate is applied inside WithReproducible, so the directories it creates are not normalized; apply it outside",
+	},
+	{
+		outer:  isLayer[*lockWrite],
+		inner:  isLayer[*baseDir],
+		reason: "WithLock is applied outside WithBaseDir, so the lock file is in the base directory; apply it inside",
+	},
+}
+
+func isLayer[T ReadOnlyFS](f ReadOnlyFS) bool {
+	_, ok := baseLayer(f).(T)
+	return ok
+}
+
+// Validate checks the stack of wrappers of f for the known mistakes: wrappers
+// applied in an order in which they silently do not work as intended, e.g.
+// [WithChangedOnly] inside [WithAtomicWrite], and wrappers applied several times.
+// [WithBaseDir] and [WithInterceptor] can be applied several times. The errors
+// wrap [ErrInvalidOptions] and are joined. The layers of [NewMountFS] and
+// [NewOverlayFS] are checked separately.
+func Validate(f ReadOnlyFS) error {
+	var layers []ReadOnlyFS
+	var errs []error
+	for ; f != nil; f = Unwrap(f) {
+		layers = append(layers, f)
+		switch f := f.(type) {
+		case *mountFS:
+			for _, mp := range f.mounts {
+				errs = append(errs, Validate(mp.fs))
+			}
+		case *OverlayFS:
+			errs = append(errs, Validate(f.upper), Validate(f.base))
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, l := range layers {
+		if isLayer[*baseDir](l) || isLayer[*intercepted](l) {
+			continue
+		}
+		kind := fmt.Sprintf("%T", baseLayer(l))
+		if seen[kind] {
+			errs = append(errs, fmt.Errorf("%w: %v is applied several times", ErrInvalidOptions, l))
+		}
+		seen[kind] = true
+	}
+
+	for i, outer := range layers {
+		for _, inner := range layers[i+1:] {
+			for _, rule := range orderRules {
+				if rule.outer(outer) && rule.inner(inner) {
+					errs = append(errs, fmt.Errorf("%w: %s", ErrInvalidOptions, rule.reason))
+				}
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// NewValidatedFS returns [FS] with the options like [NewFS], if the result
+// passes [Validate].
+func NewValidatedFS(f FS, options ...Option) (FS, error) {
+	f = NewFS(f, options...)
+	if err := Validate(f); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
@@ -0,0 +1,102 @@
+package fs_test
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"go.mws.cloud/util-toolset/pkg/internal/os/fs"
+)
+
+func TestDescribe(t *testing.T) {
+	f := fs.NewFS(fs.NewRealFS(),
+		fs.WithUnique(),
+		fs.WithAtomicWrite(),
+		fs.WithBaseDir("out"),
+		fs.WithLock("out.lock", time.Minute),
+		fs.WithDirCreate(os.ModePerm),
+	)
+	require.Equal(t, `WithUnique()
+WithAtomicWrite()
+WithBaseDir("out")
+WithLock("out.lock", 1m0s)
+WithDirCreate(0777)
+RealFS
+`, fs.Describe(f))
+
+	inner := fs.Unwrap(f)
+	require.Equal(t, "WithAtomicWrite()", inner.(fmt.Stringer).String())
+	require.Nil(t, fs.Unwrap(fs.NewMapFS()))
+
+	mount := fs.NewMountFS(map[string]fs.FS{
+		"/out": fs.NewFS(fs.NewMapFS(), fs.WithChangedOnly()),
+		"/":    fs.NewOverlayFS(fs.NewReadOnlyFS(fs.NewMapFS()), fs.NewMapFS()),
+	})
+	require.Equal(t, `WithCache()
+MountFS
+  "/out":
+    WithChangedOnly()
+    MapFS
+  "/":
+    OverlayFS
+      upper:
+        MapFS
+      base:
+        ReadOnlyFS
+        MapFS
+`, fs.Describe(fs.NewFS(mount, fs.WithCache(fs.NewCache()))))
+}
+
+func TestValidate(t *testing.T) {
+	for name, tc := range map[string]struct {
+		options []fs.Option
+		wantErr string
+	}{
+		"valid": {
+			options: []fs.Option{fs.WithUnique(), fs.WithChangedOnly(), fs.WithAtomicWrite(), fs.WithBaseDir("a"), fs.WithBaseDir("b")},
+		},
+		"changed only inside atomic write": {
+			options: []fs.Option{fs.WithAtomicWrite(), fs.WithBaseDir("out"), fs.WithChangedOnly()},
+			wantErr: "invalid options: WithChangedOnly is applied inside WithAtomicWrite, so it compares NAME.tmp files and always writes; apply it outside",
+		},
+		"dir create inside reproducible": {
+			options: []fs.Option{fs.WithReproducible(fs.ReproduciblePolicy{}), fs.WithDirCreate(os.ModePerm)},
+			wantErr: "WithDirCreate is applied inside WithReproducible",
+		},
+		"lock outside base dir": {
+			options: []fs.Option{fs.WithLock("out.lock", 0), fs.WithBaseDir("out")},
+			wantErr: "WithLock is applied outside WithBaseDir",
+		},
+		"duplicate": {
+			options: []fs.Option{fs.WithDirCreate(os.ModePerm), fs.WithBaseDir("out"), fs.WithDirCreate(0o700)},
+			wantErr: "invalid options: WithDirCreate(0700) is applied several times",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			f, err := fs.NewValidatedFS(fs.NewMapFS(), tc.options...)
+			if tc.wantErr == "" {
+				require.NoError(t, err)
+				require.NotNil(t, f)
+				return
+			}
+			require.ErrorIs(t, err, fs.ErrInvalidOptions)
+			require.ErrorContains(t, err, tc.wantErr)
+		})
+	}
+
+	mount := fs.NewMountFS(map[string]fs.FS{"/": fs.NewFS(fs.NewMapFS(), fs.WithAtomicWrite(), fs.WithChangedOnly())})
+	require.ErrorIs(t, fs.Validate(mount), fs.ErrInvalidOptions)
+}
+
+func TestValidateRecommended(t *testing.T) {
+	f := fs.NewRecommended(fs.NewMapFS())
+	require.NoError(t, fs.Validate(f))
+
+	// WithUnique inside WithAtomicWrite sees the renames of the temporary files.
+	require.NoError(t, f.WriteFile("a/x", nil, 0o644))
+	var nonUnique *fs.NonUniqueError
+	require.ErrorAs(t, f.WriteFile("a/x", nil, 0o644), &nonUnique)
+}
@@ -1,6 +1,7 @@
 package fs
 
 import (
+	"fmt"
 	"io/fs"
 	"os"
 	"path"
@@ -10,7 +11,7 @@ import (
 )
 
 type dirCreate struct {
-	FS
+	wrapped
 
 	mu      sync.Mutex
 	dirs    map[string]struct{}
@@ -23,7 +24,7 @@ type dirCreate struct {
 func WithDirCreate(dirMode fs.FileMode) Option {
 	return func(fs FS) FS {
 		return &dirCreate{
-			FS:      fs,
+			wrapped: wrapped{fs},
 			dirs:    map[string]struct{}{},
 			dirMode: dirMode,
 		}
@@ -110,3 +111,7 @@ func isSubPath(basepath, targpath string) bool {
 	result, err := filepath.Rel(basepath, targpath)
 	return err == nil && !strings.Contains(result, "..")
 }
+
+func (d *dirCreate) String() string {
+	return fmt.Sprintf("WithDirCreate(%#o)", d.dirMode)
+}
@@ -0,0 +1,49 @@
+package fs
+
+import (
+	"io"
+	"io/fs"
+	"time"
+)
+
+// virtualDirInfo describes a directory which exists only as a parent of
+// mount points.
+type virtualDirInfo struct{ name string }
+
+func (i virtualDirInfo) Name() string     { return i.name }
+func (virtualDirInfo) Size() int64        { return 0 }
+func (virtualDirInfo) Mode() fs.FileMode  { return fs.ModeDir | 0o555 }
+func (virtualDirInfo) ModTime() time.Time { return time.Time{} }
+func (virtualDirInfo) IsDir() bool        { return true }
+func (virtualDirInfo) Sys() any           { return nil }
+
+// dirFile is a read-only directory with precomputed entries, it is used
+// for directories which do not exist in a single underlying [FS].
+type dirFile struct {
+	info    fs.FileInfo
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *dirFile) Stat() (fs.FileInfo, error) { return d.info, nil }
+
+func (d *dirFile) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.Name(), Err: fs.ErrInvalid}
+}
+
+func (*dirFile) Close() error { return nil }
+
+func (d *dirFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	rest := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return rest, nil
+	}
+	if len(rest) == 0 {
+		return nil, io.EOF
+	}
+
+	n = min(n, len(rest))
+	d.offset += n
+	return rest[:n], nil
+}
@@ -0,0 +1,24 @@
+package fs
+
+import "time"
+
+// WithBackupClock replaces the clock of [Backup].
+func WithBackupClock(now func() time.Time) BackupOption {
+	return func(b *Backup) {
+		b.now = now
+	}
+}
+
+// WithTrashClock replaces the clock of [Trash].
+func WithTrashClock(now func() time.Time) TrashOption {
+	return func(t *Trash) {
+		t.now = now
+	}
+}
+
+// WithCacheClock replaces the clock of [Cache].
+func WithCacheClock(now func() time.Time) CacheOption {
+	return func(c *Cache) {
+		c.now = now
+	}
+}
@@ -1,14 +1,18 @@
 // Package fs provides an abstraction over the file system with read and write capabilities.
 package fs
 
+//go:generate mockgen -source=fs.go -destination=mock/mockfs.go
+
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
+	"math/rand/v2"
 	"os"
 	"path/filepath"
-	"slices"
-	"strings"
+	"time"
 
 	"github.com/spf13/afero"
 )
@@ -42,11 +46,37 @@ type WriteOnlyFS interface {
 	RemoveAll(path string) error
 }
 
-// ListFS is an optional interface that can be implemented by [FS] implementations
-// Currently only [FS] returned by [NewMapFS] implements this interface.
-// You should not use this interface in production code.
-type ListFS interface {
-	List() ([]fs.FileInfo, error)
+// ChmodFS is an optional interface that can be implemented by [FS] implementations
+// which are able to change file modes. It is implemented by [FS] returned by
+// [NewRealFS] and [NewMapFS] and forwarded by the options of this package.
+type ChmodFS interface {
+	Chmod(name string, mode fs.FileMode) error
+}
+
+// ChtimesFS is an optional interface that can be implemented by [FS] implementations
+// which are able to change file access and modification times. It is implemented
+// by [FS] returned by [NewRealFS] and [NewMapFS] and forwarded by the options of
+// this package.
+type ChtimesFS interface {
+	Chtimes(name string, atime, mtime time.Time) error
+}
+
+// Chmod changes the mode of the named file if f implements [ChmodFS],
+// otherwise it returns [errors.ErrUnsupported].
+func Chmod(f ReadOnlyFS, name string, mode fs.FileMode) error {
+	if c, ok := f.(ChmodFS); ok {
+		return c.Chmod(name, mode)
+	}
+	return &fs.PathError{Op: "chmod", Path: name, Err: errors.ErrUnsupported}
+}
+
+// Chtimes changes the access and modification times of the named file if f
+// implements [ChtimesFS], otherwise it returns [errors.ErrUnsupported].
+func Chtimes(f ReadOnlyFS, name string, atime, mtime time.Time) error {
+	if c, ok := f.(ChtimesFS); ok {
+		return c.Chtimes(name, atime, mtime)
+	}
+	return &fs.PathError{Op: "chtimes", Path: name, Err: errors.ErrUnsupported}
 }
 
 // Option is a function that configures an [FS].
@@ -60,7 +90,7 @@ type Option func(fs FS) FS
 // will be the innermost wrapper around the FS.
 func NewFS(fs FS, options ...Option) FS {
 	for i := len(options) - 1; i >= 0; i-- {
-		fs = options[i](fs)
+		fs = wrap(options[i](fs), fs)
 	}
 
 	return fs
@@ -73,7 +103,7 @@ func NewRealFS() FS {
 
 // NewMapFS returns [FS] built on top of in-memory map file system.
 func NewMapFS() FS {
-	return &mapFS{&aferoFS{a: afero.NewMemMapFs()}}
+	return &mapFS{aferoFS: &aferoFS{a: afero.NewMemMapFs()}}
 }
 
 // NewRecommended returns [FS] with recommended and user-defined options.
@@ -93,7 +123,13 @@ func NewRecommendedReal(options ...Option) FS {
 
 type aferoFS struct{ a afero.Fs }
 
-var _ FS = (*aferoFS)(nil)
+var (
+	_ FS        = (*aferoFS)(nil)
+	_ ChmodFS   = (*aferoFS)(nil)
+	_ ChtimesFS = (*aferoFS)(nil)
+	_ LockFS    = (*aferoFS)(nil)
+	_ WatchFS   = (*aferoFS)(nil)
+)
 
 type fsOnly struct{ fs.FS }
 
@@ -129,44 +165,39 @@ func (a *aferoFS) RemoveAll(path string) error {
 	return a.a.RemoveAll(filepath.Clean(path))
 }
 
-type mapFS struct{ *aferoFS }
-
-var _ ListFS = (*mapFS)(nil)
-
-type pathFile struct {
-	fs.FileInfo
-	path string
+func (a *aferoFS) Chmod(name string, mode fs.FileMode) error {
+	return a.a.Chmod(filepath.Clean(name), mode)
 }
 
-func (f pathFile) Name() string {
-	return f.path
+func (a *aferoFS) Chtimes(name string, atime, mtime time.Time) error {
+	return a.a.Chtimes(filepath.Clean(name), atime, mtime)
 }
 
-// List returns all the files in the FS. This function should
-// be used only in tests, do not use this in production.
-func (m *mapFS) List() ([]fs.FileInfo, error) {
-	var files []fs.FileInfo
-
-	aferoFs := m.a
-	// error ignore is intended, we only care about getting all valid files
-	walkFn := func(path string, info fs.FileInfo, _ error) error {
-		if info == nil || info.IsDir() {
-			return nil
-		}
+// Lock uses flock, it is supported only for OS file system.
+func (a *aferoFS) Lock(ctx context.Context, name string, mode LockMode) (Unlocker, error) {
+	if _, ok := a.a.(*afero.OsFs); !ok {
+		return nil, &fs.PathError{Op: "lock", Path: name, Err: errors.ErrUnsupported}
+	}
+	return flock(ctx, filepath.Clean(name), mode)
+}
 
-		files = append(files, pathFile{FileInfo: info, path: path})
-		return nil
+// Watch uses inotify, it is supported only for OS file system on Linux.
+func (a *aferoFS) Watch(ctx context.Context, name string, recursive bool) (<-chan WatchEvent, error) {
+	if _, ok := a.a.(*afero.OsFs); !ok {
+		return nil, &fs.PathError{Op: "watch", Path: name, Err: errors.ErrUnsupported}
 	}
-	// we run walk from two roots to get all the files,
-	// because MapFS doesn't provide a single root
-	_ = afero.Walk(aferoFs, ".", walkFn)
-	_ = afero.Walk(aferoFs, "/", walkFn)
+	return watchOS(ctx, filepath.Clean(name), recursive)
+}
 
-	slices.SortFunc(files, func(l, r fs.FileInfo) int {
-		return strings.Compare(l.Name(), r.Name())
-	})
+type mapFS struct {
+	*aferoFS
+	locks    memLocks
+	watchers memWatchers
+}
 
-	return files, nil
+// Lock uses in-process locks.
+func (m *mapFS) Lock(ctx context.Context, name string, mode LockMode) (Unlocker, error) {
+	return m.locks.lock(ctx, name, mode)
 }
 
 // ReadFile is an alias for [fs.ReadFile].
@@ -207,6 +238,8 @@ func CopyFS(dst WriteOnlyFS, src fs.FS) error {
 				return err
 			}
 
+			defer closeWithErr(w, &rErr)
+
 			if _, err = io.Copy(w, r); err != nil {
 				return &fs.PathError{Op: "Copy", Path: path, Err: err}
 			}
@@ -217,6 +250,37 @@ func CopyFS(dst WriteOnlyFS, src fs.FS) error {
 	})
 }
 
+// copyFile streams the named file from src to dst, creating or truncating it
+// with the permissions of src.
+func copyFile(dst WriteOnlyFS, src fs.FS, name string) (rErr error) {
+	r, err := src.Open(name)
+	if err != nil {
+		return err
+	}
+	defer closeWithErr(r, &rErr)
+
+	info, err := r.Stat()
+	if err != nil {
+		return err
+	}
+	w, err := dst.OpenFile(name, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	defer closeWithErr(w, &rErr)
+
+	if _, err = io.Copy(w, r); err != nil {
+		return &fs.PathError{Op: "copy", Path: name, Err: err}
+	}
+	return nil
+}
+
+// tempName returns a unique name of a temporary file next to the named one,
+// so that concurrent writes of the same file do not collide.
+func tempName(name string) string {
+	return fmt.Sprintf("%s.%016x.tmp", name, rand.Uint64())
+}
+
 func closeWithErr(r io.Closer, e *error) {
 	cerr := r.Close()
 	switch {
@@ -228,3 +292,10 @@ func closeWithErr(r io.Closer, e *error) {
 		*e = fmt.Errorf("close error: %w: while returning error %w", cerr, *e)
 	}
 }
+
+func (a *aferoFS) String() string {
+	if _, ok := a.a.(*afero.OsFs); ok {
+		return "RealFS"
+	}
+	return "MapFS"
+}
@@ -0,0 +1,56 @@
+//go:build ignore
+
+// This program generates capabilities_gen.go: a struct type for every
+// combination of the optional interfaces, used by narrow. Run it with
+// go generate after adding an optional interface to capabilityNames.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"strings"
+)
+
+// capabilityNames are the optional interfaces in the order of the capability
+// bits, each of them is NAMEFS with the capNAME bit.
+var capabilityNames = []string{"Chmod", "Chtimes", "Lock", "Watch"}
+
+func main() {
+	var b bytes.Buffer
+	b.WriteString(`// Code generated by gen_capabilities.go. DO NOT EDIT.
+
+package fs
+
+// narrow returns the layer with only the optional interfaces in c.
+func narrow(l capabilities, c capability) FS {
+	s := shell{l}
+	switch c {
+	case 0:
+		return s
+`)
+	all := 1<<len(capabilityNames) - 1
+	for c := 1; c < all; c++ {
+		var bits, fields, values []string
+		for i, name := range capabilityNames {
+			if c&(1<<i) != 0 {
+				bits = append(bits, "cap"+name)
+				fields = append(fields, name+"FS")
+				values = append(values, "l")
+			}
+		}
+		fmt.Fprintf(&b, "\tcase %s:\n\t\treturn struct {\n\t\t\tshell\n\t\t\t%s\n\t\t}{s, %s}\n",
+			strings.Join(bits, " | "), strings.Join(fields, "\n\t\t\t"), strings.Join(values, ", "))
+	}
+	b.WriteString("\tdefault:\n\t\treturn l\n\t}\n}\n")
+
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err = os.WriteFile("capabilities_gen.go", src, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
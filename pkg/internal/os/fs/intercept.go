@@ -0,0 +1,250 @@
+package fs
+
+import (
+	"errors"
+	"io/fs"
+	"time"
+
+	"go.mws.cloud/util-toolset/pkg/utils/consterr"
+)
+
+// ErrSkip can be returned by BeforeWrite and OnRemove hooks of [Interceptor]
+// to skip the operation, which then succeeds without calling the wrapped [FS].
+// OpenFile can not be skipped, it fails with ErrSkip.
+const ErrSkip = consterr.Error("skip operation")
+
+// CallOp is an operation of [FS] passed to the hooks of [Interceptor].
+type CallOp int
+
+const (
+	// OpOpen is Open, including the ones made by [fs.Stat] and [fs.ReadFile].
+	OpOpen CallOp = iota
+	// OpReadDir is ReadDir.
+	OpReadDir
+	// OpOpenFile is OpenFile.
+	OpOpenFile
+	// OpMkdirAll is MkdirAll.
+	OpMkdirAll
+	// OpWriteFile is WriteFile.
+	OpWriteFile
+	// OpRename is Rename.
+	OpRename
+	// OpRemove is Remove.
+	OpRemove
+	// OpRemoveAll is RemoveAll.
+	OpRemoveAll
+	// OpChmod is [Chmod].
+	OpChmod
+	// OpChtimes is [Chtimes].
+	OpChtimes
+)
+
+var callOpNames = []string{"open", "readdir", "openfile", "mkdirall", "writefile", "rename", "remove", "removeall", "chmod", "chtimes"}
+
+func (op CallOp) String() string {
+	if op < 0 || int(op) >= len(callOpNames) {
+		return "unknown"
+	}
+	return callOpNames[op]
+}
+
+// Call is an operation of [FS] passed to the hooks of [Interceptor]. The hooks
+// may change the fields, which are then passed to the wrapped [FS].
+type Call struct {
+	Op CallOp
+	// Path is the name of the file, the source for [OpRename].
+	Path string
+	// NewPath is the destination for [OpRename].
+	NewPath string
+	// Data is the content for [OpWriteFile].
+	Data []byte
+	// Flag is the flag for [OpOpenFile].
+	Flag int
+	// Perm is the permissions for [OpOpenFile], [OpMkdirAll], [OpWriteFile]
+	// and the mode for [OpChmod].
+	Perm fs.FileMode
+	// Atime and Mtime are the times for [OpChtimes].
+	Atime, Mtime time.Time
+}
+
+// Interceptor is a set of hooks for [WithInterceptor], nil hooks are skipped.
+// A hook returning an error rejects the operation, the error is returned by it.
+type Interceptor struct {
+	// OnOpen is called before the read operations: [OpOpen], [OpReadDir]
+	// and [OpOpenFile] without write flags.
+	OnOpen func(c *Call) error
+	// BeforeWrite is called before the write operations: [OpOpenFile] with
+	// write flags, [OpMkdirAll], [OpWriteFile], [OpRename], [OpChmod] and
+	// [OpChtimes].
+	BeforeWrite func(c *Call) error
+	// OnRemove is called before [OpRemove] and [OpRemoveAll].
+	OnRemove func(c *Call) error
+	// AfterWrite is called after the operations passed to BeforeWrite and
+	// OnRemove with their result, including the skipped ones, and returns the
+	// result to report. For [OpOpenFile] it is called when the file is closed,
+	// or if it is not opened.
+	AfterWrite func(c *Call, err error) error
+}
+
+type intercepted struct {
+	wrapped
+	i Interceptor
+}
+
+// WithInterceptor is an option for [NewFS] that wraps the [FS] so that the hooks
+// of the interceptor are called around its operations. It is the way to write
+// a custom option which changes or rejects a few operations, e.g. to make
+// a file system which refuses to write outside of "out":
+//
+//	fs.WithInterceptor(fs.Interceptor{
+//		BeforeWrite: func(c *fs.Call) error {
+//			if !strings.HasPrefix(c.Path, "out/") {
+//				return &iofs.PathError{Op: c.Op.String(), Path: c.Path, Err: iofs.ErrPermission}
+//			}
+//			return nil
+//		},
+//	})
+//
+// The optional interfaces of this package are implemented only if the wrapped
+// [FS] implements them: Chmod and Chtimes are intercepted, Lock and Watch are
+// forwarded as is.
+func WithInterceptor(i Interceptor) Option {
+	return func(fs FS) FS {
+		return &intercepted{wrapped: wrapped{fs}, i: i}
+	}
+}
+
+func (x *intercepted) Open(name string) (fs.File, error) {
+	c := &Call{Op: OpOpen, Path: name}
+	if err := call(x.i.OnOpen, c); err != nil {
+		return nil, err
+	}
+	return x.FS.Open(c.Path)
+}
+
+func (x *intercepted) ReadDir(name string) ([]fs.DirEntry, error) {
+	c := &Call{Op: OpReadDir, Path: name}
+	if err := call(x.i.OnOpen, c); err != nil {
+		return nil, err
+	}
+	return x.FS.ReadDir(c.Path)
+}
+
+func (x *intercepted) OpenFile(name string, flag int, perm fs.FileMode) (WritableFile, error) {
+	c := &Call{Op: OpOpenFile, Path: name, Flag: flag, Perm: perm}
+	if flag&writeFlags == 0 {
+		if err := call(x.i.OnOpen, c); err != nil {
+			return nil, err
+		}
+		return x.FS.OpenFile(c.Path, c.Flag, c.Perm)
+	}
+
+	if err := call(x.i.BeforeWrite, c); err != nil {
+		return nil, x.afterOpen(c, err)
+	}
+	f, err := x.FS.OpenFile(c.Path, c.Flag, c.Perm)
+	if err != nil {
+		return nil, x.afterOpen(c, err)
+	}
+	return &interceptedFile{WritableFile: f, after: func(err error) error {
+		return x.after(c, err)
+	}}, nil
+}
+
+func (x *intercepted) MkdirAll(name string, perm fs.FileMode) error {
+	c := &Call{Op: OpMkdirAll, Path: name, Perm: perm}
+	return x.write(x.i.BeforeWrite, c, func() error {
+		return x.FS.MkdirAll(c.Path, c.Perm)
+	})
+}
+
+func (x *intercepted) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	c := &Call{Op: OpWriteFile, Path: name, Data: data, Perm: perm}
+	return x.write(x.i.BeforeWrite, c, func() error {
+		return x.FS.WriteFile(c.Path, c.Data, c.Perm)
+	})
+}
+
+func (x *intercepted) Rename(src, dst string) error {
+	c := &Call{Op: OpRename, Path: src, NewPath: dst}
+	return x.write(x.i.BeforeWrite, c, func() error {
+		return x.FS.Rename(c.Path, c.NewPath)
+	})
+}
+
+func (x *intercepted) Remove(name string) error {
+	c := &Call{Op: OpRemove, Path: name}
+	return x.write(x.i.OnRemove, c, func() error {
+		return x.FS.Remove(c.Path)
+	})
+}
+
+func (x *intercepted) RemoveAll(name string) error {
+	c := &Call{Op: OpRemoveAll, Path: name}
+	return x.write(x.i.OnRemove, c, func() error {
+		return x.FS.RemoveAll(c.Path)
+	})
+}
+
+func (x *intercepted) Chmod(name string, mode fs.FileMode) error {
+	c := &Call{Op: OpChmod, Path: name, Perm: mode}
+	return x.write(x.i.BeforeWrite, c, func() error {
+		return x.wrapped.Chmod(c.Path, c.Perm)
+	})
+}
+
+func (x *intercepted) Chtimes(name string, atime, mtime time.Time) error {
+	c := &Call{Op: OpChtimes, Path: name, Atime: atime, Mtime: mtime}
+	return x.write(x.i.BeforeWrite, c, func() error {
+		return x.wrapped.Chtimes(c.Path, c.Atime, c.Mtime)
+	})
+}
+
+// write runs the operation between the hooks, ErrSkip skips it.
+func (x *intercepted) write(before func(*Call) error, c *Call, op func() error) error {
+	err := call(before, c)
+	switch {
+	case errors.Is(err, ErrSkip):
+		err = nil
+	case err == nil:
+		err = op()
+	}
+	return x.after(c, err)
+}
+
+// afterOpen returns the error of OpenFile which is not opened. It is never nil,
+// as there is no file to return, so ErrSkip fails OpenFile.
+func (x *intercepted) afterOpen(c *Call, err error) error {
+	if afterErr := x.after(c, err); afterErr != nil {
+		return afterErr
+	}
+	return err
+}
+
+func (x *intercepted) after(c *Call, err error) error {
+	if x.i.AfterWrite == nil {
+		return err
+	}
+	return x.i.AfterWrite(c, err)
+}
+
+func call(hook func(*Call) error, c *Call) error {
+	if hook == nil {
+		return nil
+	}
+	return hook(c)
+}
+
+// interceptedFile calls after with the result of Close.
+type interceptedFile struct {
+	WritableFile
+	after func(err error) error
+}
+
+func (f *interceptedFile) Close() error {
+	return f.after(f.WritableFile.Close())
+}
+
+func (*intercepted) String() string {
+	return "WithInterceptor()"
+}
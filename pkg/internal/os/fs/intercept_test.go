@@ -0,0 +1,146 @@
+package fs_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	iofs "io/fs"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"go.mws.cloud/util-toolset/pkg/internal/os/fs"
+	fsmock "go.mws.cloud/util-toolset/pkg/internal/os/fs/mock"
+)
+
+func TestInterceptor(t *testing.T) {
+	base := fs.NewFS(fs.NewMapFS(), fs.WithDirCreate(os.ModePerm))
+
+	var log []string
+	f := fs.NewFS(base, fs.WithInterceptor(fs.Interceptor{
+		OnOpen: func(c *fs.Call) error {
+			log = append(log, c.Op.String()+" "+c.Path)
+			return nil
+		},
+		BeforeWrite: func(c *fs.Call) error {
+			if strings.HasPrefix(c.Path, "ro/") {
+				return &iofs.PathError{Op: c.Op.String(), Path: c.Path, Err: iofs.ErrPermission}
+			}
+			c.Path = "out/" + c.Path
+			if c.Op == fs.OpRename {
+				c.NewPath = "out/" + c.NewPath
+			}
+			c.Data = bytes.ToUpper(c.Data)
+			return nil
+		},
+		OnRemove: func(c *fs.Call) error {
+			if c.Op == fs.OpRemoveAll {
+				return fs.ErrSkip
+			}
+			c.Path = "out/" + c.Path
+			return nil
+		},
+		AfterWrite: func(c *fs.Call, err error) error {
+			log = append(log, c.Op.String()+" "+c.Path)
+			return err
+		},
+	}))
+
+	require.NoError(t, f.WriteFile("a.txt", []byte("a"), 0o644))
+	require.Equal(t, "A", readString(t, base, "out/a.txt"))
+	require.ErrorIs(t, f.WriteFile("ro/a.txt", nil, 0o644), iofs.ErrPermission)
+
+	w, err := f.OpenFile("b.txt", os.O_CREATE|os.O_WRONLY, 0o644)
+	require.NoError(t, err)
+	_, err = w.WriteString("b")
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	require.Equal(t, "b", readString(t, f, "out/b.txt"))
+
+	require.NoError(t, f.Rename("b.txt", "c.txt"))
+	require.NoError(t, fs.Chmod(f, "c.txt", 0o600))
+	require.NoError(t, fs.Chtimes(f, "c.txt", time.Now(), time.Now()))
+	require.NoError(t, f.RemoveAll("c.txt"))
+	require.NoError(t, f.Remove("c.txt"))
+	_, err = f.ReadDir("out")
+	require.NoError(t, err)
+
+	require.Equal(t, []string{
+		"writefile out/a.txt",
+		"writefile ro/a.txt",
+		"openfile out/b.txt",
+		"open out/b.txt",
+		"rename out/b.txt",
+		"chmod out/c.txt",
+		"chtimes out/c.txt",
+		"removeall c.txt",
+		"remove out/c.txt",
+		"readdir out",
+	}, log)
+
+	files, err := fs.List(base)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+}
+
+func TestInterceptorSkipOpenFile(t *testing.T) {
+	f := fs.NewFS(fs.NewMapFS(), fs.WithInterceptor(fs.Interceptor{
+		BeforeWrite: func(*fs.Call) error {
+			return fs.ErrSkip
+		},
+	}))
+
+	require.NoError(t, f.WriteFile("a.txt", nil, 0o644))
+	_, err := f.OpenFile("a.txt", os.O_CREATE|os.O_WRONLY, 0o644)
+	require.ErrorIs(t, err, fs.ErrSkip)
+
+	files, err := fs.List(f)
+	require.NoError(t, err)
+	require.Empty(t, files)
+}
+
+func TestInterceptorForwards(t *testing.T) {
+	errFailed := errors.New("failed")
+	f := fs.NewFS(fs.NewMapFS(), fs.WithInterceptor(fs.Interceptor{
+		AfterWrite: func(_ *fs.Call, err error) error {
+			return errors.Join(err, errFailed)
+		},
+	}))
+	require.ErrorIs(t, f.MkdirAll("dir", os.ModePerm), errFailed)
+
+	u, err := fs.Lock(context.Background(), f, "lock", fs.LockExclusive)
+	require.NoError(t, err)
+	require.NoError(t, u.Unlock())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_, err = fs.Watch(ctx, f, "dir")
+	require.NoError(t, err)
+}
+
+func TestInterceptorCapabilities(t *testing.T) {
+	f := fs.NewFS(fs.NewMapFS(), fs.WithInterceptor(fs.Interceptor{}))
+	require.Implements(t, (*fs.ChmodFS)(nil), f)
+	require.Implements(t, (*fs.LockFS)(nil), f)
+	require.Implements(t, (*fs.WatchFS)(nil), f)
+	require.Equal(t, "WithInterceptor()\nMapFS\n", fs.Describe(f))
+
+	var ops []string
+	plain := fs.NewFS(fsmock.NewMockFS(gomock.NewController(t)), fs.WithInterceptor(fs.Interceptor{
+		BeforeWrite: func(c *fs.Call) error {
+			ops = append(ops, c.Op.String())
+			return nil
+		},
+	}))
+	_, ok := plain.(fs.ChmodFS)
+	require.False(t, ok)
+	_, ok = plain.(fs.LockFS)
+	require.False(t, ok)
+	require.ErrorIs(t, fs.Chmod(plain, "a", 0o600), errors.ErrUnsupported)
+	require.Empty(t, ops)
+	require.NoError(t, fs.Validate(fs.NewFS(plain, fs.WithInterceptor(fs.Interceptor{}))))
+}
@@ -0,0 +1,179 @@
+package fs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"time"
+
+	"go.mws.cloud/util-toolset/pkg/utils/consterr"
+)
+
+// ErrReadOnly is returned by write operations of [FS] implementations which
+// cannot be modified, e.g. [FS] returned by [NewReadOnlyFS].
+const ErrReadOnly = consterr.Error("read-only file system")
+
+const writeFlags = os.O_WRONLY | os.O_RDWR | os.O_APPEND | os.O_CREATE | os.O_TRUNC
+
+type readOnlyFS struct{ fsys fs.FS }
+
+var _ FS = (*readOnlyFS)(nil)
+
+// NewReadOnlyFS returns [FS] built on top of any [fs.FS], e.g. [embed.FS],
+// [testing/fstest.MapFS] or [os.DirFS]. All the write operations fail with
+// [fs.PathError] wrapping [ErrReadOnly].
+//
+// Relative and absolute paths are treated the same way, so the result can be
+// mounted with [NewMountFS] or used as a base layer of [NewOverlayFS].
+func NewReadOnlyFS(fsys fs.FS) FS {
+	return &readOnlyFS{fsys: fsys}
+}
+
+func (r *readOnlyFS) Open(name string) (fs.File, error) {
+	return r.fsys.Open(cleanRelPath(name))
+}
+
+func (r *readOnlyFS) OpenFile(name string, flag int, _ fs.FileMode) (WritableFile, error) {
+	if flag&writeFlags != 0 {
+		return nil, &fs.PathError{Op: "openfile", Path: name, Err: ErrReadOnly}
+	}
+
+	f, err := r.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &readOnlyFile{File: f, name: name}, nil
+}
+
+func (r *readOnlyFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return fs.ReadDir(r.fsys, cleanRelPath(name))
+}
+
+func (*readOnlyFS) MkdirAll(path string, _ fs.FileMode) error {
+	return &fs.PathError{Op: "mkdir", Path: path, Err: ErrReadOnly}
+}
+
+func (*readOnlyFS) WriteFile(name string, _ []byte, _ fs.FileMode) error {
+	return &fs.PathError{Op: "write_file", Path: name, Err: ErrReadOnly}
+}
+
+func (*readOnlyFS) Rename(src, dst string) error {
+	return &os.LinkError{Op: "rename", Old: src, New: dst, Err: ErrReadOnly}
+}
+
+func (*readOnlyFS) Remove(name string) error {
+	return &fs.PathError{Op: "remove", Path: name, Err: ErrReadOnly}
+}
+
+func (*readOnlyFS) RemoveAll(path string) error {
+	return &fs.PathError{Op: "remove_all", Path: path, Err: ErrReadOnly}
+}
+
+func (*readOnlyFS) Chmod(name string, _ fs.FileMode) error {
+	return &fs.PathError{Op: "chmod", Path: name, Err: ErrReadOnly}
+}
+
+func (*readOnlyFS) Chtimes(name string, _, _ time.Time) error {
+	return &fs.PathError{Op: "chtimes", Path: name, Err: ErrReadOnly}
+}
+
+func (*readOnlyFS) Lock(_ context.Context, name string, _ LockMode) (Unlocker, error) {
+	return nil, &fs.PathError{Op: "lock", Path: name, Err: ErrReadOnly}
+}
+
+// Watch is supported if the wrapped [fs.FS] implements [WatchFS].
+func (r *readOnlyFS) Watch(ctx context.Context, name string, recursive bool) (<-chan WatchEvent, error) {
+	if w, ok := r.fsys.(WatchFS); ok {
+		return w.Watch(ctx, name, recursive)
+	}
+	return nil, &fs.PathError{Op: "watch", Path: name, Err: errors.ErrUnsupported}
+}
+
+// IOFS is an [fs.FS] implementing all the optional interfaces of the io/fs package.
+type IOFS interface {
+	fs.ReadDirFS
+	fs.ReadFileFS
+	fs.StatFS
+	fs.SubFS
+	fs.GlobFS
+}
+
+type ioFS struct{ f ReadOnlyFS }
+
+var _ IOFS = (*ioFS)(nil)
+
+// NewIOFS exposes f as [IOFS], so it can be passed wherever an [fs.FS] is
+// expected. Unlike [FS], it accepts only paths valid by [fs.ValidPath].
+// Sub returns a view built with [Sub].
+func NewIOFS(f ReadOnlyFS) IOFS {
+	return &ioFS{f: f}
+}
+
+func (i *ioFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	return i.f.Open(name)
+}
+
+func (i *ioFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	return i.f.ReadDir(name)
+}
+
+func (i *ioFS) ReadFile(name string) (_ []byte, rErr error) {
+	f, err := i.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer closeWithErr(f, &rErr)
+
+	return io.ReadAll(f)
+}
+
+func (i *ioFS) Stat(name string) (_ fs.FileInfo, rErr error) {
+	f, err := i.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer closeWithErr(f, &rErr)
+
+	return f.Stat()
+}
+
+func (i *ioFS) Sub(dir string) (fs.FS, error) {
+	sub, err := Sub(asFS(i.f), dir)
+	if err != nil {
+		return nil, err
+	}
+	return &ioFS{f: sub}, nil
+}
+
+func (i *ioFS) Glob(pattern string) ([]string, error) {
+	// The wrapper hides Glob, otherwise fs.Glob would call it back.
+	return fs.Glob(readDirOnly{i}, pattern)
+}
+
+type readDirOnly struct{ fs.ReadDirFS }
+
+// asFS returns f as [FS], read-only file systems fail on write operations.
+func asFS(f ReadOnlyFS) FS {
+	if full, ok := f.(FS); ok {
+		return full
+	}
+	return &readOnlyFS{fsys: f}
+}
+
+// Unwrap returns the wrapped file system, if it is [FS].
+func (r *readOnlyFS) Unwrap() FS {
+	f, _ := r.fsys.(FS)
+	return f
+}
+
+func (*readOnlyFS) String() string {
+	return "ReadOnlyFS"
+}
@@ -0,0 +1,76 @@
+package fs_test
+
+import (
+	iofs "io/fs"
+	"os"
+	"testing"
+	stdfstest "testing/fstest"
+
+	"github.com/stretchr/testify/require"
+
+	"go.mws.cloud/util-toolset/pkg/internal/os/fs"
+)
+
+func TestReadOnlyFS(t *testing.T) {
+	ro := fs.NewReadOnlyFS(stdfstest.MapFS{
+		"a.txt":     {Data: []byte("a")},
+		"dir/b.txt": {Data: []byte("b")},
+	})
+
+	data, err := fs.ReadFile(ro, "/dir/b.txt")
+	require.NoError(t, err)
+	require.Equal(t, []byte("b"), data)
+	require.Equal(t, []string{"a.txt", "dir/b.txt"}, collectElements(t, ro))
+
+	f, err := ro.OpenFile("a.txt", os.O_RDONLY, 0)
+	require.NoError(t, err)
+	_, err = f.WriteString("x")
+	require.ErrorIs(t, err, fs.ErrReadOnly)
+	require.NoError(t, f.Close())
+
+	_, err = ro.OpenFile("a.txt", os.O_WRONLY, 0)
+	require.ErrorIs(t, err, fs.ErrReadOnly)
+	require.ErrorIs(t, ro.WriteFile("a.txt", nil, 0o644), fs.ErrReadOnly)
+	require.ErrorIs(t, ro.MkdirAll("dir", os.ModePerm), fs.ErrReadOnly)
+	require.ErrorIs(t, ro.Rename("a.txt", "c.txt"), fs.ErrReadOnly)
+	require.ErrorIs(t, ro.Remove("a.txt"), fs.ErrReadOnly)
+	require.ErrorIs(t, ro.RemoveAll("dir"), fs.ErrReadOnly)
+}
+
+func TestReadOnlyFSMount(t *testing.T) {
+	out := fs.NewMapFS()
+	mount := fs.NewMountFS(map[string]fs.FS{
+		"/templates": fs.NewReadOnlyFS(stdfstest.MapFS{"main.tmpl": {Data: []byte("tmpl")}}),
+		"/out":       out,
+	})
+
+	data, err := fs.ReadFile(mount, "/templates/main.tmpl")
+	require.NoError(t, err)
+	require.Equal(t, []byte("tmpl"), data)
+
+	err = mount.Rename("/templates/main.tmpl", "/out/main.tmpl")
+	require.ErrorIs(t, err, fs.ErrCrossMount)
+	require.ErrorIs(t, err, fs.ErrReadOnly)
+	require.Empty(t, collectElements(t, out))
+}
+
+func TestIOFS(t *testing.T) {
+	f := fs.NewFS(fs.NewRealFS(), fs.WithBaseDir(t.TempDir()), fs.WithDirCreate(os.ModePerm))
+	require.NoError(t, f.WriteFile("a.txt", []byte("a"), 0o644))
+	require.NoError(t, f.WriteFile("dir/b.txt", []byte("b"), 0o644))
+	require.NoError(t, f.WriteFile("dir/sub/c.txt", []byte("c"), 0o644))
+
+	iofsys := fs.NewIOFS(f)
+	require.NoError(t, stdfstest.TestFS(iofsys, "a.txt", "dir/b.txt", "dir/sub/c.txt"))
+
+	sub, err := iofs.Sub(iofsys, "dir")
+	require.NoError(t, err)
+	require.NoError(t, stdfstest.TestFS(sub, "b.txt", "sub/c.txt"))
+
+	matches, err := iofs.Glob(iofsys, "dir/*.txt")
+	require.NoError(t, err)
+	require.Equal(t, []string{"dir/b.txt"}, matches)
+
+	_, err = iofsys.Open("/a.txt")
+	require.ErrorIs(t, err, iofs.ErrInvalid)
+}
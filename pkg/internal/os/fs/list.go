@@ -0,0 +1,109 @@
+package fs
+
+import (
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+// ListOption is an option for [List] and [ListDir].
+type ListOption func(*listConfig)
+
+type listConfig struct {
+	dirs bool
+}
+
+// WithListDirs makes [List] and [ListDir] return directories along with
+// files. The root directory itself is never returned.
+func WithListDirs() ListOption {
+	return func(c *listConfig) {
+		c.dirs = true
+	}
+}
+
+// pathFile is [fs.FileInfo] which Name returns the full path of the file.
+type pathFile struct {
+	fs.FileInfo
+	path string
+}
+
+func (f pathFile) Name() string {
+	return f.path
+}
+
+// List returns all the files of f sorted by path, see [ListDir].
+func List(f ReadOnlyFS, options ...ListOption) ([]fs.FileInfo, error) {
+	return ListDir(f, ".", options...)
+}
+
+// ListDir returns all the files in the root directory of f and its
+// subdirectories, sorted by path. Name of every returned [fs.FileInfo] is the
+// path of the file in f, including root, as in [fs.WalkDir]. Only non-directory
+// entries are returned, unless [WithListDirs] is passed.
+//
+// It works over ReadDir, so it can be used with any [ReadOnlyFS]. The whole
+// [FS] returned by [NewMapFS] is listed including files written with absolute
+// paths, as it has no single root.
+func ListDir(f ReadOnlyFS, root string, options ...ListOption) ([]fs.FileInfo, error) {
+	var cfg listConfig
+	for _, o := range options {
+		o(&cfg)
+	}
+
+	var files []fs.FileInfo
+	if m, ok := f.(*mapFS); ok && path.Clean(root) == "." {
+		files = m.list(cfg)
+	} else {
+		var err error
+		if files, err = listDir(f, root, cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	slices.SortFunc(files, func(l, r fs.FileInfo) int {
+		return strings.Compare(l.Name(), r.Name())
+	})
+	return files, nil
+}
+
+func listDir(f ReadOnlyFS, root string, cfg listConfig) ([]fs.FileInfo, error) {
+	var files []fs.FileInfo
+	err := fs.WalkDir(f, root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && (p == root || !cfg.dirs) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		files = append(files, pathFile{FileInfo: info, path: p})
+		return nil
+	})
+	return files, err
+}
+
+// list returns all the files of the map file system. Relative and absolute
+// paths are stored separately in it, so there is no single root and the walk
+// runs from both "." and "/". Errors are ignored, as only valid files matter.
+func (m *mapFS) list(cfg listConfig) []fs.FileInfo {
+	var files []fs.FileInfo
+	walkFn := func(p string, info fs.FileInfo, _ error) error {
+		if info == nil || info.IsDir() && (!cfg.dirs || p == "." || p == "/") {
+			return nil
+		}
+
+		files = append(files, pathFile{FileInfo: info, path: p})
+		return nil
+	}
+
+	_ = afero.Walk(m.a, ".", walkFn)
+	_ = afero.Walk(m.a, "/", walkFn)
+	return files
+}
@@ -0,0 +1,61 @@
+package fs_test
+
+import (
+	iofs "io/fs"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go.mws.cloud/util-toolset/pkg/internal/os/fs"
+	"go.mws.cloud/util-toolset/pkg/internal/testing/fstest"
+)
+
+func TestList(t *testing.T) {
+	fstest.RunFS(t, func(t *testing.T, f fs.FS) {
+		require.NoError(t, f.MkdirAll("dir/sub", os.ModePerm))
+		require.NoError(t, f.MkdirAll("empty", os.ModePerm))
+		require.NoError(t, f.WriteFile("dir/sub/b.txt", []byte("bb"), 0o644))
+		require.NoError(t, f.WriteFile("dir/a.txt", []byte("a"), 0o644))
+		require.NoError(t, f.WriteFile("z.txt", nil, 0o644))
+
+		files, err := fs.List(f)
+		require.NoError(t, err)
+		require.Equal(t, []string{"dir/a.txt", "dir/sub/b.txt", "z.txt"}, names(files))
+		require.EqualValues(t, 2, files[1].Size())
+
+		files, err = fs.List(f, fs.WithListDirs())
+		require.NoError(t, err)
+		require.Equal(t, []string{"dir", "dir/a.txt", "dir/sub", "dir/sub/b.txt", "empty", "z.txt"}, names(files))
+		require.True(t, files[0].IsDir())
+
+		files, err = fs.ListDir(f, "dir", fs.WithListDirs())
+		require.NoError(t, err)
+		require.Equal(t, []string{"dir/a.txt", "dir/sub", "dir/sub/b.txt"}, names(files))
+
+		files, err = fs.ListDir(f, "empty")
+		require.NoError(t, err)
+		require.Empty(t, files)
+
+		_, err = fs.ListDir(f, "missing")
+		require.ErrorIs(t, err, iofs.ErrNotExist)
+	})
+}
+
+func TestListMapFSAbsolute(t *testing.T) {
+	f := fs.NewMapFS()
+	require.NoError(t, f.WriteFile("/abs/a.txt", nil, 0o644))
+	require.NoError(t, f.WriteFile("rel.txt", nil, 0o644))
+
+	files, err := fs.List(f, fs.WithListDirs())
+	require.NoError(t, err)
+	require.Equal(t, []string{"/abs", "/abs/a.txt", "rel.txt"}, names(files))
+}
+
+func names(files []iofs.FileInfo) []string {
+	result := make([]string, len(files))
+	for i, f := range files {
+		result[i] = f.Name()
+	}
+	return result
+}
@@ -0,0 +1,273 @@
+package fs
+
+//go:generate mockgen -source=lock.go -destination=mock/mocklock.go
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mws.cloud/util-toolset/pkg/utils/consterr"
+)
+
+// ErrLocked is returned when a lock is not acquired before the context is
+// done, it is joined with the context error.
+const ErrLocked = consterr.Error("file is locked")
+
+// lockPollInterval is the interval between attempts to acquire a lock held
+// by another process.
+const lockPollInterval = 10 * time.Millisecond
+
+// LockMode is a mode of an advisory lock.
+type LockMode int
+
+const (
+	// LockExclusive is a lock which can be held by a single owner.
+	LockExclusive LockMode = iota
+	// LockShared is a lock which can be held by several owners at once,
+	// while no one holds [LockExclusive].
+	LockShared
+)
+
+// Unlocker releases a lock acquired with [LockFS].
+type Unlocker interface {
+	Unlock() error
+}
+
+// LockFS is an optional interface that can be implemented by [FS] implementations
+// which support advisory locks. The named file is the lock, it is created if it
+// does not exist and it is not removed on unlock. Locks are advisory: they do not
+// prevent access to the file, only other locks of the same file.
+//
+// [FS] returned by [NewRealFS] uses flock on Unix, so the locks work across
+// processes and are released by the system, if the process dies. [FS] returned by
+// [NewMapFS] uses in-process locks. The options of this package forward locks.
+type LockFS interface {
+	// Lock acquires the lock, waiting until it is released by other owners
+	// or the context is done.
+	Lock(ctx context.Context, name string, mode LockMode) (Unlocker, error)
+}
+
+// Lock acquires the lock of the named file if f implements [LockFS].
+// If it does not, or locks are not supported by the platform, f is [FS], then
+// a PID file lock is used: the named file is created exclusively with the PID
+// of the process and removed on unlock. If the process which created the file
+// is not running anymore, the lock is stale and it is taken over. PID file
+// locks are always exclusive.
+//
+// If the lock is not acquired before the context is done, the error wraps
+// both [ErrLocked] and the context error.
+func Lock(ctx context.Context, f ReadOnlyFS, name string, mode LockMode) (Unlocker, error) {
+	if l, ok := f.(LockFS); ok {
+		u, err := l.Lock(ctx, name, mode)
+		if !errors.Is(err, errors.ErrUnsupported) {
+			return u, err
+		}
+	}
+
+	if w, ok := f.(FS); ok {
+		return lockPIDFile(ctx, w, name)
+	}
+	return nil, &fs.PathError{Op: "lock", Path: name, Err: errors.ErrUnsupported}
+}
+
+// waitLock waits for the next attempt to acquire the lock.
+func waitLock(ctx context.Context, name string) error {
+	t := time.NewTimer(lockPollInterval)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return &fs.PathError{Op: "lock", Path: name, Err: errors.Join(ErrLocked, ctx.Err())}
+	case <-t.C:
+		return nil
+	}
+}
+
+type unlockFunc func() error
+
+func (f unlockFunc) Unlock() error {
+	return f()
+}
+
+// lockPIDFile acquires the PID file lock, see [Lock].
+// Two processes taking over the same stale lock at once may both succeed,
+// as the check and the removal are not atomic.
+func lockPIDFile(ctx context.Context, f FS, name string) (Unlocker, error) {
+	pid := []byte(strconv.Itoa(os.Getpid()) + "\n")
+	for {
+		w, err := f.OpenFile(name, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if err == nil {
+			_, err = w.Write(pid)
+			if err = errors.Join(err, w.Close()); err != nil {
+				return nil, errors.Join(err, f.Remove(name))
+			}
+			return unlockFunc(func() error {
+				return f.Remove(name)
+			}), nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, err
+		}
+
+		if isStalePIDFile(f, name) {
+			if err := f.Remove(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return nil, err
+			}
+			continue
+		}
+		if err := waitLock(ctx, name); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// isStalePIDFile reports whether the PID file contains the PID of a process
+// which is not running. The file being written by another process is not stale.
+func isStalePIDFile(f FS, name string) bool {
+	data, err := fs.ReadFile(f, name)
+	if err != nil {
+		return false
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 {
+		return false
+	}
+	return !processAlive(pid)
+}
+
+// memLocks is the table of in-process locks used by [FS] returned by [NewMapFS].
+type memLocks struct {
+	mu    sync.Mutex
+	locks map[string]*memLock
+}
+
+// memLock is the state of a lock, released is closed and replaced every time
+// the lock is released to wake up the waiters.
+type memLock struct {
+	readers  int
+	writer   bool
+	released chan struct{}
+}
+
+func (m *memLocks) lock(ctx context.Context, name string, mode LockMode) (Unlocker, error) {
+	p := path.Clean(name)
+	for {
+		m.mu.Lock()
+		if m.locks == nil {
+			m.locks = map[string]*memLock{}
+		}
+		l, ok := m.locks[p]
+		if !ok {
+			l = &memLock{released: make(chan struct{})}
+			m.locks[p] = l
+		}
+
+		switch {
+		case mode == LockExclusive && !l.writer && l.readers == 0:
+			l.writer = true
+		case mode == LockShared && !l.writer:
+			l.readers++
+		default:
+			released := l.released
+			m.mu.Unlock()
+
+			select {
+			case <-ctx.Done():
+				return nil, &fs.PathError{Op: "lock", Path: name, Err: errors.Join(ErrLocked, ctx.Err())}
+			case <-released:
+			}
+			continue
+		}
+		m.mu.Unlock()
+
+		var once sync.Once
+		return unlockFunc(func() error {
+			once.Do(func() { m.unlock(p, mode) })
+			return nil
+		}), nil
+	}
+}
+
+func (m *memLocks) unlock(p string, mode LockMode) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	l := m.locks[p]
+	if mode == LockExclusive {
+		l.writer = false
+	} else {
+		l.readers--
+	}
+
+	close(l.released)
+	l.released = make(chan struct{})
+	if !l.writer && l.readers == 0 {
+		delete(m.locks, p)
+	}
+}
+
+type lockWrite struct {
+	wrapped
+	name    string
+	timeout time.Duration
+}
+
+// WithLock is an option for [NewFS] that wraps the [FS] so that WriteFile and
+// Rename are performed holding the exclusive lock of the named file, see [Lock].
+// Use the same lock file in all the processes writing to the same directory.
+// If the lock is not acquired within the timeout, the operation fails with
+// [ErrLocked]; zero timeout means waiting forever.
+//
+// The lock file is taken from the wrapped [FS], so it should be kept out of
+// the output, e.g. by applying the option after [WithBaseDir], so that the
+// lock file is outside of the base directory:
+//
+//	fs.NewFS(fs.NewRealFS(), fs.WithBaseDir("out"), fs.WithLock("out.lock", time.Minute))
+func WithLock(name string, timeout time.Duration) Option {
+	return func(fs FS) FS {
+		return &lockWrite{wrapped: wrapped{fs}, name: name, timeout: timeout}
+	}
+}
+
+func (l *lockWrite) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	return l.locked(func() error {
+		return l.FS.WriteFile(name, data, perm)
+	})
+}
+
+func (l *lockWrite) Rename(src, dst string) error {
+	return l.locked(func() error {
+		return l.FS.Rename(src, dst)
+	})
+}
+
+func (l *lockWrite) locked(op func() error) (rErr error) {
+	ctx := context.Background()
+	if l.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, l.timeout)
+		defer cancel()
+	}
+
+	u, err := Lock(ctx, l.FS, l.name, LockExclusive)
+	if err != nil {
+		return fmt.Errorf("acquiring write lock: %w", err)
+	}
+	defer func() {
+		rErr = errors.Join(rErr, u.Unlock())
+	}()
+
+	return op()
+}
+
+func (l *lockWrite) String() string {
+	return fmt.Sprintf("WithLock(%q, %s)", l.name, l.timeout)
+}
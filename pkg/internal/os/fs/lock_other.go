@@ -0,0 +1,25 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package fs
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+)
+
+// flock is not supported on this platform, so [Lock] uses PID file locks.
+func flock(_ context.Context, name string, _ LockMode) (Unlocker, error) {
+	return nil, &fs.PathError{Op: "lock", Path: name, Err: errors.ErrUnsupported}
+}
+
+// processAlive reports whether the process with the PID is running.
+func processAlive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	_ = p.Release()
+	return true
+}
@@ -0,0 +1,143 @@
+package fs_test
+
+import (
+	"context"
+	"errors"
+	iofs "io/fs"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"go.mws.cloud/util-toolset/pkg/internal/os/fs"
+	"go.mws.cloud/util-toolset/pkg/internal/testing/fstest"
+)
+
+// noLockFS hides LockFS of the wrapped FS.
+type noLockFS struct{ fs.FS }
+
+// blockedContext closes blocked when a lock waiter first waits on Done,
+// which it does only when the lock is held by another one.
+type blockedContext struct {
+	context.Context
+	once    sync.Once
+	blocked chan struct{}
+}
+
+func newBlockedContext() *blockedContext {
+	return &blockedContext{Context: context.Background(), blocked: make(chan struct{})}
+}
+
+func (c *blockedContext) Done() <-chan struct{} {
+	c.once.Do(func() { close(c.blocked) })
+	return c.Context.Done()
+}
+
+func lockWithTimeout(f fs.ReadOnlyFS, name string, mode fs.LockMode) (fs.Unlocker, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	return fs.Lock(ctx, f, name, mode)
+}
+
+func TestLock(t *testing.T) {
+	fstest.RunFS(t, func(t *testing.T, f fs.FS) {
+		l, err := fs.Lock(context.Background(), f, "x.lock", fs.LockExclusive)
+		require.NoError(t, err)
+
+		_, err = lockWithTimeout(f, "x.lock", fs.LockShared)
+		require.ErrorIs(t, err, fs.ErrLocked)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+
+		other, err := lockWithTimeout(f, "other.lock", fs.LockExclusive)
+		require.NoError(t, err)
+		require.NoError(t, other.Unlock())
+
+		ctx := newBlockedContext()
+		acquired := make(chan fs.Unlocker)
+		go func() {
+			l, err := fs.Lock(ctx, f, "x.lock", fs.LockExclusive)
+			if err == nil {
+				acquired <- l
+			}
+			close(acquired)
+		}()
+		<-ctx.blocked
+		require.NoError(t, l.Unlock())
+
+		l = <-acquired
+		require.NotNil(t, l)
+		require.NoError(t, l.Unlock())
+
+		shared1, err := lockWithTimeout(f, "x.lock", fs.LockShared)
+		require.NoError(t, err)
+		shared2, err := lockWithTimeout(f, "x.lock", fs.LockShared)
+		require.NoError(t, err)
+		_, err = lockWithTimeout(f, "x.lock", fs.LockExclusive)
+		require.ErrorIs(t, err, fs.ErrLocked)
+		require.NoError(t, shared1.Unlock())
+		require.NoError(t, shared2.Unlock())
+	}, fs.WithUnique(), fs.WithAtomicWrite())
+}
+
+func TestLockRealFSWritesPID(t *testing.T) {
+	dir := t.TempDir()
+	f := fs.NewFS(fs.NewRealFS(), fs.WithBaseDir(dir))
+
+	l, err := fs.Lock(context.Background(), f, "x.lock", fs.LockExclusive)
+	require.NoError(t, err)
+	data, err := fs.ReadFile(f, "x.lock")
+	require.NoError(t, err)
+	require.Equal(t, strconv.Itoa(os.Getpid())+"\n", string(data))
+	require.NoError(t, l.Unlock())
+}
+
+func TestLockPIDFile(t *testing.T) {
+	f := noLockFS{fs.NewFS(fs.NewRealFS(), fs.WithBaseDir(t.TempDir()))}
+
+	l, err := fs.Lock(context.Background(), f, "x.lock", fs.LockShared)
+	require.NoError(t, err)
+	data, err := fs.ReadFile(f, "x.lock")
+	require.NoError(t, err)
+	require.Equal(t, strconv.Itoa(os.Getpid())+"\n", string(data))
+
+	_, err = lockWithTimeout(f, "x.lock", fs.LockShared)
+	require.ErrorIs(t, err, fs.ErrLocked)
+
+	require.NoError(t, l.Unlock())
+	_, err = iofs.Stat(f, "x.lock")
+	require.ErrorIs(t, err, iofs.ErrNotExist)
+
+	// The lock of a process which is not running is stale.
+	cmd := exec.Command("true")
+	require.NoError(t, cmd.Run())
+	require.NoError(t, f.WriteFile("x.lock", []byte(strconv.Itoa(cmd.Process.Pid)), 0o644))
+
+	l, err = lockWithTimeout(f, "x.lock", fs.LockExclusive)
+	require.NoError(t, err)
+	require.NoError(t, l.Unlock())
+
+	_, err = fs.Lock(context.Background(), fs.NewIOFS(f), "x.lock", fs.LockExclusive)
+	require.ErrorIs(t, err, errors.ErrUnsupported)
+}
+
+func TestWithLock(t *testing.T) {
+	base := fs.NewFS(fs.NewRealFS(), fs.WithBaseDir(t.TempDir()))
+	f := fs.NewFS(base, fs.WithBaseDir("out"), fs.WithDirCreate(os.ModePerm), fs.WithLock("out.lock", 50*time.Millisecond))
+
+	require.NoError(t, f.WriteFile("a.txt", []byte("a"), 0o644))
+	require.NoError(t, f.Rename("a.txt", "b.txt"))
+
+	l, err := fs.Lock(context.Background(), base, "out.lock", fs.LockExclusive)
+	require.NoError(t, err)
+	require.ErrorIs(t, f.WriteFile("c.txt", nil, 0o644), fs.ErrLocked)
+	require.ErrorIs(t, f.Rename("b.txt", "c.txt"), fs.ErrLocked)
+	require.NoError(t, l.Unlock())
+
+	require.NoError(t, f.WriteFile("c.txt", nil, 0o644))
+	require.Equal(t, []string{"out/b.txt", "out/c.txt", "out.lock"}, collectElements(t, base))
+}
@@ -0,0 +1,60 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package fs
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// flock acquires the lock of the named file with flock(2), polling until
+// the lock is acquired or the context is done. The PID of the process is
+// written to the file while an exclusive lock is held, for diagnostics.
+func flock(ctx context.Context, name string, mode LockMode) (Unlocker, error) {
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	how := syscall.LOCK_EX
+	if mode == LockShared {
+		how = syscall.LOCK_SH
+	}
+
+	for {
+		err = syscall.Flock(int(f.Fd()), how|syscall.LOCK_NB)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, errors.Join(&fs.PathError{Op: "lock", Path: name, Err: err}, f.Close())
+		}
+		if err = waitLock(ctx, name); err != nil {
+			return nil, errors.Join(err, f.Close())
+		}
+	}
+
+	if mode == LockExclusive {
+		if err = f.Truncate(0); err == nil {
+			_, err = f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+		}
+		if err != nil {
+			return nil, errors.Join(err, f.Close())
+		}
+	}
+
+	return unlockFunc(func() error {
+		// Closing the file releases the lock.
+		return f.Close()
+	}), nil
+}
+
+// processAlive reports whether the process with the PID is running.
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
@@ -1,9 +1,9 @@
 // Code generated by MockGen. DO NOT EDIT.
-// Source: pkg/fs/fs.go
+// Source: fs.go
 //
 // Generated by this command:
 //
-//	mockgen -source=pkg/fs/fs.go -destination pkg/fs/mock/mockfs.go
+//	mockgen -source=fs.go -destination=mock/mockfs.go
 //
 
 // Package mock_fs is a generated GoMock package.
@@ -12,6 +12,7 @@ package mock_fs
 import (
 	fs0 "io/fs"
 	reflect "reflect"
+	time "time"
 
 	fs "go.mws.cloud/util-toolset/pkg/internal/os/fs"
 	gomock "go.uber.org/mock/gomock"
@@ -265,41 +266,78 @@ func (mr *MockWriteOnlyFSMockRecorder) WriteFile(name, data, perm any) *gomock.C
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WriteFile", reflect.TypeOf((*MockWriteOnlyFS)(nil).WriteFile), name, data, perm)
 }
 
-// MockListFS is a mock of ListFS interface.
-type MockListFS struct {
+// MockChmodFS is a mock of ChmodFS interface.
+type MockChmodFS struct {
 	ctrl     *gomock.Controller
-	recorder *MockListFSMockRecorder
+	recorder *MockChmodFSMockRecorder
 	isgomock struct{}
 }
 
-// MockListFSMockRecorder is the mock recorder for MockListFS.
-type MockListFSMockRecorder struct {
-	mock *MockListFS
+// MockChmodFSMockRecorder is the mock recorder for MockChmodFS.
+type MockChmodFSMockRecorder struct {
+	mock *MockChmodFS
 }
 
-// NewMockListFS creates a new mock instance.
-func NewMockListFS(ctrl *gomock.Controller) *MockListFS {
-	mock := &MockListFS{ctrl: ctrl}
-	mock.recorder = &MockListFSMockRecorder{mock}
+// NewMockChmodFS creates a new mock instance.
+func NewMockChmodFS(ctrl *gomock.Controller) *MockChmodFS {
+	mock := &MockChmodFS{ctrl: ctrl}
+	mock.recorder = &MockChmodFSMockRecorder{mock}
 	return mock
 }
 
 // EXPECT returns an object that allows the caller to indicate expected use.
-func (m *MockListFS) EXPECT() *MockListFSMockRecorder {
+func (m *MockChmodFS) EXPECT() *MockChmodFSMockRecorder {
 	return m.recorder
 }
 
-// List mocks base method.
-func (m *MockListFS) List() ([]fs0.FileInfo, error) {
+// Chmod mocks base method.
+func (m *MockChmodFS) Chmod(name string, mode fs0.FileMode) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "List")
-	ret0, _ := ret[0].([]fs0.FileInfo)
-	ret1, _ := ret[1].(error)
-	return ret0, ret1
+	ret := m.ctrl.Call(m, "Chmod", name, mode)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Chmod indicates an expected call of Chmod.
+func (mr *MockChmodFSMockRecorder) Chmod(name, mode any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Chmod", reflect.TypeOf((*MockChmodFS)(nil).Chmod), name, mode)
+}
+
+// MockChtimesFS is a mock of ChtimesFS interface.
+type MockChtimesFS struct {
+	ctrl     *gomock.Controller
+	recorder *MockChtimesFSMockRecorder
+	isgomock struct{}
+}
+
+// MockChtimesFSMockRecorder is the mock recorder for MockChtimesFS.
+type MockChtimesFSMockRecorder struct {
+	mock *MockChtimesFS
+}
+
+// NewMockChtimesFS creates a new mock instance.
+func NewMockChtimesFS(ctrl *gomock.Controller) *MockChtimesFS {
+	mock := &MockChtimesFS{ctrl: ctrl}
+	mock.recorder = &MockChtimesFSMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockChtimesFS) EXPECT() *MockChtimesFSMockRecorder {
+	return m.recorder
+}
+
+// Chtimes mocks base method.
+func (m *MockChtimesFS) Chtimes(name string, atime, mtime time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Chtimes", name, atime, mtime)
+	ret0, _ := ret[0].(error)
+	return ret0
 }
 
-// List indicates an expected call of List.
-func (mr *MockListFSMockRecorder) List() *gomock.Call {
+// Chtimes indicates an expected call of Chtimes.
+func (mr *MockChtimesFSMockRecorder) Chtimes(name, atime, mtime any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockListFS)(nil).List))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Chtimes", reflect.TypeOf((*MockChtimesFS)(nil).Chtimes), name, atime, mtime)
 }
@@ -0,0 +1,95 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: lock.go
+//
+// Generated by this command:
+//
+//	mockgen -source=lock.go -destination=mock/mocklock.go
+//
+
+// Package mock_fs is a generated GoMock package.
+package mock_fs
+
+import (
+	context "context"
+	reflect "reflect"
+
+	fs "go.mws.cloud/util-toolset/pkg/internal/os/fs"
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockUnlocker is a mock of Unlocker interface.
+type MockUnlocker struct {
+	ctrl     *gomock.Controller
+	recorder *MockUnlockerMockRecorder
+	isgomock struct{}
+}
+
+// MockUnlockerMockRecorder is the mock recorder for MockUnlocker.
+type MockUnlockerMockRecorder struct {
+	mock *MockUnlocker
+}
+
+// NewMockUnlocker creates a new mock instance.
+func NewMockUnlocker(ctrl *gomock.Controller) *MockUnlocker {
+	mock := &MockUnlocker{ctrl: ctrl}
+	mock.recorder = &MockUnlockerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockUnlocker) EXPECT() *MockUnlockerMockRecorder {
+	return m.recorder
+}
+
+// Unlock mocks base method.
+func (m *MockUnlocker) Unlock() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Unlock")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Unlock indicates an expected call of Unlock.
+func (mr *MockUnlockerMockRecorder) Unlock() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Unlock", reflect.TypeOf((*MockUnlocker)(nil).Unlock))
+}
+
+// MockLockFS is a mock of LockFS interface.
+type MockLockFS struct {
+	ctrl     *gomock.Controller
+	recorder *MockLockFSMockRecorder
+	isgomock struct{}
+}
+
+// MockLockFSMockRecorder is the mock recorder for MockLockFS.
+type MockLockFSMockRecorder struct {
+	mock *MockLockFS
+}
+
+// NewMockLockFS creates a new mock instance.
+func NewMockLockFS(ctrl *gomock.Controller) *MockLockFS {
+	mock := &MockLockFS{ctrl: ctrl}
+	mock.recorder = &MockLockFSMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockLockFS) EXPECT() *MockLockFSMockRecorder {
+	return m.recorder
+}
+
+// Lock mocks base method.
+func (m *MockLockFS) Lock(ctx context.Context, name string, mode fs.LockMode) (fs.Unlocker, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Lock", ctx, name, mode)
+	ret0, _ := ret[0].(fs.Unlocker)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Lock indicates an expected call of Lock.
+func (mr *MockLockFSMockRecorder) Lock(ctx, name, mode any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Lock", reflect.TypeOf((*MockLockFS)(nil).Lock), ctx, name, mode)
+}
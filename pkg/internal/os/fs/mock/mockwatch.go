@@ -0,0 +1,57 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: watch.go
+//
+// Generated by this command:
+//
+//	mockgen -source=watch.go -destination=mock/mockwatch.go
+//
+
+// Package mock_fs is a generated GoMock package.
+package mock_fs
+
+import (
+	context "context"
+	reflect "reflect"
+
+	fs "go.mws.cloud/util-toolset/pkg/internal/os/fs"
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockWatchFS is a mock of WatchFS interface.
+type MockWatchFS struct {
+	ctrl     *gomock.Controller
+	recorder *MockWatchFSMockRecorder
+	isgomock struct{}
+}
+
+// MockWatchFSMockRecorder is the mock recorder for MockWatchFS.
+type MockWatchFSMockRecorder struct {
+	mock *MockWatchFS
+}
+
+// NewMockWatchFS creates a new mock instance.
+func NewMockWatchFS(ctrl *gomock.Controller) *MockWatchFS {
+	mock := &MockWatchFS{ctrl: ctrl}
+	mock.recorder = &MockWatchFSMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockWatchFS) EXPECT() *MockWatchFSMockRecorder {
+	return m.recorder
+}
+
+// Watch mocks base method.
+func (m *MockWatchFS) Watch(ctx context.Context, name string, recursive bool) (<-chan fs.WatchEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Watch", ctx, name, recursive)
+	ret0, _ := ret[0].(<-chan fs.WatchEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Watch indicates an expected call of Watch.
+func (mr *MockWatchFSMockRecorder) Watch(ctx, name, recursive any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Watch", reflect.TypeOf((*MockWatchFS)(nil).Watch), ctx, name, recursive)
+}
@@ -0,0 +1,333 @@
+package fs
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"go.mws.cloud/util-toolset/pkg/utils/consterr"
+)
+
+const (
+	// ErrCrossMount is returned by Rename of the [FS] created with [NewMountFS]
+	// when the entry cannot be moved between mounts.
+	ErrCrossMount = consterr.Error("cross-mount rename is not possible")
+
+	// ErrMountPoint is returned by Rename of the [FS] created with [NewMountFS]
+	// when the source or destination path is a mount point.
+	ErrMountPoint = consterr.Error("mount point cannot be renamed")
+)
+
+type mountPoint struct {
+	prefix string
+	fs     FS
+}
+
+type mountFS struct {
+	// mounts are sorted by prefix length in descending order, so the first
+	// matching mount is the longest prefix match.
+	mounts []mountPoint
+}
+
+var _ FS = (*mountFS)(nil)
+
+// NewMountFS returns [FS] that composes several file systems mounted under
+// path prefixes, e.g.
+//
+//	fs.NewMountFS(map[string]fs.FS{
+//		"/templates": templatesFS,
+//		"/out":       outFS,
+//		"/tmp":       fs.NewMapFS(),
+//	})
+//
+// Every call is routed to the mount with the longest matching prefix, and the
+// mounted [FS] receives a path relative to its mount point. Relative and
+// absolute paths are treated the same way, so "out/x.txt" and "/out/x.txt"
+// refer to the same file. Use "/" as a prefix to mount the root.
+//
+// ReadDir merges entries of the routed [FS] with mount points located directly
+// in the listed directory. Rename between different mounts falls back to
+// copying and removing the source, [ErrCrossMount] is returned if that fails.
+func NewMountFS(mounts map[string]FS) FS {
+	m := &mountFS{}
+	for prefix, f := range mounts {
+		m.mounts = append(m.mounts, mountPoint{prefix: cleanMountPath(prefix), fs: f})
+	}
+	slices.SortFunc(m.mounts, func(l, r mountPoint) int {
+		return cmp.Or(cmp.Compare(len(r.prefix), len(l.prefix)), strings.Compare(l.prefix, r.prefix))
+	})
+
+	return m
+}
+
+func (m *mountFS) Open(name string) (fs.File, error) {
+	p := cleanMountPath(name)
+	mp, inner, ok := m.route(p)
+	if !ok {
+		return m.openVirtualDir(p, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist})
+	}
+
+	f, err := mp.fs.Open(inner)
+	if errors.Is(err, fs.ErrNotExist) {
+		return m.openVirtualDir(p, err)
+	}
+	return f, err
+}
+
+func (m *mountFS) OpenFile(name string, flag int, perm fs.FileMode) (WritableFile, error) {
+	mp, inner, err := m.routeOp("openfile", name)
+	if err != nil {
+		return nil, err
+	}
+	return mp.fs.OpenFile(inner, flag, perm)
+}
+
+func (m *mountFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	p := cleanMountPath(name)
+	children := m.children(p)
+
+	mp, inner, ok := m.route(p)
+	if !ok {
+		if len(children) == 0 {
+			return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+		}
+		return children, nil
+	}
+
+	entries, err := mp.fs.ReadDir(inner)
+	switch {
+	case errors.Is(err, fs.ErrNotExist) && len(children) > 0:
+		return children, nil
+	case err != nil:
+		return nil, err
+	case len(children) == 0:
+		return entries, nil
+	}
+
+	merged := map[string]fs.DirEntry{}
+	for _, e := range entries {
+		merged[e.Name()] = e
+	}
+	for _, e := range children {
+		merged[e.Name()] = e
+	}
+
+	result := make([]fs.DirEntry, 0, len(merged))
+	for _, k := range slices.Sorted(maps.Keys(merged)) {
+		result = append(result, merged[k])
+	}
+	return result, nil
+}
+
+func (m *mountFS) MkdirAll(name string, perm fs.FileMode) error {
+	p := cleanMountPath(name)
+	mp, inner, ok := m.route(p)
+	switch {
+	case ok:
+		return mp.fs.MkdirAll(inner, perm)
+	case len(m.children(p)) > 0:
+		return nil
+	default:
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrNotExist}
+	}
+}
+
+func (m *mountFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	mp, inner, err := m.routeOp("write_file", name)
+	if err != nil {
+		return err
+	}
+	return mp.fs.WriteFile(inner, data, perm)
+}
+
+func (m *mountFS) Rename(src, dst string) error {
+	srcMount, srcInner, err := m.routeOp("rename", src)
+	if err != nil {
+		return err
+	}
+	dstMount, dstInner, err := m.routeOp("rename", dst)
+	if err != nil {
+		return err
+	}
+	if srcInner == "." || dstInner == "." {
+		return &os.LinkError{Op: "rename", Old: src, New: dst, Err: ErrMountPoint}
+	}
+
+	if srcMount.prefix == dstMount.prefix {
+		return srcMount.fs.Rename(srcInner, dstInner)
+	}
+
+	if err = moveAcross(dstMount.fs, srcMount.fs, dstInner, srcInner); err != nil {
+		return &os.LinkError{Op: "rename", Old: src, New: dst, Err: errors.Join(ErrCrossMount, err)}
+	}
+	return nil
+}
+
+func (m *mountFS) Remove(name string) error {
+	mp, inner, err := m.routeOp("remove", name)
+	if err != nil {
+		return err
+	}
+	return mp.fs.Remove(inner)
+}
+
+func (m *mountFS) RemoveAll(name string) error {
+	mp, inner, err := m.routeOp("remove_all", name)
+	if err != nil {
+		return err
+	}
+	return mp.fs.RemoveAll(inner)
+}
+
+func (m *mountFS) Chmod(name string, mode fs.FileMode) error {
+	mp, inner, err := m.routeOp("chmod", name)
+	if err != nil {
+		return err
+	}
+	return Chmod(mp.fs, inner, mode)
+}
+
+func (m *mountFS) Chtimes(name string, atime, mtime time.Time) error {
+	mp, inner, err := m.routeOp("chtimes", name)
+	if err != nil {
+		return err
+	}
+	return Chtimes(mp.fs, inner, atime, mtime)
+}
+
+func (m *mountFS) Lock(ctx context.Context, name string, mode LockMode) (Unlocker, error) {
+	mp, inner, err := m.routeOp("lock", name)
+	if err != nil {
+		return nil, err
+	}
+	return Lock(ctx, mp.fs, inner, mode)
+}
+
+func (m *mountFS) Watch(ctx context.Context, name string, recursive bool) (<-chan WatchEvent, error) {
+	mp, inner, err := m.routeOp("watch", name)
+	if err != nil {
+		return nil, err
+	}
+	events, err := watchFS(ctx, mp.fs, inner, recursive)
+	if err != nil {
+		return nil, err
+	}
+
+	return mapWatchEvents(ctx, events, func(e *WatchEvent) bool {
+		e.Path = path.Join(mp.prefix[1:], cleanRelPath(filepath.ToSlash(e.Path)))
+		return true
+	}), nil
+}
+
+// route finds the mount with the longest prefix matching the cleaned path p
+// and returns the path relative to that mount.
+func (m *mountFS) route(p string) (mountPoint, string, bool) {
+	for _, mp := range m.mounts {
+		switch {
+		case p == mp.prefix:
+			return mp, ".", true
+		case mp.prefix == "/":
+			return mp, p[1:], true
+		case strings.HasPrefix(p, mp.prefix+"/"):
+			return mp, p[len(mp.prefix)+1:], true
+		}
+	}
+	return mountPoint{}, "", false
+}
+
+func (m *mountFS) routeOp(op, name string) (mountPoint, string, error) {
+	mp, inner, ok := m.route(cleanMountPath(name))
+	if !ok {
+		return mountPoint{}, "", &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return mp, inner, nil
+}
+
+// children returns directory entries for mount points located directly in
+// the directory p or for intermediate directories leading to them.
+func (m *mountFS) children(p string) []fs.DirEntry {
+	dir := p
+	if dir != "/" {
+		dir += "/"
+	}
+
+	names := map[string]struct{}{}
+	for _, mp := range m.mounts {
+		rest, ok := strings.CutPrefix(mp.prefix, dir)
+		if !ok || rest == "" {
+			continue
+		}
+		name, _, _ := strings.Cut(rest, "/")
+		names[name] = struct{}{}
+	}
+
+	entries := make([]fs.DirEntry, 0, len(names))
+	for _, name := range slices.Sorted(maps.Keys(names)) {
+		entries = append(entries, fs.FileInfoToDirEntry(virtualDirInfo{name: name}))
+	}
+	return entries
+}
+
+func (m *mountFS) openVirtualDir(p string, notExist error) (fs.File, error) {
+	children := m.children(p)
+	if len(children) == 0 {
+		return nil, notExist
+	}
+	return &dirFile{info: virtualDirInfo{name: path.Base(p)}, entries: children}, nil
+}
+
+// moveAcross moves a file or a directory tree from one file system to another
+// by copying and removing the source afterwards. A failed copy is removed,
+// unless dstName existed before, while a complete copy is kept even if the
+// source is only partly removed. The copy is removed as well if the source is
+// read-only.
+func moveAcross(dst, src FS, dstName, srcName string) error {
+	info, err := fs.Stat(src, srcName)
+	if err != nil {
+		return err
+	}
+	_, err = fs.Stat(dst, dstName)
+	existed := err == nil
+
+	if info.IsDir() {
+		err = CopyFS(&baseDir{wrapped: wrapped{dst}, dir: dstName}, &baseDir{wrapped: wrapped{src}, dir: srcName})
+	} else {
+		var data []byte
+		if data, err = fs.ReadFile(src, srcName); err == nil {
+			err = dst.WriteFile(dstName, data, info.Mode().Perm())
+		}
+	}
+	if err != nil {
+		if !existed {
+			err = errors.Join(err, dst.RemoveAll(dstName))
+		}
+		return err
+	}
+
+	err = src.RemoveAll(srcName)
+	switch {
+	case errors.Is(err, ErrReadOnly):
+		// Nothing is removed, so the copy is not needed.
+		return errors.Join(err, dst.RemoveAll(dstName))
+	case err != nil:
+		return fmt.Errorf("%s is copied to %s, but only partly removed: %w", srcName, dstName, err)
+	}
+	return nil
+}
+
+func cleanMountPath(name string) string {
+	return path.Clean("/" + name)
+}
+
+func (*mountFS) String() string {
+	return "MountFS"
+}
@@ -0,0 +1,124 @@
+package fs_test
+
+import (
+	"errors"
+	iofs "io/fs"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go.mws.cloud/util-toolset/pkg/internal/os/fs"
+)
+
+func newTestMountFS(t *testing.T) (mount, templates, out, tmp fs.FS) {
+	t.Helper()
+
+	templates = fs.NewFS(fs.NewMapFS(), fs.WithDirCreate(os.ModePerm))
+	out = fs.NewFS(fs.NewRealFS(), fs.WithBaseDir(t.TempDir()), fs.WithDirCreate(os.ModePerm))
+	tmp = fs.NewFS(fs.NewMapFS(), fs.WithDirCreate(os.ModePerm))
+
+	mount = fs.NewMountFS(map[string]fs.FS{
+		"/templates":   templates,
+		"/out":         out,
+		"/var/lib/tmp": tmp,
+	})
+	return mount, templates, out, tmp
+}
+
+func TestMountFS(t *testing.T) {
+	mount, templates, out, tmp := newTestMountFS(t)
+
+	require.NoError(t, templates.WriteFile("main.tmpl", []byte("tmpl"), 0o644))
+	require.NoError(t, mount.WriteFile("/out/gen/main.go", []byte("main"), 0o644))
+	require.NoError(t, mount.WriteFile("var/lib/tmp/scratch.txt", []byte("tmp"), 0o644))
+
+	data, err := fs.ReadFile(mount, "/templates/main.tmpl")
+	require.NoError(t, err)
+	require.Equal(t, []byte("tmpl"), data)
+	require.Equal(t, []string{"gen/main.go"}, collectElements(t, out))
+	require.Equal(t, []string{"scratch.txt"}, collectElements(t, tmp))
+
+	require.Equal(t, []string{"out/gen/main.go", "templates/main.tmpl", "var/lib/tmp/scratch.txt"}, collectElements(t, mount))
+
+	entries, err := mount.ReadDir("/var")
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, "lib", entries[0].Name())
+	require.True(t, entries[0].IsDir())
+
+	_, err = mount.Open("/unknown/x.txt")
+	require.ErrorIs(t, err, iofs.ErrNotExist)
+	require.ErrorIs(t, mount.WriteFile("/x.txt", nil, 0o644), iofs.ErrNotExist)
+	require.NoError(t, mount.MkdirAll("/var/lib", os.ModePerm))
+}
+
+func TestMountFSLongestPrefix(t *testing.T) {
+	root, nested := fs.NewMapFS(), fs.NewMapFS()
+	mount := fs.NewMountFS(map[string]fs.FS{"/": root, "/nested": nested})
+
+	require.NoError(t, mount.WriteFile("/a.txt", nil, 0o644))
+	require.NoError(t, mount.WriteFile("/nested/b.txt", nil, 0o644))
+	require.NoError(t, root.WriteFile("c.txt", nil, 0o644))
+
+	require.Equal(t, []string{"a.txt", "c.txt"}, collectElements(t, root))
+	require.Equal(t, []string{"b.txt"}, collectElements(t, nested))
+	require.Equal(t, []string{"a.txt", "c.txt", "nested/b.txt"}, collectElements(t, mount))
+}
+
+func TestMountFSRename(t *testing.T) {
+	mount, _, out, tmp := newTestMountFS(t)
+
+	require.NoError(t, mount.WriteFile("/var/lib/tmp/a.txt", []byte("a"), 0o644))
+	require.NoError(t, mount.WriteFile("/var/lib/tmp/dir/b.txt", []byte("b"), 0o644))
+
+	// Same mount.
+	require.NoError(t, mount.Rename("/var/lib/tmp/a.txt", "/var/lib/tmp/c.txt"))
+	require.Equal(t, []string{"c.txt", "dir/b.txt"}, collectElements(t, tmp))
+
+	// Across mounts.
+	require.NoError(t, mount.Rename("/var/lib/tmp/c.txt", "/out/c.txt"))
+	require.NoError(t, mount.Rename("/var/lib/tmp/dir", "/out/moved"))
+	require.Empty(t, collectElements(t, tmp))
+	require.Equal(t, []string{"c.txt", "moved/b.txt"}, collectElements(t, out))
+
+	data, err := fs.ReadFile(out, "moved/b.txt")
+	require.NoError(t, err)
+	require.Equal(t, []byte("b"), data)
+
+	require.ErrorIs(t, mount.Rename("/out", "/var/lib/tmp/out"), fs.ErrMountPoint)
+	require.ErrorIs(t, mount.Rename("/out/missing", "/var/lib/tmp/missing"), fs.ErrCrossMount)
+}
+
+func TestMountFSRenameFailure(t *testing.T) {
+	base := fs.NewFS(fs.NewMapFS(), fs.WithDirCreate(os.ModePerm))
+	require.NoError(t, base.WriteFile("dir/a.txt", []byte("a"), 0o644))
+	require.NoError(t, base.WriteFile("dir/b.txt", []byte("b"), 0o644))
+	src := fs.NewFS(base, fs.WithInterceptor(fs.Interceptor{
+		OnOpen: func(c *fs.Call) error {
+			if c.Path == "broken/b.txt" {
+				return &iofs.PathError{Op: "open", Path: c.Path, Err: iofs.ErrPermission}
+			}
+			return nil
+		},
+		OnRemove: func(c *fs.Call) error {
+			// The removal fails after a part of the tree is removed.
+			return errors.Join(base.Remove("dir/a.txt"), iofs.ErrPermission)
+		},
+	}))
+	dst := fs.NewFS(fs.NewMapFS(), fs.WithDirCreate(os.ModePerm))
+	mount := fs.NewMountFS(map[string]fs.FS{"/src": src, "/dst": dst})
+
+	// The copy is complete, so it is kept.
+	require.ErrorIs(t, mount.Rename("/src/dir", "/dst/dir"), iofs.ErrPermission)
+	require.Equal(t, []string{"dir/a.txt", "dir/b.txt"}, collectElements(t, dst))
+	require.Equal(t, []string{"dir/b.txt"}, collectElements(t, base))
+
+	// The partial copy is removed, unless the destination existed before.
+	require.NoError(t, base.Rename("dir", "broken"))
+	require.NoError(t, base.WriteFile("broken/a.txt", []byte("a"), 0o644))
+	require.ErrorIs(t, mount.Rename("/src/broken", "/dst/new"), iofs.ErrPermission)
+	require.ErrorIs(t, mount.Rename("/src/broken", "/dst/dir"), iofs.ErrExist)
+	require.Equal(t, []string{"dir/a.txt", "dir/b.txt"}, collectElements(t, dst))
+	require.Equal(t, []string{"broken/a.txt", "broken/b.txt"}, collectElements(t, base))
+}
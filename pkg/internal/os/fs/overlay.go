@@ -0,0 +1,631 @@
+package fs
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"go.mws.cloud/util-toolset/pkg/utils/consterr"
+)
+
+const errDirNotEmpty = consterr.Error("directory not empty")
+
+// OverlayFS is a copy-on-write [FS]: reads come from the upper layer if it
+// contains the file and from the read-only base layer otherwise, while all
+// writes go to the upper layer. Removed base entries are hidden by whiteouts,
+// which are kept in memory.
+//
+// A directory removed and created again is opaque: entries of the base
+// directory are not visible in it anymore.
+type OverlayFS struct {
+	base  ReadOnlyFS
+	upper FS
+	// lower is the base layer seen through the base dir.
+	lower ReadOnlyFS
+
+	mu        sync.Mutex
+	whiteouts map[string]struct{}
+}
+
+var _ FS = (*OverlayFS)(nil)
+
+// OverlayOption is an option for [NewOverlayFS].
+type OverlayOption func(*OverlayFS)
+
+// WithOverlayBaseDir resolves the names against dir in the base layer. Names
+// of [OverlayFS] are relative to its root, "/a" and "a" are the same file, so
+// the base is read from dir, "." by default. Use "/" to read absolute paths:
+//
+//	preview := fs.NewOverlayFS(fs.NewRealFS(), fs.NewMapFS(), fs.WithOverlayBaseDir("/"))
+//	data, err := fs.ReadFile(preview, "/etc/hosts")
+func WithOverlayBaseDir(dir string) OverlayOption {
+	return func(o *OverlayFS) {
+		o.lower = dirView{f: o.base, dir: dir}
+	}
+}
+
+// NewOverlayFS returns [OverlayFS] over the base layer, writing all the
+// changes to the upper layer. [NewMapFS] is the natural upper layer:
+//
+//	preview := fs.NewOverlayFS(fs.NewRealFS(), fs.NewMapFS())
+func NewOverlayFS(base ReadOnlyFS, upper FS, options ...OverlayOption) *OverlayFS {
+	o := &OverlayFS{
+		base:      base,
+		upper:     upper,
+		lower:     base,
+		whiteouts: map[string]struct{}{},
+	}
+	for _, opt := range options {
+		opt(o)
+	}
+	return o
+}
+
+// Open opens the named file for reading. Directories are opened with entries
+// of both layers merged.
+func (o *OverlayFS) Open(name string) (fs.File, error) {
+	p := cleanRelPath(name)
+	info, err := o.stat(p)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+
+	if info.IsDir() {
+		entries, err := o.ReadDir(p)
+		if err != nil {
+			return nil, err
+		}
+		return &dirFile{info: info, entries: entries}, nil
+	}
+
+	if _, err = fs.Stat(o.upper, p); err == nil {
+		return o.upper.Open(p)
+	}
+	return o.lower.Open(p)
+}
+
+// OpenFile opens the named file. If the file is opened for writing, it is
+// copied to the upper layer first.
+func (o *OverlayFS) OpenFile(name string, flag int, perm fs.FileMode) (WritableFile, error) {
+	p := cleanRelPath(name)
+	if flag&writeFlags == 0 {
+		if _, err := fs.Stat(o.upper, p); err == nil || o.isHidden(p) {
+			return o.upper.OpenFile(p, flag, perm)
+		}
+		f, err := o.lower.Open(p)
+		if err != nil {
+			return nil, err
+		}
+		return &readOnlyFile{File: f, name: name}, nil
+	}
+
+	if err := o.copyUp(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	if err := o.mkdirParent(p); err != nil {
+		return nil, err
+	}
+
+	f, err := o.upper.OpenFile(p, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	o.unhide(p)
+	return f, nil
+}
+
+// ReadDir reads the named directory merging entries of both layers.
+func (o *OverlayFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	p := cleanRelPath(name)
+
+	upperEntries, upperErr := o.upper.ReadDir(p)
+	if upperErr != nil && !errors.Is(upperErr, fs.ErrNotExist) {
+		return nil, upperErr
+	}
+
+	var baseEntries []fs.DirEntry
+	baseErr := fs.ErrNotExist
+	if !o.isHidden(p) && !o.upperHasFile(p) {
+		baseEntries, baseErr = o.lower.ReadDir(p)
+		if baseErr != nil && !errors.Is(baseErr, fs.ErrNotExist) {
+			return nil, baseErr
+		}
+	}
+	if upperErr != nil && baseErr != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+
+	merged := map[string]fs.DirEntry{}
+	for _, e := range baseEntries {
+		if !o.isHidden(path.Join(p, e.Name())) {
+			merged[e.Name()] = e
+		}
+	}
+	for _, e := range upperEntries {
+		merged[e.Name()] = e
+	}
+
+	entries := make([]fs.DirEntry, 0, len(merged))
+	for _, k := range slices.Sorted(maps.Keys(merged)) {
+		entries = append(entries, merged[k])
+	}
+	return entries, nil
+}
+
+// MkdirAll creates a directory in the upper layer.
+func (o *OverlayFS) MkdirAll(name string, perm fs.FileMode) error {
+	return o.upper.MkdirAll(cleanRelPath(name), perm)
+}
+
+// WriteFile writes data to the named file in the upper layer.
+func (o *OverlayFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	p := cleanRelPath(name)
+	if err := o.mkdirParent(p); err != nil {
+		return err
+	}
+	if err := o.upper.WriteFile(p, data, perm); err != nil {
+		return err
+	}
+
+	o.unhide(p)
+	return nil
+}
+
+// Rename renames (moves) src to dst, src is copied to the upper layer first
+// if it exists only in the base layer.
+func (o *OverlayFS) Rename(src, dst string) error {
+	srcPath, dstPath := cleanRelPath(src), cleanRelPath(dst)
+	info, err := o.stat(srcPath)
+	if err != nil {
+		return &os.LinkError{Op: "rename", Old: src, New: dst, Err: err}
+	}
+
+	if err = o.copyUp(srcPath); err != nil {
+		return err
+	}
+	if err = o.mkdirParent(dstPath); err != nil {
+		return err
+	}
+	if err = o.upper.Rename(srcPath, dstPath); err != nil {
+		return err
+	}
+
+	o.hide(srcPath)
+	if info.IsDir() {
+		// Entries of the base directory must not show up in the moved one.
+		o.hide(dstPath)
+	} else {
+		o.unhide(dstPath)
+	}
+	return nil
+}
+
+// Remove removes the named file or empty directory.
+func (o *OverlayFS) Remove(name string) error {
+	p := cleanRelPath(name)
+	info, err := o.stat(p)
+	if err != nil {
+		return &fs.PathError{Op: "remove", Path: name, Err: err}
+	}
+	if info.IsDir() {
+		entries, err := o.ReadDir(p)
+		if err != nil {
+			return err
+		}
+		if len(entries) > 0 {
+			return &fs.PathError{Op: "remove", Path: name, Err: errDirNotEmpty}
+		}
+	}
+
+	if err = o.upper.RemoveAll(p); err != nil {
+		return err
+	}
+	o.hide(p)
+	return nil
+}
+
+// RemoveAll removes path and any children it contains.
+func (o *OverlayFS) RemoveAll(name string) error {
+	p := cleanRelPath(name)
+	if err := o.upper.RemoveAll(p); err != nil {
+		return err
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for w := range o.whiteouts {
+		if p == "." || strings.HasPrefix(w, p+"/") {
+			delete(o.whiteouts, w)
+		}
+	}
+	o.whiteouts[p] = struct{}{}
+	return nil
+}
+
+// Chmod changes the mode of the named file, copying it to the upper layer first.
+func (o *OverlayFS) Chmod(name string, mode fs.FileMode) error {
+	p := cleanRelPath(name)
+	if err := o.copyUp(p); err != nil {
+		return err
+	}
+	return Chmod(o.upper, p, mode)
+}
+
+// Chtimes changes the times of the named file, copying it to the upper layer first.
+func (o *OverlayFS) Chtimes(name string, atime, mtime time.Time) error {
+	p := cleanRelPath(name)
+	if err := o.copyUp(p); err != nil {
+		return err
+	}
+	return Chtimes(o.upper, p, atime, mtime)
+}
+
+// Lock acquires the lock in the upper layer, the base is never modified.
+func (o *OverlayFS) Lock(ctx context.Context, name string, mode LockMode) (Unlocker, error) {
+	return Lock(ctx, o.upper, cleanRelPath(name), mode)
+}
+
+// Watch reports changes of the upper layer. If the directory exists only in
+// the base, its closest ancestor in the upper layer is watched instead, so
+// that the directory is reported once it is copied up.
+func (o *OverlayFS) Watch(ctx context.Context, name string, recursive bool) (<-chan WatchEvent, error) {
+	p := cleanRelPath(name)
+	if _, err := fs.Stat(o.upper, p); err == nil {
+		return watchFS(ctx, o.upper, p, recursive)
+	}
+
+	info, err := fs.Stat(o, p)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, &fs.PathError{Op: "watch", Path: name, Err: syscall.ENOTDIR}
+	}
+
+	dir := path.Dir(p)
+	for ; dir != "."; dir = path.Dir(dir) {
+		if info, err := fs.Stat(o.upper, dir); err == nil && info.IsDir() {
+			break
+		}
+	}
+	events, err := watchFS(ctx, o.upper, dir, true)
+	if err != nil {
+		return nil, err
+	}
+	return mapWatchEvents(ctx, events, func(e *WatchEvent) bool {
+		rel, ok := relWatchPath(p, e.Path)
+		return ok && rel != "." && (recursive || !strings.Contains(rel, "/"))
+	}), nil
+}
+
+// Changes returns the changes made in the upper layer relative to the base
+// layer, sorted by path.
+func (o *OverlayFS) Changes() ([]SyncChange, error) {
+	var changes []SyncChange
+	err := fs.WalkDir(o.upper, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || p == "." {
+			return err
+		}
+
+		c, err := o.upperChange(p, d)
+		if err != nil {
+			return err
+		}
+		changes = append(changes, c...)
+		return nil
+	})
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	o.mu.Lock()
+	whiteouts := slices.Sorted(maps.Keys(o.whiteouts))
+	o.mu.Unlock()
+
+	for _, w := range whiteouts {
+		c, err := o.whiteoutChanges(w)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, c...)
+	}
+
+	slices.SortStableFunc(changes, func(l, r SyncChange) int {
+		// Deletions go first, so the path can be reused by a creation.
+		return cmp.Or(strings.Compare(l.Path, r.Path), deleteFirst(l)-deleteFirst(r))
+	})
+	return changes, nil
+}
+
+// Apply applies the changes made in the upper layer to dst, which is
+// usually a writable view of the base layer.
+func (o *OverlayFS) Apply(dst FS) error {
+	changes, err := o.Changes()
+	if err != nil {
+		return err
+	}
+
+	for _, c := range changes {
+		switch {
+		case c.Op == SyncDelete:
+			err = dst.RemoveAll(c.Path)
+		case c.IsDir:
+			err = dst.MkdirAll(c.Path, fs.ModePerm)
+		default:
+			err = o.applyFile(dst, c.Path)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyFile copies the file of the upper layer to dst along with its mode,
+// which is kept by copyFile if the file exists.
+func (o *OverlayFS) applyFile(dst FS, p string) error {
+	info, err := fs.Stat(o.upper, p)
+	if err != nil {
+		return err
+	}
+	if err = copyFile(dst, o.upper, p); err != nil {
+		return err
+	}
+	if err = Chmod(dst, p, info.Mode().Perm()); errors.Is(err, errors.ErrUnsupported) {
+		return nil
+	}
+	return err
+}
+
+// WritePatch writes the changes made in the upper layer as a git-style patch.
+func (o *OverlayFS) WritePatch(w io.Writer) error {
+	changes, err := o.Changes()
+	if err != nil {
+		return err
+	}
+
+	for _, c := range changes {
+		if c.IsDir && c.Op != SyncDelete {
+			continue
+		}
+		if err = o.writeChangePatch(w, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (o *OverlayFS) writeChangePatch(w io.Writer, c SyncChange) error {
+	if c.Op == SyncDelete {
+		// Every file of the removed tree has to be listed in the patch.
+		return fs.WalkDir(o.lower, c.Path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			fp := filePatch{name: p}
+			if fp.oldData, fp.oldMode, err = readPatchFile(o.lower, p); err != nil {
+				return err
+			}
+			return fp.write(w)
+		})
+	}
+
+	fp := filePatch{name: c.Path}
+	var err error
+	if c.Op == SyncUpdate {
+		if fp.oldData, fp.oldMode, err = readPatchFile(o.lower, c.Path); err != nil {
+			return err
+		}
+	}
+	if fp.newData, fp.newMode, err = readPatchFile(o.upper, c.Path); err != nil {
+		return err
+	}
+	return fp.write(w)
+}
+
+// upperChange returns the change for the entry p of the upper layer.
+func (o *OverlayFS) upperChange(p string, d fs.DirEntry) ([]SyncChange, error) {
+	create := []SyncChange{{Op: SyncCreate, Path: p, IsDir: d.IsDir()}}
+
+	baseInfo, err := o.baseStat(p)
+	switch {
+	case errors.Is(err, fs.ErrNotExist), err != nil && o.isHidden(path.Dir(p)):
+		// A parent hidden by a whiteout may be a file in the base layer.
+		return create, nil
+	case err != nil:
+		return nil, err
+	case baseInfo.IsDir() != d.IsDir():
+		return append([]SyncChange{{Op: SyncDelete, Path: p, IsDir: baseInfo.IsDir()}}, create...), nil
+	case d.IsDir():
+		return nil, nil
+	}
+
+	info, err := d.Info()
+	if err != nil {
+		return nil, err
+	}
+	if info.Mode() != baseInfo.Mode() {
+		return []SyncChange{{Op: SyncUpdate, Path: p}}, nil
+	}
+
+	upperSum, err := hashFile(o.upper, p)
+	if err != nil {
+		return nil, err
+	}
+	baseSum, err := hashFile(o.lower, p)
+	if err != nil || slices.Equal(upperSum, baseSum) {
+		return nil, err
+	}
+	return []SyncChange{{Op: SyncUpdate, Path: p}}, nil
+}
+
+// whiteoutChanges returns deletions of base entries hidden by the whiteout w.
+func (o *OverlayFS) whiteoutChanges(w string) ([]SyncChange, error) {
+	if w != "." && o.isHidden(path.Dir(w)) {
+		// Already covered by the parent whiteout.
+		return nil, nil
+	}
+
+	baseInfo, err := o.baseStat(w)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	upperInfo, err := fs.Stat(o.upper, w)
+	if errors.Is(err, fs.ErrNotExist) {
+		return []SyncChange{{Op: SyncDelete, Path: w, IsDir: baseInfo.IsDir()}}, nil
+	}
+	if err != nil || !upperInfo.IsDir() || !baseInfo.IsDir() {
+		// Type changes are reported by upperChange.
+		return nil, err
+	}
+
+	// Opaque directory, base entries missing in the upper layer are deleted.
+	var changes []SyncChange
+	err = fs.WalkDir(o.lower, w, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || p == w {
+			return err
+		}
+		if _, err = fs.Stat(o.upper, p); errors.Is(err, fs.ErrNotExist) {
+			changes = append(changes, SyncChange{Op: SyncDelete, Path: p, IsDir: d.IsDir()})
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		return err
+	})
+	return changes, err
+}
+
+// stat returns info of the visible entry p.
+func (o *OverlayFS) stat(p string) (fs.FileInfo, error) {
+	info, err := fs.Stat(o.upper, p)
+	if !errors.Is(err, fs.ErrNotExist) {
+		return info, err
+	}
+	if o.isHidden(p) {
+		return nil, fs.ErrNotExist
+	}
+	return o.baseStat(p)
+}
+
+func (o *OverlayFS) baseStat(p string) (fs.FileInfo, error) {
+	return fs.Stat(o.lower, p)
+}
+
+// copyUp copies the visible entry p (recursively for directories) from the
+// base to the upper layer, skipping entries the upper layer already has.
+func (o *OverlayFS) copyUp(p string) error {
+	info, err := fs.Stat(o.upper, p)
+	switch {
+	case err == nil && !info.IsDir():
+		return nil
+	case err != nil && o.isHidden(p):
+		return nil
+	}
+
+	return fs.WalkDir(o, p, func(q string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if _, err = fs.Stat(o.upper, q); err == nil {
+			return nil
+		}
+		if err = o.mkdirParent(q); err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return o.upper.MkdirAll(q, fs.ModePerm)
+		}
+		return copyFile(o.upper, o.lower, q)
+	})
+}
+
+func (o *OverlayFS) mkdirParent(p string) error {
+	if dir := path.Dir(p); dir != "." {
+		return o.upper.MkdirAll(dir, fs.ModePerm)
+	}
+	return nil
+}
+
+func (o *OverlayFS) upperHasFile(p string) bool {
+	info, err := fs.Stat(o.upper, p)
+	return err == nil && !info.IsDir()
+}
+
+// isHidden reports whether p or any of its parents is hidden by a whiteout.
+func (o *OverlayFS) isHidden(p string) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for {
+		if _, ok := o.whiteouts[p]; ok {
+			return true
+		}
+		if p == "." {
+			return false
+		}
+		p = path.Dir(p)
+	}
+}
+
+func (o *OverlayFS) hide(p string) {
+	o.mu.Lock()
+	o.whiteouts[p] = struct{}{}
+	o.mu.Unlock()
+}
+
+func (o *OverlayFS) unhide(p string) {
+	o.mu.Lock()
+	delete(o.whiteouts, p)
+	o.mu.Unlock()
+}
+
+func deleteFirst(c SyncChange) int {
+	if c.Op == SyncDelete {
+		return 0
+	}
+	return 1
+}
+
+// dirView is a read-only view of the directory of f.
+type dirView struct {
+	f   ReadOnlyFS
+	dir string
+}
+
+func (v dirView) Open(name string) (fs.File, error) {
+	return v.f.Open(path.Join(v.dir, name))
+}
+
+func (v dirView) ReadDir(name string) ([]fs.DirEntry, error) {
+	return v.f.ReadDir(path.Join(v.dir, name))
+}
+
+// cleanRelPath converts name to a valid [fs.FS] path, treating relative and
+// absolute paths the same way.
+func cleanRelPath(name string) string {
+	if p := cleanMountPath(name)[1:]; p != "" {
+		return p
+	}
+	return "."
+}
+
+func (*OverlayFS) String() string {
+	return "OverlayFS"
+}
@@ -0,0 +1,175 @@
+package fs_test
+
+import (
+	"bytes"
+	iofs "io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go.mws.cloud/util-toolset/pkg/internal/os/fs"
+)
+
+func newTestOverlayFS(t *testing.T) (overlay *fs.OverlayFS, base fs.FS) {
+	t.Helper()
+
+	base = fs.NewFS(fs.NewRealFS(), fs.WithBaseDir(t.TempDir()), fs.WithDirCreate(os.ModePerm))
+	require.NoError(t, base.WriteFile("a.txt", []byte("a\n"), 0o644))
+	require.NoError(t, base.WriteFile("dir/b.txt", []byte("b\n"), 0o644))
+	require.NoError(t, base.WriteFile("dir/c.txt", []byte("c\n"), 0o644))
+	require.NoError(t, base.WriteFile("old/d.txt", []byte("d\n"), 0o644))
+
+	return fs.NewOverlayFS(base, fs.NewMapFS()), base
+}
+
+func TestOverlayFS(t *testing.T) {
+	overlay, base := newTestOverlayFS(t)
+
+	require.NoError(t, overlay.WriteFile("a.txt", []byte("A\n"), 0o644))
+	require.NoError(t, overlay.WriteFile("dir/new.txt", []byte("new\n"), 0o644))
+	require.NoError(t, overlay.Remove("dir/c.txt"))
+	require.NoError(t, overlay.RemoveAll("old"))
+
+	require.Equal(t, []string{"a.txt", "dir/b.txt", "dir/new.txt"}, collectElements(t, overlay))
+	require.Equal(t, []string{"a.txt", "dir/b.txt", "dir/c.txt", "old/d.txt"}, collectElements(t, base))
+
+	data, err := fs.ReadFile(overlay, "a.txt")
+	require.NoError(t, err)
+	require.Equal(t, []byte("A\n"), data)
+
+	_, err = overlay.Open("old/d.txt")
+	require.ErrorIs(t, err, iofs.ErrNotExist)
+
+	changes, err := overlay.Changes()
+	require.NoError(t, err)
+	require.Equal(t, []fs.SyncChange{
+		{Op: fs.SyncUpdate, Path: "a.txt"},
+		{Op: fs.SyncDelete, Path: "dir/c.txt"},
+		{Op: fs.SyncCreate, Path: "dir/new.txt"},
+		{Op: fs.SyncDelete, Path: "old", IsDir: true},
+	}, changes)
+
+	require.NoError(t, overlay.Apply(base))
+	require.Equal(t, []string{"a.txt", "dir/b.txt", "dir/new.txt"}, collectElements(t, base))
+}
+
+func TestOverlayFSOpaqueDir(t *testing.T) {
+	overlay, _ := newTestOverlayFS(t)
+
+	require.NoError(t, overlay.RemoveAll("dir"))
+	require.NoError(t, overlay.WriteFile("dir/c.txt", []byte("c\n"), 0o644))
+	require.Equal(t, []string{"a.txt", "dir/c.txt", "old/d.txt"}, collectElements(t, overlay))
+
+	changes, err := overlay.Changes()
+	require.NoError(t, err)
+	require.Equal(t, []fs.SyncChange{{Op: fs.SyncDelete, Path: "dir/b.txt"}}, changes)
+}
+
+func TestOverlayFSRename(t *testing.T) {
+	overlay, _ := newTestOverlayFS(t)
+
+	require.NoError(t, overlay.Rename("dir", "moved"))
+	require.NoError(t, overlay.Rename("a.txt", "moved/a.txt"))
+	require.Equal(t, []string{"moved/a.txt", "moved/b.txt", "moved/c.txt", "old/d.txt"}, collectElements(t, overlay))
+}
+
+func TestOverlayFSOpenFile(t *testing.T) {
+	overlay, base := newTestOverlayFS(t)
+
+	f, err := overlay.OpenFile("a.txt", os.O_RDONLY, 0)
+	require.NoError(t, err)
+	_, err = f.Write([]byte("x"))
+	require.ErrorIs(t, err, fs.ErrReadOnly)
+	require.NoError(t, f.Close())
+
+	f, err = overlay.OpenFile("a.txt", os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	_, err = f.Write([]byte("b\n"))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	data, err := fs.ReadFile(overlay, "a.txt")
+	require.NoError(t, err)
+	require.Equal(t, []byte("a\nb\n"), data)
+
+	data, err = fs.ReadFile(base, "a.txt")
+	require.NoError(t, err)
+	require.Equal(t, []byte("a\n"), data)
+}
+
+func TestOverlayFSWritePatch(t *testing.T) {
+	overlay, _ := newTestOverlayFS(t)
+
+	require.NoError(t, overlay.WriteFile("a.txt", []byte("A"), 0o644))
+	require.NoError(t, overlay.WriteFile("new.txt", []byte("new\n"), 0o644))
+	require.NoError(t, overlay.RemoveAll("old"))
+
+	var b bytes.Buffer
+	require.NoError(t, overlay.WritePatch(&b))
+	require.Equal(t, `diff --git a/a.txt b/a.txt
+--- a/a.txt
++++ b/a.txt
+@@ -1 +1 @@
+-a
++A
+\ No newline at end of file
+diff --git a/new.txt b/new.txt
+new file mode 100644
+--- /dev/null
++++ b/new.txt
+@@ -0,0 +1 @@
++new
+diff --git a/old/d.txt b/old/d.txt
+deleted file mode 100644
+--- a/old/d.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-d
+`, b.String())
+}
+
+func TestOverlayFSChmod(t *testing.T) {
+	overlay, base := newTestOverlayFS(t)
+	require.NoError(t, overlay.Chmod("a.txt", 0o755))
+
+	changes, err := overlay.Changes()
+	require.NoError(t, err)
+	require.Equal(t, []fs.SyncChange{{Op: fs.SyncUpdate, Path: "a.txt"}}, changes)
+
+	var b bytes.Buffer
+	require.NoError(t, overlay.WritePatch(&b))
+	require.Equal(t, `diff --git a/a.txt b/a.txt
+old mode 100644
+new mode 100755
+`, b.String())
+
+	require.NoError(t, overlay.Apply(base))
+	info, err := iofs.Stat(base, "a.txt")
+	require.NoError(t, err)
+	require.Equal(t, iofs.FileMode(0o755), info.Mode())
+}
+
+func TestOverlayFSBaseDir(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "x.txt"), []byte("x"), 0o644))
+	name := filepath.ToSlash(filepath.Join(dir, "x.txt"))
+
+	// Names are relative to the root of the overlay, and the real FS is read
+	// from its root.
+	overlay := fs.NewOverlayFS(fs.NewRealFS(), fs.NewMapFS(), fs.WithOverlayBaseDir("/"))
+	data, err := fs.ReadFile(overlay, name)
+	require.NoError(t, err)
+	require.Equal(t, []byte("x"), data)
+
+	require.NoError(t, overlay.WriteFile(name, []byte("y"), 0o644))
+	changes, err := overlay.Changes()
+	require.NoError(t, err)
+	require.Equal(t, []fs.SyncChange{{Op: fs.SyncUpdate, Path: strings.TrimPrefix(name, "/")}}, changes)
+
+	data, err = os.ReadFile(filepath.Join(dir, "x.txt"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("x"), data)
+}
@@ -0,0 +1,52 @@
+package fs
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+
+	"go.mws.cloud/util-toolset/pkg/internal/diff"
+)
+
+const devNull = "/dev/null"
+
+// filePatch is a git-style patch replacing the old content of the file with
+// the new one. A nil content means the file does not exist on the
+// corresponding side. The modes are written only if they are set.
+type filePatch struct {
+	name             string
+	oldData, newData []byte
+	oldMode, newMode fs.FileMode
+}
+
+func (p filePatch) write(w io.Writer, options ...diff.Option) error {
+	oldName, newName := "a/"+p.name, "b/"+p.name
+	if p.oldData == nil {
+		oldName = devNull
+	}
+	if p.newData == nil {
+		newName = devNull
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "diff --git a/%s b/%s\n", p.name, p.name)
+	switch {
+	case p.oldData == nil && p.newMode != 0:
+		fmt.Fprintf(&b, "new file mode %06o\n", gitMode(p.newMode))
+	case p.newData == nil && p.oldMode != 0:
+		fmt.Fprintf(&b, "deleted file mode %06o\n", gitMode(p.oldMode))
+	case p.oldMode != p.newMode && p.oldData != nil && p.newData != nil:
+		fmt.Fprintf(&b, "old mode %06o\nnew mode %06o\n", gitMode(p.oldMode), gitMode(p.newMode))
+	}
+	b.WriteString(diff.Unified(oldName, newName, p.oldData, p.newData, options...))
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// gitMode converts the mode of a regular file to the form used by git.
+func gitMode(m fs.FileMode) uint32 {
+	const regular = 0o100000
+	return regular | uint32(m.Perm())
+}
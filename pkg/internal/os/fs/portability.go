@@ -0,0 +1,350 @@
+package fs
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	// defaultMaxPathLength is MAX_PATH of Windows without the drive and the
+	// terminating NUL.
+	defaultMaxPathLength = 256
+	defaultMaxNameLength = 255
+)
+
+// PortabilityIssueKind is a kind of [PortabilityIssue].
+type PortabilityIssueKind int
+
+const (
+	// CaseCollision means that the path differs from another path only in case,
+	// so they are the same file on macOS and Windows.
+	CaseCollision PortabilityIssueKind = iota + 1
+	// NormalizationCollision means that the path differs from another path
+	// only in Unicode normalization, e.g. NFC and NFD, so they are the same
+	// file on macOS.
+	NormalizationCollision
+	// ReservedName means that a path element is a device name reserved on
+	// Windows, e.g. "CON" or "nul.txt".
+	ReservedName
+	// InvalidName means that a path element contains a character invalid on
+	// Windows, e.g. ':', or ends with a dot or a space.
+	InvalidName
+	// PathTooLong means that the path is longer than the limit.
+	PathTooLong
+	// NameTooLong means that a path element is longer than the limit.
+	NameTooLong
+)
+
+var portabilityIssueKindNames = []string{
+	"case collision", "normalization collision", "reserved name", "invalid name", "path too long", "name too long",
+}
+
+func (k PortabilityIssueKind) String() string {
+	if k < CaseCollision || k > NameTooLong {
+		return "unknown"
+	}
+	return portabilityIssueKindNames[k-1]
+}
+
+// PortabilityIssue is a path which is not portable across platforms.
+type PortabilityIssue struct {
+	Kind PortabilityIssueKind
+	// Path is the path written.
+	Path string
+	// Detail is the colliding path for the collisions, and the offending
+	// element otherwise.
+	Detail string
+}
+
+func (i PortabilityIssue) Error() string {
+	switch i.Kind {
+	case CaseCollision, NormalizationCollision:
+		return fmt.Sprintf("%s: %s with %s", i.Path, i.Kind, i.Detail)
+	default:
+		return fmt.Sprintf("%s: %s %q", i.Path, i.Kind, i.Detail)
+	}
+}
+
+// PortabilityError is the aggregated error of [Portability].
+type PortabilityError struct {
+	Issues []PortabilityIssue
+}
+
+func (e *PortabilityError) Error() string {
+	lines := make([]string, 0, len(e.Issues)+1)
+	lines = append(lines, fmt.Sprintf("%d portability issues:", len(e.Issues)))
+	for _, i := range e.Issues {
+		lines = append(lines, "\t"+i.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// PortabilityOption is an option for [NewPortability].
+type PortabilityOption func(*Portability)
+
+// WithPortabilityMaxPath sets the maximum length of a path in bytes,
+// the default is 256. Zero disables the check.
+func WithPortabilityMaxPath(n int) PortabilityOption {
+	return func(p *Portability) {
+		p.maxPath = n
+	}
+}
+
+// WithPortabilityMaxName sets the maximum length of a path element in bytes,
+// the default is 255. Zero disables the check.
+func WithPortabilityMaxName(n int) PortabilityOption {
+	return func(p *Portability) {
+		p.maxName = n
+	}
+}
+
+// WithPortabilityStrict makes the writes of paths with issues fail with
+// [*PortabilityError] instead of only recording the issues.
+func WithPortabilityStrict() PortabilityOption {
+	return func(p *Portability) {
+		p.strict = true
+	}
+}
+
+// Portability records the portability issues of the paths written through
+// [WithPortability]. Only the paths written through the [FS] are checked for
+// collisions, not the files which already exist. It is safe for concurrent use.
+type Portability struct {
+	maxPath int
+	maxName int
+	strict  bool
+
+	mu sync.Mutex
+	// paths maps the folded and normalized paths and their parent directories
+	// to the written ones.
+	paths  map[string]string
+	issues []PortabilityIssue
+	seen   map[PortabilityIssue]struct{}
+}
+
+// NewPortability returns [Portability] with the options.
+func NewPortability(options ...PortabilityOption) *Portability {
+	p := &Portability{
+		maxPath: defaultMaxPathLength,
+		maxName: defaultMaxNameLength,
+		paths:   map[string]string{},
+		seen:    map[PortabilityIssue]struct{}{},
+	}
+	for _, o := range options {
+		o(p)
+	}
+	return p
+}
+
+// Issues returns the issues found so far in the order of the writes.
+func (p *Portability) Issues() []PortabilityIssue {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return slices.Clone(p.issues)
+}
+
+// Err returns [*PortabilityError] with all the issues found so far,
+// or nil if there are none.
+func (p *Portability) Err() error {
+	if issues := p.Issues(); len(issues) > 0 {
+		return &PortabilityError{Issues: issues}
+	}
+	return nil
+}
+
+var (
+	caseFolder = cases.Fold()
+	// windowsReserved are the device names, which are reserved with any
+	// extension as well.
+	windowsReserved = func() map[string]struct{} {
+		names := map[string]struct{}{"CON": {}, "PRN": {}, "AUX": {}, "NUL": {}}
+		for _, prefix := range []string{"COM", "LPT"} {
+			for _, digit := range "123456789¹²³" {
+				names[prefix+string(digit)] = struct{}{}
+			}
+		}
+		return names
+	}()
+)
+
+// check records the path and returns its issues.
+func (p *Portability) check(name string) []PortabilityIssue {
+	clean := strings.TrimPrefix(path.Clean(name), "/")
+	if clean == "." {
+		return nil
+	}
+
+	var issues []PortabilityIssue
+	if p.maxPath > 0 && len(clean) > p.maxPath {
+		issues = append(issues, PortabilityIssue{Kind: PathTooLong, Path: name, Detail: clean})
+	}
+	for _, elem := range strings.Split(clean, "/") {
+		issues = append(issues, p.checkName(name, elem)...)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Every parent directory is checked as well, so that "A/x" and "a/y"
+	// collide on "A" and "a".
+	for prefix := clean; prefix != "."; prefix = path.Dir(prefix) {
+		normalized := norm.NFC.String(prefix)
+		key := caseFolder.String(normalized)
+		other, ok := p.paths[key]
+		switch {
+		case !ok:
+			p.paths[key] = prefix
+			continue
+		case other == prefix:
+			continue
+		case norm.NFC.String(other) == normalized:
+			issues = append(issues, PortabilityIssue{Kind: NormalizationCollision, Path: prefix, Detail: other})
+		default:
+			issues = append(issues, PortabilityIssue{Kind: CaseCollision, Path: prefix, Detail: other})
+		}
+		break
+	}
+
+	var added []PortabilityIssue
+	for _, i := range issues {
+		if _, ok := p.seen[i]; !ok {
+			p.seen[i] = struct{}{}
+			added = append(added, i)
+		}
+	}
+	p.issues = append(p.issues, added...)
+	return issues
+}
+
+func (p *Portability) checkName(name, elem string) []PortabilityIssue {
+	var issues []PortabilityIssue
+	if p.maxName > 0 && len(elem) > p.maxName {
+		issues = append(issues, PortabilityIssue{Kind: NameTooLong, Path: name, Detail: elem})
+	}
+
+	base, _, _ := strings.Cut(elem, ".")
+	if _, ok := windowsReserved[strings.ToUpper(strings.TrimRight(base, " "))]; ok {
+		issues = append(issues, PortabilityIssue{Kind: ReservedName, Path: name, Detail: elem})
+	}
+
+	invalid := !utf8.ValidString(elem) || strings.HasSuffix(elem, ".") && elem != "." && elem != ".." ||
+		strings.HasSuffix(elem, " ") || strings.ContainsAny(elem, `<>:"\|?*`) ||
+		strings.ContainsFunc(elem, func(r rune) bool { return r < ' ' })
+	if invalid {
+		issues = append(issues, PortabilityIssue{Kind: InvalidName, Path: name, Detail: elem})
+	}
+	return issues
+}
+
+// forget removes the path and everything under it, so that it can be
+// written again with a different case.
+func (p *Portability) forget(name string) {
+	clean := strings.TrimPrefix(path.Clean(name), "/")
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for key, written := range p.paths {
+		if written == clean || strings.HasPrefix(written, clean+"/") {
+			delete(p.paths, key)
+		}
+	}
+}
+
+type portable struct {
+	wrapped
+	p *Portability
+}
+
+// WithPortability is an option for [NewFS] that wraps the [FS] so that the paths
+// created by WriteFile, OpenFile, MkdirAll and Rename are checked for the issues
+// which make a tree unusable on another platform: names differing only in case
+// or in Unicode normalization, names reserved or invalid on Windows and paths
+// longer than the limits. The issues are recorded and reported at once by
+// [Portability.Err], or returned by the write with [WithPortabilityStrict]:
+//
+//	p := fs.NewPortability()
+//	f := fs.NewFS(fs.NewRealFS(), fs.WithPortability(p))
+//	// ... generate files ...
+//	if err := p.Err(); err != nil {
+//		return err
+//	}
+func WithPortability(p *Portability) Option {
+	return func(fs FS) FS {
+		return &portable{wrapped: wrapped{fs}, p: p}
+	}
+}
+
+// check checks the path, returning an error only in the strict mode.
+func (p *portable) check(name string) error {
+	issues := p.p.check(name)
+	if p.p.strict && len(issues) > 0 {
+		p.p.forget(name)
+		return &PortabilityError{Issues: issues}
+	}
+	return nil
+}
+
+func (p *portable) OpenFile(name string, flag int, perm fs.FileMode) (WritableFile, error) {
+	if flag&os.O_CREATE != 0 {
+		if err := p.check(name); err != nil {
+			return nil, err
+		}
+	}
+	return p.FS.OpenFile(name, flag, perm)
+}
+
+func (p *portable) MkdirAll(name string, perm fs.FileMode) error {
+	if err := p.check(name); err != nil {
+		return err
+	}
+	return p.FS.MkdirAll(name, perm)
+}
+
+func (p *portable) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	if err := p.check(name); err != nil {
+		return err
+	}
+	return p.FS.WriteFile(name, data, perm)
+}
+
+func (p *portable) Rename(src, dst string) error {
+	if err := p.check(dst); err != nil {
+		return err
+	}
+	if err := p.FS.Rename(src, dst); err != nil {
+		return err
+	}
+	p.p.forget(src)
+	return nil
+}
+
+func (p *portable) Remove(name string) error {
+	if err := p.FS.Remove(name); err != nil {
+		return err
+	}
+	p.p.forget(name)
+	return nil
+}
+
+func (p *portable) RemoveAll(name string) error {
+	if err := p.FS.RemoveAll(name); err != nil {
+		return err
+	}
+	p.p.forget(name)
+	return nil
+}
+
+func (*portable) String() string {
+	return "WithPortability()"
+}
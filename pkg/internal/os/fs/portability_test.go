@@ -0,0 +1,92 @@
+package fs_test
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go.mws.cloud/util-toolset/pkg/internal/os/fs"
+)
+
+func TestPortability(t *testing.T) {
+	p := fs.NewPortability(fs.WithPortabilityMaxPath(40), fs.WithPortabilityMaxName(20))
+	f := fs.NewFS(fs.NewMapFS(), fs.WithPortability(p), fs.WithDirCreate(os.ModePerm))
+
+	for _, name := range []string{
+		"pkg/Foo.go",
+		"pkg/foo.go",
+		"pkg/foo.go",
+		"PKG/bar.go",
+		"café.txt",
+		"café.txt",
+		"CON",
+		"dir/nul.tar.gz",
+		"com1 .txt",
+		"a:b.txt",
+		"trailing.",
+		"trailing ",
+		strings.Repeat("n", 21),
+		"deep/" + strings.Repeat("d/", 20) + "x",
+		"ok/Ünïcode.txt",
+	} {
+		require.NoError(t, f.WriteFile(name, nil, 0o644), name)
+	}
+	require.NoError(t, f.MkdirAll("Dir", os.ModePerm))
+
+	w, err := f.OpenFile("stream/AUX.log", os.O_CREATE|os.O_WRONLY, 0o644)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	require.NoError(t, f.Rename("pkg/foo.go", "pkg/Baz.go"))
+	require.NoError(t, f.WriteFile("pkg/baz.go", nil, 0o644))
+	require.NoError(t, f.RemoveAll("pkg"))
+	require.NoError(t, f.WriteFile("Pkg/FOO.go", nil, 0o644))
+
+	err = p.Err()
+	var perr *fs.PortabilityError
+	require.True(t, errors.As(err, &perr))
+
+	var issues []string
+	for _, i := range perr.Issues {
+		issues = append(issues, i.Kind.String()+": "+i.Path+" "+i.Detail)
+	}
+	require.Equal(t, []string{
+		"case collision: pkg/foo.go pkg/Foo.go",
+		"case collision: PKG pkg",
+		"normalization collision: café.txt café.txt",
+		"reserved name: CON CON",
+		"reserved name: dir/nul.tar.gz nul.tar.gz",
+		"reserved name: com1 .txt com1 .txt",
+		"invalid name: a:b.txt a:b.txt",
+		"invalid name: trailing. trailing.",
+		"invalid name: trailing  trailing ",
+		"name too long: nnnnnnnnnnnnnnnnnnnnn nnnnnnnnnnnnnnnnnnnnn",
+		"path too long: deep/d/d/d/d/d/d/d/d/d/d/d/d/d/d/d/d/d/d/d/d/x deep/d/d/d/d/d/d/d/d/d/d/d/d/d/d/d/d/d/d/d/d/x",
+		"case collision: Dir dir",
+		"reserved name: stream/AUX.log AUX.log",
+		"case collision: pkg/baz.go pkg/Baz.go",
+	}, issues)
+	require.Contains(t, err.Error(), "14 portability issues:\n\tpkg/foo.go: case collision with pkg/Foo.go\n")
+	require.Len(t, p.Issues(), 14)
+}
+
+func TestPortabilityStrict(t *testing.T) {
+	p := fs.NewPortability(fs.WithPortabilityStrict())
+	f := fs.NewFS(fs.NewMapFS(), fs.WithPortability(p))
+
+	require.NoError(t, p.Err())
+	require.NoError(t, f.WriteFile("Foo.go", nil, 0o644))
+
+	err := f.WriteFile("foo.go", nil, 0o644)
+	var perr *fs.PortabilityError
+	require.ErrorAs(t, err, &perr)
+	require.Equal(t, []fs.PortabilityIssue{{Kind: fs.CaseCollision, Path: "foo.go", Detail: "Foo.go"}}, perr.Issues)
+	require.Equal(t, "1 portability issues:\n\tfoo.go: case collision with Foo.go", err.Error())
+
+	files, err := fs.List(f)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+}
@@ -0,0 +1,87 @@
+package fs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+)
+
+// readOnlyFile adapts [fs.File] to [WritableFile], all write operations
+// fail with [ErrReadOnly].
+type readOnlyFile struct {
+	fs.File
+	name string
+}
+
+var _ WritableFile = (*readOnlyFile)(nil)
+
+func (f *readOnlyFile) Name() string {
+	return f.name
+}
+
+func (f *readOnlyFile) ReadAt(p []byte, off int64) (int, error) {
+	if r, ok := f.File.(io.ReaderAt); ok {
+		return r.ReadAt(p, off)
+	}
+	return 0, f.error("readat", errors.ErrUnsupported)
+}
+
+func (f *readOnlyFile) Seek(offset int64, whence int) (int64, error) {
+	if s, ok := f.File.(io.Seeker); ok {
+		return s.Seek(offset, whence)
+	}
+	return 0, f.error("seek", errors.ErrUnsupported)
+}
+
+func (f *readOnlyFile) Readdir(count int) ([]fs.FileInfo, error) {
+	entries, err := f.readDir(count)
+	infos := make([]fs.FileInfo, 0, len(entries))
+	for _, e := range entries {
+		info, infoErr := e.Info()
+		if infoErr != nil {
+			return infos, infoErr
+		}
+		infos = append(infos, info)
+	}
+	return infos, err
+}
+
+func (f *readOnlyFile) Readdirnames(n int) ([]string, error) {
+	entries, err := f.readDir(n)
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	return names, err
+}
+
+func (f *readOnlyFile) readDir(n int) ([]fs.DirEntry, error) {
+	if d, ok := f.File.(fs.ReadDirFile); ok {
+		return d.ReadDir(n)
+	}
+	return nil, f.error("readdir", errors.ErrUnsupported)
+}
+
+func (*readOnlyFile) Sync() error {
+	return nil
+}
+
+func (f *readOnlyFile) Write([]byte) (int, error) {
+	return 0, f.error("write", ErrReadOnly)
+}
+
+func (f *readOnlyFile) WriteAt([]byte, int64) (int, error) {
+	return 0, f.error("write", ErrReadOnly)
+}
+
+func (f *readOnlyFile) WriteString(string) (int, error) {
+	return 0, f.error("write", ErrReadOnly)
+}
+
+func (f *readOnlyFile) Truncate(int64) error {
+	return f.error("truncate", ErrReadOnly)
+}
+
+func (f *readOnlyFile) error(op string, err error) error {
+	return &fs.PathError{Op: op, Path: f.name, Err: err}
+}
@@ -0,0 +1,324 @@
+package fs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// WriteResult is the result of writing a file recorded by [Report].
+type WriteResult int
+
+const (
+	// WriteCreated means that the file did not exist.
+	WriteCreated WriteResult = iota + 1
+	// WriteModified means that the content of the file was changed.
+	WriteModified
+	// WriteUnchanged means that the file was written with the same content,
+	// or it was not written by [WithChangedOnly].
+	WriteUnchanged
+	// WriteRemoved means that the file was removed or renamed.
+	WriteRemoved
+)
+
+var writeResultNames = []string{"created", "modified", "unchanged", "removed"}
+
+func (r WriteResult) String() string {
+	if r < WriteCreated || r > WriteRemoved {
+		return "unknown"
+	}
+	return writeResultNames[r-1]
+}
+
+// ReportEntry is the result of the writes of a path.
+type ReportEntry struct {
+	Path   string
+	Result WriteResult
+}
+
+// ReportCounts are the numbers of paths by result.
+type ReportCounts struct {
+	Created   int
+	Modified  int
+	Unchanged int
+	Removed   int
+}
+
+// String returns the summary, e.g. "2 created, 10 modified, 340 unchanged, 2 removed".
+func (c ReportCounts) String() string {
+	return fmt.Sprintf("%d created, %d modified, %d unchanged, %d removed", c.Created, c.Modified, c.Unchanged, c.Removed)
+}
+
+// ReportOption is an option for [NewReport].
+type ReportOption func(*Report)
+
+// WithReportLogger logs every recorded write at debug level.
+func WithReportLogger(logger *zap.Logger) ReportOption {
+	return func(r *Report) {
+		r.logger = logger
+	}
+}
+
+// Report records the result of every file written, renamed or removed through
+// [WithReport]. The results of several writes of a path are merged, e.g. a file
+// created and then modified is created, and a file created and then removed
+// is not reported at all. Directories are not recorded. It is safe for
+// concurrent use.
+type Report struct {
+	logger *zap.Logger
+
+	mu      sync.Mutex
+	results map[string]WriteResult
+}
+
+// NewReport returns an empty [Report] with the options.
+func NewReport(options ...ReportOption) *Report {
+	r := &Report{logger: zap.NewNop(), results: map[string]WriteResult{}}
+	for _, o := range options {
+		o(r)
+	}
+	return r
+}
+
+// Entries returns the recorded results sorted by path.
+func (r *Report) Entries() []ReportEntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entries := make([]ReportEntry, 0, len(r.results))
+	for p, result := range r.results {
+		entries = append(entries, ReportEntry{Path: p, Result: result})
+	}
+	slices.SortFunc(entries, func(l, r ReportEntry) int {
+		return strings.Compare(l.Path, r.Path)
+	})
+	return entries
+}
+
+// Counts returns the numbers of the recorded paths by result.
+func (r *Report) Counts() ReportCounts {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var c ReportCounts
+	for _, result := range r.results {
+		switch result {
+		case WriteCreated:
+			c.Created++
+		case WriteModified:
+			c.Modified++
+		case WriteUnchanged:
+			c.Unchanged++
+		case WriteRemoved:
+			c.Removed++
+		}
+	}
+	return c
+}
+
+// Log logs the counts at info level.
+func (r *Report) Log(logger *zap.Logger) {
+	c := r.Counts()
+	logger.Info("files written",
+		zap.Int("created", c.Created),
+		zap.Int("modified", c.Modified),
+		zap.Int("unchanged", c.Unchanged),
+		zap.Int("removed", c.Removed),
+	)
+}
+
+func (r *Report) record(name string, result WriteResult) {
+	p := path.Clean(name)
+	r.logger.Debug("file "+result.String(), zap.String("path", p))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	prev, ok := r.results[p]
+	switch {
+	case !ok:
+	case prev == WriteCreated && result == WriteRemoved:
+		delete(r.results, p)
+		return
+	case prev == WriteCreated && result != WriteCreated:
+		return
+	case prev == WriteRemoved && result == WriteCreated,
+		prev == WriteModified && result == WriteUnchanged:
+		result = WriteModified
+	}
+	r.results[p] = result
+}
+
+// resultWriter is implemented by wrappers which know the result of WriteFile
+// and OpenFile.
+type resultWriter interface {
+	writeFile(name string, data []byte, perm fs.FileMode) (WriteResult, error)
+	// openFile calls done with the result on Close, if it returns true.
+	openFile(name string, flag int, perm fs.FileMode, done func(WriteResult)) (WritableFile, bool, error)
+}
+
+type reportWrite struct {
+	wrapped
+	report *Report
+}
+
+// WithReport is an option for [NewFS] that wraps the [FS] so that the result of
+// every WriteFile, OpenFile for writing, Rename, Remove and RemoveAll is recorded
+// in the report. To tell modified files from unchanged ones the current content
+// is read before WriteFile, unless [WithChangedOnly] is applied right inside of
+// this option, which reports it instead:
+//
+//	report := fs.NewReport()
+//	f := fs.NewFS(fs.NewRealFS(), fs.WithReport(report), fs.WithChangedOnly())
+//	// ... generate files ...
+//	fmt.Println(report.Counts()) // 12 created, 0 modified, 340 unchanged, 2 removed
+func WithReport(r *Report) Option {
+	return func(fs FS) FS {
+		return &reportWrite{wrapped: wrapped{fs}, report: r}
+	}
+}
+
+func (r *reportWrite) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	if w, ok := baseLayer(r.FS).(resultWriter); ok {
+		result, err := w.writeFile(name, data, perm)
+		if err != nil {
+			return err
+		}
+		r.report.record(name, result)
+		return nil
+	}
+
+	actual, err := fs.ReadFile(r.FS, name)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err := r.FS.WriteFile(name, data, perm); err != nil {
+		return err
+	}
+
+	switch {
+	case err != nil:
+		r.report.record(name, WriteCreated)
+	case bytes.Equal(actual, data):
+		r.report.record(name, WriteUnchanged)
+	default:
+		r.report.record(name, WriteModified)
+	}
+	return nil
+}
+
+// OpenFile records the file opened for writing as created or modified,
+// as the content is not compared, unless [WithChangedOnly] applied right inside
+// of this option reports it.
+func (r *reportWrite) OpenFile(name string, flag int, perm fs.FileMode) (WritableFile, error) {
+	if flag&writeFlags == 0 {
+		return r.FS.OpenFile(name, flag, perm)
+	}
+
+	result := WriteModified
+	if !r.isFile(name) {
+		result = WriteCreated
+	}
+
+	var f WritableFile
+	var err error
+	if w, ok := baseLayer(r.FS).(resultWriter); ok {
+		var known bool
+		f, known, err = w.openFile(name, flag, perm, func(result WriteResult) {
+			r.report.record(name, result)
+		})
+		if known {
+			return f, err
+		}
+	} else {
+		f, err = r.FS.OpenFile(name, flag, perm)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &closeHookFile{WritableFile: f, onClose: func() error {
+		r.report.record(name, result)
+		return nil
+	}}, nil
+}
+
+// Rename records the renamed file or all the files of the renamed directory
+// as removed from src and created or modified in dst.
+func (r *reportWrite) Rename(src, dst string) error {
+	type move struct {
+		from, to string
+		existed  bool
+	}
+	var moves []move
+	if r.isFile(src) {
+		moves = append(moves, move{from: src, to: dst})
+	} else if files, err := ListDir(r.FS, src); err == nil {
+		for _, f := range files {
+			rel := strings.TrimPrefix(f.Name(), path.Clean(src)+"/")
+			moves = append(moves, move{from: f.Name(), to: path.Join(dst, rel)})
+		}
+	}
+	for i := range moves {
+		moves[i].existed = r.isFile(moves[i].to)
+	}
+
+	if err := r.FS.Rename(src, dst); err != nil {
+		return err
+	}
+	for _, m := range moves {
+		if m.existed {
+			r.report.record(m.to, WriteModified)
+		} else {
+			r.report.record(m.to, WriteCreated)
+		}
+		r.report.record(m.from, WriteRemoved)
+	}
+	return nil
+}
+
+func (r *reportWrite) Remove(name string) error {
+	file := r.isFile(name)
+	if err := r.FS.Remove(name); err != nil {
+		return err
+	}
+	if file {
+		r.report.record(name, WriteRemoved)
+	}
+	return nil
+}
+
+// RemoveAll records the removed file or all the files of the removed directory.
+func (r *reportWrite) RemoveAll(name string) error {
+	var removed []string
+	if r.isFile(name) {
+		removed = append(removed, name)
+	} else if files, err := ListDir(r.FS, name); err == nil {
+		for _, f := range files {
+			removed = append(removed, f.Name())
+		}
+	}
+
+	if err := r.FS.RemoveAll(name); err != nil {
+		return err
+	}
+	for _, p := range removed {
+		r.report.record(p, WriteRemoved)
+	}
+	return nil
+}
+
+func (r *reportWrite) isFile(name string) bool {
+	info, err := fs.Stat(r.FS, name)
+	return err == nil && !info.IsDir()
+}
+
+func (*reportWrite) String() string {
+	return "WithReport()"
+}
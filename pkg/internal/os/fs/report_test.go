@@ -0,0 +1,86 @@
+package fs_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"go.mws.cloud/util-toolset/pkg/internal/os/fs"
+)
+
+func TestReport(t *testing.T) {
+	base := fs.NewFS(fs.NewMapFS(), fs.WithDirCreate(os.ModePerm))
+	require.NoError(t, base.WriteFile("same.txt", []byte("same"), 0o644))
+	require.NoError(t, base.WriteFile("changed.txt", []byte("old"), 0o644))
+	require.NoError(t, base.WriteFile("old.txt", []byte("old"), 0o644))
+	require.NoError(t, base.WriteFile("dir/a.txt", []byte("a"), 0o644))
+	require.NoError(t, base.WriteFile("dir/sub/b.txt", []byte("b"), 0o644))
+
+	core, logs := observer.New(zapcore.DebugLevel)
+	for name, changedOnly := range map[string]bool{"plain": false, "changed only": true} {
+		t.Run(name, func(t *testing.T) {
+			snapshot, err := fs.NewSnapshot(base)
+			require.NoError(t, err)
+			mem, err := snapshot.FS()
+			require.NoError(t, err)
+
+			report := fs.NewReport(fs.WithReportLogger(zap.New(core)))
+			options := []fs.Option{fs.WithReport(report)}
+			if changedOnly {
+				options = append(options, fs.WithChangedOnly())
+			}
+			f := fs.NewFS(mem, append(options, fs.WithDirCreate(os.ModePerm))...)
+
+			require.NoError(t, f.WriteFile("same.txt", []byte("same"), 0o644))
+			require.NoError(t, f.WriteFile("changed.txt", []byte("new"), 0o644))
+			require.NoError(t, f.WriteFile("new.txt", []byte("1"), 0o644))
+			require.NoError(t, f.WriteFile("new.txt", []byte("2"), 0o644))
+			require.NoError(t, f.WriteFile("tmp.txt", []byte("tmp"), 0o644))
+			require.NoError(t, f.Remove("tmp.txt"))
+			require.NoError(t, f.Rename("old.txt", "renamed.txt"))
+			require.NoError(t, f.RemoveAll("dir"))
+
+			w, err := f.OpenFile("stream.txt", os.O_CREATE|os.O_WRONLY, 0o644)
+			require.NoError(t, err)
+			require.NoError(t, w.Close())
+
+			require.Equal(t, []fs.ReportEntry{
+				{Path: "changed.txt", Result: fs.WriteModified},
+				{Path: "dir/a.txt", Result: fs.WriteRemoved},
+				{Path: "dir/sub/b.txt", Result: fs.WriteRemoved},
+				{Path: "new.txt", Result: fs.WriteCreated},
+				{Path: "old.txt", Result: fs.WriteRemoved},
+				{Path: "renamed.txt", Result: fs.WriteCreated},
+				{Path: "same.txt", Result: fs.WriteUnchanged},
+				{Path: "stream.txt", Result: fs.WriteCreated},
+			}, report.Entries())
+			require.Equal(t, fs.ReportCounts{Created: 3, Modified: 1, Unchanged: 1, Removed: 3}, report.Counts())
+			require.Equal(t, "3 created, 1 modified, 1 unchanged, 3 removed", report.Counts().String())
+		})
+	}
+	require.Equal(t, "file unchanged", logs.FilterField(zap.String("path", "same.txt")).All()[0].Message)
+
+	summary, logs := observer.New(zapcore.InfoLevel)
+	fs.NewReport().Log(zap.New(summary))
+	require.Equal(t, 1, logs.FilterMessage("files written").FilterField(zap.Int("created", 0)).Len())
+}
+
+func TestReportRenameDir(t *testing.T) {
+	base := fs.NewFS(fs.NewMapFS(), fs.WithDirCreate(os.ModePerm))
+	require.NoError(t, base.WriteFile("d1/a.txt", []byte("a"), 0o644))
+	require.NoError(t, base.WriteFile("d1/sub/b.txt", []byte("b"), 0o644))
+
+	report := fs.NewReport()
+	f := fs.NewFS(base, fs.WithReport(report))
+	require.NoError(t, f.Rename("d1", "d2"))
+	require.Equal(t, []fs.ReportEntry{
+		{Path: "d1/a.txt", Result: fs.WriteRemoved},
+		{Path: "d1/sub/b.txt", Result: fs.WriteRemoved},
+		{Path: "d2/a.txt", Result: fs.WriteCreated},
+		{Path: "d2/sub/b.txt", Result: fs.WriteCreated},
+	}, report.Entries())
+}
@@ -0,0 +1,201 @@
+package fs
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"strconv"
+	"time"
+
+	"go.mws.cloud/util-toolset/pkg/os/env"
+)
+
+// SourceDateEpochEnv is the name of the environment variable with the build
+// timestamp, see https://reproducible-builds.org/specs/source-date-epoch/.
+const SourceDateEpochEnv = "SOURCE_DATE_EPOCH"
+
+// ReproduciblePolicy defines file modes and times applied by [WithReproducible]
+// and [WithArchiveReproducible]. Zero fields are not applied.
+type ReproduciblePolicy struct {
+	// ModTime is set as access and modification time of written entries.
+	ModTime time.Time
+	// FileMode is used for regular files without any executable bit.
+	FileMode fs.FileMode
+	// ExecMode is used for regular files with any executable bit.
+	ExecMode fs.FileMode
+	// DirMode is used for directories.
+	DirMode fs.FileMode
+}
+
+// DefaultReproduciblePolicy returns [ReproduciblePolicy] with 0o644 mode for
+// regular files, 0o755 mode for executables and directories, and the time
+// from [SourceDateEpochEnv] environment variable, 1980-01-01 00:00:00 UTC
+// if the variable is not set.
+func DefaultReproduciblePolicy(e env.Env) (ReproduciblePolicy, error) {
+	p := ReproduciblePolicy{
+		ModTime:  defaultArchiveModTime,
+		FileMode: 0o644,
+		ExecMode: 0o755,
+		DirMode:  0o755,
+	}
+
+	value, ok := e.LookupEnv(SourceDateEpochEnv)
+	if !ok || value == "" {
+		return p, nil
+	}
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return p, fmt.Errorf("parsing %s: %w", SourceDateEpochEnv, err)
+	}
+
+	p.ModTime = time.Unix(seconds, 0).UTC()
+	return p, nil
+}
+
+// Mode returns the mode for an entry with the permission bits perm.
+func (p ReproduciblePolicy) Mode(perm fs.FileMode, isDir bool) fs.FileMode {
+	var mode fs.FileMode
+	switch {
+	case isDir:
+		mode = p.DirMode
+	case perm&0o111 != 0:
+		mode = p.ExecMode
+	default:
+		mode = p.FileMode
+	}
+
+	if mode == 0 {
+		return perm.Perm()
+	}
+	return mode.Perm()
+}
+
+type reproducible struct {
+	wrapped
+	policy ReproduciblePolicy
+}
+
+// WithReproducible is an option for [NewFS] that wraps the [FS] so that files
+// and directories written with WriteFile, OpenFile, MkdirAll and Rename get
+// modes and times defined by the policy, regardless of the process umask.
+// Modification times of parent directories, which change when an entry is
+// created, are reset as well. It applies to [CopyFS] into the wrapped [FS].
+//
+// The wrapped [FS] must implement [ChmodFS] and [ChtimesFS]. Parent directories
+// created implicitly by [WithDirCreate] are covered only if it is applied
+// outside of this option:
+//
+//	fs.NewFS(fs.NewRealFS(), fs.WithDirCreate(os.ModePerm), fs.WithReproducible(policy))
+//
+// Use [WithArchiveReproducible] for archive writers.
+func WithReproducible(policy ReproduciblePolicy) Option {
+	return func(fs FS) FS {
+		return &reproducible{wrapped: wrapped{fs}, policy: policy}
+	}
+}
+
+func (r *reproducible) OpenFile(name string, flag int, perm fs.FileMode) (WritableFile, error) {
+	f, err := r.FS.OpenFile(name, flag, r.policy.Mode(perm, false))
+	if err != nil || flag&writeFlags == 0 {
+		return f, err
+	}
+
+	return &closeHookFile{WritableFile: f, onClose: func() error {
+		return r.apply(name, r.policy.Mode(perm, false))
+	}}, nil
+}
+
+func (r *reproducible) MkdirAll(name string, perm fs.FileMode) error {
+	// Only directories created by this call are changed.
+	created := []string{}
+	for dir := path.Clean(name); dir != "." && dir != "/"; dir = path.Dir(dir) {
+		if _, err := fs.Stat(r.FS, dir); err == nil {
+			break
+		}
+		created = append(created, dir)
+	}
+
+	mode := r.policy.Mode(perm, true)
+	if err := r.FS.MkdirAll(name, mode); err != nil {
+		return err
+	}
+
+	for i := len(created) - 1; i >= 0; i-- {
+		if err := r.apply(created[i], mode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *reproducible) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	mode := r.policy.Mode(perm, false)
+	if err := r.FS.WriteFile(name, data, mode); err != nil {
+		return err
+	}
+	return r.apply(name, mode)
+}
+
+func (r *reproducible) Rename(src, dst string) error {
+	if err := r.FS.Rename(src, dst); err != nil {
+		return err
+	}
+	return errors.Join(r.resetParent(src), r.resetParent(dst))
+}
+
+// apply sets the mode and the times of the entry and resets the time of its parent.
+func (r *reproducible) apply(name string, mode fs.FileMode) error {
+	if err := Chmod(r.FS, name, mode); err != nil {
+		return err
+	}
+	if !r.policy.ModTime.IsZero() {
+		if err := Chtimes(r.FS, name, r.policy.ModTime, r.policy.ModTime); err != nil {
+			return err
+		}
+	}
+	return r.resetParent(name)
+}
+
+func (r *reproducible) resetParent(name string) error {
+	dir := path.Dir(path.Clean(name))
+	if r.policy.ModTime.IsZero() || dir == "." || dir == "/" {
+		return nil
+	}
+
+	err := Chtimes(r.FS, dir, r.policy.ModTime, r.policy.ModTime)
+	if errors.Is(err, os.ErrNotExist) {
+		// The parent may be outside of the file system, e.g. a base dir.
+		return nil
+	}
+	return err
+}
+
+// closeHookFile calls onClose after the file is successfully closed.
+type closeHookFile struct {
+	WritableFile
+	onClose func() error
+}
+
+func (f *closeHookFile) Close() error {
+	if err := f.WritableFile.Close(); err != nil {
+		return err
+	}
+	return f.onClose()
+}
+
+// WithArchiveReproducible applies the modes and the modification time of the
+// policy to archive entries, see [WithReproducible].
+func WithArchiveReproducible(policy ReproduciblePolicy) ArchiveOption {
+	return func(c *archiveConfig) {
+		if !policy.ModTime.IsZero() {
+			c.modTime = policy.ModTime
+		}
+		c.mode = policy.Mode
+	}
+}
+
+func (*reproducible) String() string {
+	return "WithReproducible()"
+}
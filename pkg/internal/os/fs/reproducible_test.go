@@ -0,0 +1,98 @@
+package fs_test
+
+import (
+	"bytes"
+	iofs "io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"go.mws.cloud/util-toolset/pkg/internal/os/fs"
+	"go.mws.cloud/util-toolset/pkg/os/env"
+)
+
+func TestDefaultReproduciblePolicy(t *testing.T) {
+	e := env.NewMapEnv()
+	p, err := fs.DefaultReproduciblePolicy(e)
+	require.NoError(t, err)
+	require.Equal(t, time.Date(1980, time.January, 1, 0, 0, 0, 0, time.UTC), p.ModTime)
+
+	e[fs.SourceDateEpochEnv] = "1700000000"
+	p, err = fs.DefaultReproduciblePolicy(e)
+	require.NoError(t, err)
+	require.Equal(t, time.Unix(1700000000, 0).UTC(), p.ModTime)
+
+	e[fs.SourceDateEpochEnv] = "yesterday"
+	_, err = fs.DefaultReproduciblePolicy(e)
+	require.Error(t, err)
+
+	require.Equal(t, iofs.FileMode(0o644), p.Mode(0o600, false))
+	require.Equal(t, iofs.FileMode(0o755), p.Mode(0o700, false))
+	require.Equal(t, iofs.FileMode(0o755), p.Mode(0o700, true))
+	require.Equal(t, iofs.FileMode(0o600), fs.ReproduciblePolicy{}.Mode(0o600, false))
+}
+
+func TestReproducible(t *testing.T) {
+	dir := t.TempDir()
+	policy, err := fs.DefaultReproduciblePolicy(env.MapEnv{fs.SourceDateEpochEnv: "1700000000"})
+	require.NoError(t, err)
+
+	f := fs.NewFS(
+		fs.NewRealFS(),
+		fs.WithBaseDir(dir),
+		fs.WithDirCreate(0o700),
+		fs.WithAtomicWrite(),
+		fs.WithReproducible(policy),
+	)
+
+	require.NoError(t, f.WriteFile("gen/a.txt", []byte("a"), 0o600))
+	require.NoError(t, f.MkdirAll("gen/empty/nested", 0o700))
+
+	w, err := f.OpenFile("gen/run.sh", os.O_CREATE|os.O_WRONLY, 0o700)
+	require.NoError(t, err)
+	_, err = w.WriteString("#!/bin/sh")
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	src := fs.NewFS(fs.NewMapFS(), fs.WithDirCreate(os.ModePerm))
+	require.NoError(t, src.WriteFile("copied/b.txt", []byte("b"), 0o666))
+	require.NoError(t, fs.CopyFS(fs.NewFS(f, fs.WithBaseDir("gen")), src))
+
+	for name, mode := range map[string]iofs.FileMode{
+		"gen":              iofs.ModeDir | 0o755,
+		"gen/a.txt":        0o644,
+		"gen/empty":        iofs.ModeDir | 0o755,
+		"gen/empty/nested": iofs.ModeDir | 0o755,
+		"gen/run.sh":       0o755,
+		"gen/copied":       iofs.ModeDir | 0o755,
+		"gen/copied/b.txt": 0o644,
+	} {
+		info, err := os.Stat(filepath.Join(dir, name))
+		require.NoError(t, err)
+		require.Equal(t, mode, info.Mode(), name)
+		require.Equal(t, policy.ModTime, info.ModTime().UTC(), name)
+	}
+}
+
+func TestArchiveReproducible(t *testing.T) {
+	policy := fs.ReproduciblePolicy{ModTime: time.Unix(1700000000, 0), FileMode: 0o644, ExecMode: 0o755, DirMode: 0o755}
+
+	var b bytes.Buffer
+	w := fs.NewTarWriter(&b, fs.WithArchiveReproducible(policy))
+	require.NoError(t, w.MkdirAll("dir", 0o700))
+	require.NoError(t, w.WriteFile("dir/a.txt", nil, 0o600))
+	require.NoError(t, w.Close())
+
+	tarFS, err := fs.NewTarFS(&b)
+	require.NoError(t, err)
+
+	for name, mode := range map[string]iofs.FileMode{"dir": iofs.ModeDir | 0o755, "dir/a.txt": 0o644} {
+		info, err := iofs.Stat(tarFS, name)
+		require.NoError(t, err)
+		require.Equal(t, mode, info.Mode(), name)
+		require.Equal(t, policy.ModTime.UTC(), info.ModTime().UTC(), name)
+	}
+}
@@ -0,0 +1,10 @@
+package rotate
+
+import "time"
+
+// WithClock replaces the clock of [Writer].
+func WithClock(now func() time.Time) Option {
+	return func(w *Writer) {
+		w.now = now
+	}
+}
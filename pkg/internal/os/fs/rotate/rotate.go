@@ -0,0 +1,352 @@
+// Package rotate provides a log file writer on top of [fs.FS] which rotates
+// the file by size and time.
+package rotate
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	iofs "io/fs"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+
+	"go.mws.cloud/util-toolset/pkg/internal/os/fs"
+)
+
+const (
+	// timeLayout is the layout of the timestamps in the names of rotated
+	// files, it sorts in chronological order.
+	timeLayout = "20060102T150405.000000000"
+	gzipSuffix = ".gz"
+)
+
+// Option is an option for [New].
+type Option func(*Writer)
+
+// WithMaxSize rotates the file before a write which would make it larger than
+// n bytes. A single write larger than n is written to a new file as a whole.
+func WithMaxSize(n int64) Option {
+	return func(w *Writer) {
+		w.maxSize = n
+	}
+}
+
+// WithInterval rotates the file at the first write after every multiple of d
+// since the zero time in UTC, e.g. at midnight UTC for 24 hours.
+func WithInterval(d time.Duration) Option {
+	return func(w *Writer) {
+		w.interval = d
+	}
+}
+
+// WithMaxBackups keeps at most n rotated files, the oldest are removed.
+func WithMaxBackups(n int) Option {
+	return func(w *Writer) {
+		w.maxBackups = n
+	}
+}
+
+// WithMaxAge removes the rotated files older than d.
+func WithMaxAge(d time.Duration) Option {
+	return func(w *Writer) {
+		w.maxAge = d
+	}
+}
+
+// WithCompress compresses the rotated files with gzip.
+func WithCompress() Option {
+	return func(w *Writer) {
+		w.compress = true
+	}
+}
+
+// WithPerm sets the permissions of the created files, the default is 0o644.
+func WithPerm(perm iofs.FileMode) Option {
+	return func(w *Writer) {
+		w.perm = perm
+	}
+}
+
+// Writer is an [io.WriteCloser] which appends to the named file and rotates it:
+// the file is renamed to NAME-TIMESTAMP.EXT, e.g. "app-20261019T120000.000000000.log"
+// for "app.log", and a new file is created. Retention and compression of the
+// rotated files run in the background after a rotation, Close waits for them.
+//
+// It is safe for concurrent use and implements [zapcore.WriteSyncer]:
+//
+//	w := rotate.New(fs.NewRealFS(), "agent.log", rotate.WithMaxSize(100<<20), rotate.WithCompress())
+//	defer w.Close()
+//	logger := zap.New(zapcore.NewCore(zapcore.NewJSONEncoder(cfg), w, zap.InfoLevel))
+type Writer struct {
+	fs         fs.FS
+	name       string
+	maxSize    int64
+	interval   time.Duration
+	maxBackups int
+	maxAge     time.Duration
+	compress   bool
+	perm       iofs.FileMode
+	// now is the clock, it is replaced in the tests.
+	now func() time.Time
+
+	mu     sync.Mutex
+	file   fs.WritableFile
+	size   int64
+	next   time.Time
+	closed bool
+
+	// millMu serializes the retention and compression runs, wg tracks them.
+	millMu sync.Mutex
+	wg     sync.WaitGroup
+	// millErr is the error of the last run, it is returned by Close.
+	millErr error
+}
+
+var _ zapcore.WriteSyncer = (*Writer)(nil)
+
+// New returns [Writer] of the named file of f. The file is opened on the first
+// write, an existing file is appended to.
+func New(f fs.FS, name string, options ...Option) *Writer {
+	w := &Writer{fs: f, name: path.Clean(name), perm: 0o644, now: time.Now}
+	for _, o := range options {
+		o(w)
+	}
+	return w
+}
+
+// Write appends p to the file, rotating it first if it is due.
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return 0, &iofs.PathError{Op: "write", Path: w.name, Err: iofs.ErrClosed}
+	}
+	if w.file == nil {
+		if err := w.open(); err != nil {
+			return 0, err
+		}
+	}
+	if w.due(int64(len(p))) {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Sync commits the written data to the storage.
+func (w *Writer) Sync() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+	return w.file.Sync()
+}
+
+// Rotate rotates the file now, e.g. on SIGHUP. An empty file is not rotated.
+func (w *Writer) Rotate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return &iofs.PathError{Op: "rotate", Path: w.name, Err: iofs.ErrClosed}
+	}
+	if w.file == nil {
+		if err := w.open(); err != nil {
+			return err
+		}
+	}
+	return w.rotate()
+}
+
+// Close closes the file and waits for the background retention and
+// compression, returning the error of the last run. Subsequent calls are no-ops.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	var err error
+	if w.file != nil {
+		err = w.file.Close()
+		w.file = nil
+	}
+	w.mu.Unlock()
+
+	w.wg.Wait()
+	return errors.Join(err, w.millErr)
+}
+
+func (w *Writer) open() error {
+	f, err := w.fs.OpenFile(w.name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, w.perm)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return errors.Join(err, f.Close())
+	}
+
+	w.file, w.size = f, info.Size()
+	if w.interval > 0 {
+		// The modification time of an existing file is used, so that a file
+		// left by a previous run is rotated when it is due.
+		started := w.now()
+		if w.size > 0 {
+			started = info.ModTime()
+		}
+		w.next = started.UTC().Truncate(w.interval).Add(w.interval)
+	}
+	return nil
+}
+
+func (w *Writer) due(n int64) bool {
+	if w.size == 0 {
+		return false
+	}
+	return w.maxSize > 0 && w.size+n > w.maxSize || w.interval > 0 && !w.now().Before(w.next)
+}
+
+// rotate renames the current file, opens a new one and starts the retention
+// and compression in the background.
+func (w *Writer) rotate() error {
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	w.file = nil
+
+	if w.size > 0 {
+		if err := w.fs.Rename(w.name, w.rotatedName()); err != nil {
+			return err
+		}
+	}
+	if err := w.open(); err != nil {
+		return err
+	}
+
+	if w.compress || w.maxBackups > 0 || w.maxAge > 0 {
+		w.wg.Go(w.mill)
+	}
+	return nil
+}
+
+func (w *Writer) split() (string, string) {
+	ext := path.Ext(w.name)
+	return strings.TrimSuffix(w.name, ext), ext
+}
+
+// rotatedName returns a name for the rotated file which is not taken.
+func (w *Writer) rotatedName() string {
+	prefix, ext := w.split()
+	for t := w.now().UTC(); ; t = t.Add(time.Nanosecond) {
+		name := prefix + "-" + t.Format(timeLayout) + ext
+		if _, err := iofs.Stat(w.fs, name); errors.Is(err, iofs.ErrNotExist) {
+			return name
+		}
+	}
+}
+
+// rotated is a rotated file.
+type rotated struct {
+	name string
+	time time.Time
+}
+
+// rotatedFiles returns the rotated files, the newest first.
+func (w *Writer) rotatedFiles() ([]rotated, error) {
+	prefix, ext := w.split()
+	entries, err := w.fs.ReadDir(path.Dir(w.name))
+	if err != nil {
+		return nil, err
+	}
+
+	base := path.Base(prefix) + "-"
+	var files []rotated
+	for _, e := range entries {
+		stamp, ok := strings.CutPrefix(strings.TrimSuffix(e.Name(), gzipSuffix), base)
+		if !ok || e.IsDir() {
+			continue
+		}
+		stamp, ok = strings.CutSuffix(stamp, ext)
+		if !ok {
+			continue
+		}
+		t, err := time.Parse(timeLayout, stamp)
+		if err != nil {
+			continue
+		}
+		files = append(files, rotated{name: path.Join(path.Dir(w.name), e.Name()), time: t})
+	}
+	slices.SortFunc(files, func(l, r rotated) int {
+		return r.time.Compare(l.time)
+	})
+	return files, nil
+}
+
+// mill removes the rotated files exceeding the retention and compresses the rest.
+func (w *Writer) mill() {
+	w.millMu.Lock()
+	defer w.millMu.Unlock()
+
+	files, err := w.rotatedFiles()
+	if err != nil {
+		w.millErr = err
+		return
+	}
+
+	var errs []error
+	for i, f := range files {
+		if w.maxBackups > 0 && i >= w.maxBackups || w.maxAge > 0 && w.now().Sub(f.time) > w.maxAge {
+			if err := w.fs.Remove(f.name); err != nil && !errors.Is(err, iofs.ErrNotExist) {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		if w.compress && !strings.HasSuffix(f.name, gzipSuffix) {
+			errs = append(errs, w.gzip(f.name))
+		}
+	}
+	w.millErr = errors.Join(errs...)
+}
+
+// gzip compresses the named file to NAME.gz and removes it.
+func (w *Writer) gzip(name string) (rErr error) {
+	src, err := w.fs.Open(name)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		rErr = errors.Join(rErr, src.Close())
+		if rErr == nil {
+			rErr = w.fs.Remove(name)
+		}
+	}()
+
+	dst, err := w.fs.OpenFile(name+gzipSuffix, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, w.perm)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if rErr != nil {
+			rErr = errors.Join(rErr, w.fs.Remove(name+gzipSuffix))
+		}
+	}()
+
+	gz := gzip.NewWriter(dst)
+	_, err = io.Copy(gz, src)
+	return errors.Join(err, gz.Close(), dst.Close())
+}
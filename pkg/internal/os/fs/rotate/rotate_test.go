@@ -0,0 +1,180 @@
+package rotate_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"go.mws.cloud/util-toolset/pkg/internal/os/fs"
+	"go.mws.cloud/util-toolset/pkg/internal/os/fs/rotate"
+	"go.mws.cloud/util-toolset/pkg/internal/testing/fstest"
+)
+
+func readDir(t *testing.T, f fs.FS, dir string) (string, []string) {
+	t.Helper()
+
+	entries, err := f.ReadDir(dir)
+	require.NoError(t, err)
+
+	var current string
+	var rotated []string
+	for _, e := range entries {
+		data, err := fs.ReadFile(f, dir+"/"+e.Name())
+		require.NoError(t, err)
+		if strings.HasSuffix(e.Name(), ".gz") {
+			gz, err := gzip.NewReader(bytes.NewReader(data))
+			require.NoError(t, err)
+			data, err = io.ReadAll(gz)
+			require.NoError(t, err)
+		}
+		if e.Name() == "app.log" {
+			current = string(data)
+			continue
+		}
+		require.Regexp(t, `^app-\d{8}T\d{6}\.\d{9}\.log(\.gz)?$`, e.Name())
+		rotated = append(rotated, string(data))
+	}
+	return current, rotated
+}
+
+func TestWriterMaxSize(t *testing.T) {
+	fstest.RunFS(t, func(t *testing.T, f fs.FS) {
+		require.NoError(t, f.MkdirAll("logs", os.ModePerm))
+		require.NoError(t, f.WriteFile("logs/app.log", []byte("old\n"), 0o644))
+
+		w := rotate.New(f, "logs/app.log", rotate.WithMaxSize(10))
+		for _, line := range []string{"1234\n", "5678\n", "9\n", "too long line\n", "x\n"} {
+			_, err := w.Write([]byte(line))
+			require.NoError(t, err)
+		}
+		require.NoError(t, w.Sync())
+		require.NoError(t, w.Close())
+		require.NoError(t, w.Close())
+
+		_, err := w.Write([]byte("closed"))
+		require.ErrorIs(t, err, os.ErrClosed)
+
+		current, rotated := readDir(t, f, "logs")
+		require.Equal(t, "x\n", current)
+		require.Equal(t, []string{"old\n1234\n", "5678\n9\n", "too long line\n"}, rotated)
+	}, fs.WithDirCreate(os.ModePerm))
+}
+
+func TestWriterRetentionAndCompress(t *testing.T) {
+	f := fs.NewMapFS()
+	w := rotate.New(f, "app.log", rotate.WithMaxSize(1), rotate.WithMaxBackups(2), rotate.WithCompress())
+	for i := range 5 {
+		_, err := fmt.Fprint(w, i)
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+
+	current, rotated := readDir(t, f, ".")
+	require.Equal(t, "4", current)
+	require.Equal(t, []string{"2", "3"}, rotated)
+
+	entries, err := f.ReadDir(".")
+	require.NoError(t, err)
+	for _, e := range entries[:2] {
+		require.True(t, strings.HasSuffix(e.Name(), ".log.gz"), e.Name())
+	}
+}
+
+// clock is a fake clock, it is read by the retention in the background.
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newClock() *clock {
+	return &clock{now: time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)}
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+}
+
+func TestWriterMaxAge(t *testing.T) {
+	f := fs.NewMapFS()
+	c := newClock()
+	w := rotate.New(f, "app.log", rotate.WithMaxAge(20*time.Minute), rotate.WithClock(c.Now))
+	_, err := w.Write([]byte("a"))
+	require.NoError(t, err)
+	require.NoError(t, w.Rotate())
+	c.Advance(30 * time.Minute)
+	_, err = w.Write([]byte("b"))
+	require.NoError(t, err)
+	require.NoError(t, w.Rotate())
+	_, err = w.Write([]byte("c"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	current, rotated := readDir(t, f, ".")
+	require.Equal(t, "c", current)
+	require.Equal(t, []string{"b"}, rotated)
+}
+
+func TestWriterInterval(t *testing.T) {
+	f := fs.NewMapFS()
+	c := newClock()
+	w := rotate.New(f, "app.log", rotate.WithInterval(time.Hour), rotate.WithClock(c.Now))
+	_, err := w.Write([]byte("a"))
+	require.NoError(t, err)
+	c.Advance(59 * time.Minute)
+	_, err = w.Write([]byte("b"))
+	require.NoError(t, err)
+	c.Advance(time.Minute)
+	_, err = w.Write([]byte("c"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	current, rotated := readDir(t, f, ".")
+	require.Equal(t, "c", current)
+	require.Equal(t, []string{"ab"}, rotated)
+}
+
+func TestWriterZap(t *testing.T) {
+	f := fs.NewMapFS()
+	w := rotate.New(f, "app.log", rotate.WithMaxSize(200))
+	logger := zap.New(zapcore.NewCore(zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()), w, zap.InfoLevel))
+
+	var wg sync.WaitGroup
+	for i := range 4 {
+		wg.Go(func() {
+			for j := range 25 {
+				logger.Info("message", zap.Int("worker", i), zap.Int("n", j))
+			}
+		})
+	}
+	wg.Wait()
+	require.NoError(t, logger.Sync())
+	require.NoError(t, w.Close())
+
+	current, rotated := readDir(t, f, ".")
+	lines := strings.Count(current, "\n")
+	for _, data := range rotated {
+		require.LessOrEqual(t, len(data), 200)
+		lines += strings.Count(data, "\n")
+	}
+	require.Equal(t, 100, lines)
+}
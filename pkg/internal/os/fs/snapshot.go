@@ -0,0 +1,273 @@
+package fs
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"go.mws.cloud/util-toolset/pkg/utils/consterr"
+)
+
+// ErrInvalidSnapshot is returned when a serialized [Snapshot] cannot be parsed.
+const ErrInvalidSnapshot = consterr.Error("invalid snapshot")
+
+// Snapshot is an immutable copy of all the files and directories of a file
+// system with their contents and permission bits. It is intended for test
+// fixtures: build [FS] with [NewMapFS] once, take a snapshot of it and get
+// a fresh copy for every test case with [Snapshot.FS].
+type Snapshot struct {
+	entries []snapshotEntry
+}
+
+// snapshotEntry is a file or a directory (if dir is true) of [Snapshot].
+type snapshotEntry struct {
+	path string
+	dir  bool
+	perm fs.FileMode
+	data []byte
+}
+
+// NewSnapshot returns [Snapshot] of all the files and directories of f,
+// listed with [List].
+func NewSnapshot(f ReadOnlyFS) (*Snapshot, error) {
+	files, err := List(f, WithListDirs())
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Snapshot{entries: make([]snapshotEntry, 0, len(files))}
+	for _, info := range files {
+		e := snapshotEntry{path: info.Name(), dir: info.IsDir(), perm: info.Mode().Perm()}
+		if !e.dir {
+			if e.data, err = fs.ReadFile(f, e.path); err != nil {
+				return nil, err
+			}
+		}
+		s.entries = append(s.entries, e)
+	}
+	return s, nil
+}
+
+// Clone returns a copy of f built with [NewMapFS].
+func Clone(f ReadOnlyFS) (FS, error) {
+	s, err := NewSnapshot(f)
+	if err != nil {
+		return nil, err
+	}
+	return s.FS()
+}
+
+// FS returns a new [FS] built with [NewMapFS] containing the snapshot.
+func (s *Snapshot) FS() (FS, error) {
+	f := NewMapFS()
+	if err := s.write(f); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Restore removes all the files and directories from f and writes the
+// snapshot into it. f must implement [ChmodFS] to restore the permission bits.
+func (s *Snapshot) Restore(f FS) error {
+	if m, ok := f.(*mapFS); ok {
+		// Map file system has no single root to remove, see [mapFS.list].
+		for _, info := range m.list(listConfig{dirs: true}) {
+			// Relative and absolute paths may refer to the same entry.
+			if dir := path.Dir(info.Name()); dir != "." && dir != "/" || !m.exists(info.Name()) {
+				continue
+			}
+			if err := m.RemoveAll(info.Name()); err != nil {
+				return err
+			}
+		}
+	} else {
+		entries, err := f.ReadDir(".")
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if err := f.RemoveAll(e.Name()); err != nil {
+				return err
+			}
+		}
+	}
+
+	return s.write(f)
+}
+
+func (s *Snapshot) write(f FS) error {
+	for _, e := range s.entries {
+		if e.dir {
+			if err := f.MkdirAll(e.path, e.perm); err != nil {
+				return err
+			}
+		} else {
+			if dir := path.Dir(e.path); dir != "." {
+				if err := f.MkdirAll(dir, fs.ModePerm); err != nil {
+					return err
+				}
+			}
+			if err := f.WriteFile(e.path, e.data, e.perm); err != nil {
+				return err
+			}
+		}
+
+		// Permission bits of the created entries are affected by umask.
+		if err := Chmod(f, e.path, e.perm); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// snapshotJSONEntry is the JSON representation of [snapshotEntry].
+// Content is stored as is if it is valid UTF-8, otherwise it is encoded
+// with base64 and Encoding is set.
+type snapshotJSONEntry struct {
+	Path     string `json:"path"`
+	Dir      bool   `json:"dir,omitempty"`
+	Mode     string `json:"mode"`
+	Content  string `json:"content,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+const snapshotBase64 = "base64"
+
+// MarshalJSON implements [json.Marshaler], the snapshot is encoded as an
+// array of entries with paths, octal permission bits and contents.
+func (s *Snapshot) MarshalJSON() ([]byte, error) {
+	entries := make([]snapshotJSONEntry, len(s.entries))
+	for i, e := range s.entries {
+		entries[i] = snapshotJSONEntry{Path: e.path, Dir: e.dir, Mode: formatPerm(e.perm)}
+		switch {
+		case e.dir:
+		case utf8.Valid(e.data):
+			entries[i].Content = string(e.data)
+		default:
+			entries[i].Content = base64.StdEncoding.EncodeToString(e.data)
+			entries[i].Encoding = snapshotBase64
+		}
+	}
+	return json.Marshal(entries)
+}
+
+// UnmarshalJSON implements [json.Unmarshaler], see [Snapshot.MarshalJSON].
+func (s *Snapshot) UnmarshalJSON(data []byte) error {
+	var entries []snapshotJSONEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return err
+	}
+
+	s.entries = make([]snapshotEntry, len(entries))
+	for i, je := range entries {
+		perm, err := parsePerm(je.Mode)
+		if err != nil {
+			return fmt.Errorf("%w: entry %q: %w", ErrInvalidSnapshot, je.Path, err)
+		}
+
+		e := snapshotEntry{path: je.Path, dir: je.Dir, perm: perm}
+		switch {
+		case e.dir:
+		case je.Encoding == snapshotBase64:
+			if e.data, err = base64.StdEncoding.DecodeString(je.Content); err != nil {
+				return fmt.Errorf("%w: entry %q: %w", ErrInvalidSnapshot, je.Path, err)
+			}
+		case je.Encoding == "":
+			e.data = []byte(je.Content)
+		default:
+			return fmt.Errorf("%w: entry %q: unknown encoding %q", ErrInvalidSnapshot, je.Path, je.Encoding)
+		}
+		s.entries[i] = e
+	}
+	return nil
+}
+
+// Txtar returns the snapshot as a txtar archive, see [NewTxtarFS].
+// As txtar stores only file contents, the archive comment lists modes
+// of the files differing from 0644 and all the directories, so that
+// [ParseTxtarSnapshot] restores them. As in [NewTxtarWriter], a newline is
+// added to the content not ending with it:
+//
+//	mode 0755 bin/run.sh
+//	dir 0755 bin
+func (s *Snapshot) Txtar() []byte {
+	var comment, files bytes.Buffer
+	for _, e := range s.entries {
+		switch {
+		case e.dir:
+			fmt.Fprintf(&comment, "dir %s %s\n", formatPerm(e.perm), e.path)
+			continue
+		case e.perm != txtarFileMode:
+			fmt.Fprintf(&comment, "mode %s %s\n", formatPerm(e.perm), e.path)
+		}
+
+		// Writing to bytes.Buffer never fails.
+		_ = (&txtarFormat{w: &files}).writeEntry(archiveEntry{name: e.path, data: e.data})
+	}
+
+	return append(comment.Bytes(), files.Bytes()...)
+}
+
+// ParseTxtarSnapshot parses the txtar archive, see [Snapshot.Txtar].
+// Archives without modes in the comment are also accepted, files get
+// 0644 mode then.
+func ParseTxtarSnapshot(data []byte) (*Snapshot, error) {
+	comment, name, data := findTxtarMarker(data)
+
+	modes := map[string]fs.FileMode{}
+	var s Snapshot
+	for line := range strings.Lines(string(comment)) {
+		line = strings.TrimSpace(line)
+		kind, rest, _ := strings.Cut(line, " ")
+		mode, p, ok := strings.Cut(rest, " ")
+		if !ok || kind != "dir" && kind != "mode" {
+			continue
+		}
+		perm, err := parsePerm(mode)
+		if err != nil {
+			return nil, fmt.Errorf("%w: comment line %q: %w", ErrInvalidSnapshot, line, err)
+		}
+
+		if kind == "dir" {
+			s.entries = append(s.entries, snapshotEntry{path: p, dir: true, perm: perm})
+		} else {
+			modes[p] = perm
+		}
+	}
+
+	for name != "" {
+		var content []byte
+		var next string
+		content, next, data = findTxtarMarker(data)
+
+		if content == nil {
+			// The last file may be empty without a trailing newline.
+			content = []byte{}
+		}
+		perm, ok := modes[name]
+		if !ok {
+			perm = txtarFileMode
+		}
+		s.entries = append(s.entries, snapshotEntry{path: name, perm: perm, data: content})
+		name = next
+	}
+	return &s, nil
+}
+
+func formatPerm(perm fs.FileMode) string {
+	return fmt.Sprintf("%04o", uint32(perm.Perm()))
+}
+
+func parsePerm(s string) (fs.FileMode, error) {
+	perm, err := strconv.ParseUint(s, 8, 32)
+	if err != nil {
+		return 0, fmt.Errorf("parsing mode: %w", err)
+	}
+	return fs.FileMode(perm).Perm(), nil
+}
@@ -0,0 +1,171 @@
+package fs_test
+
+import (
+	"encoding/json"
+	iofs "io/fs"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go.mws.cloud/util-toolset/pkg/internal/os/fs"
+)
+
+func newSnapshotFixture(t *testing.T) fs.FS {
+	t.Helper()
+
+	f := fs.NewFS(fs.NewMapFS(), fs.WithDirCreate(0o755))
+	require.NoError(t, f.WriteFile("a.txt", []byte("a\n"), 0o644))
+	require.NoError(t, f.WriteFile("bin/run.sh", []byte("#!/bin/sh\n"), 0o755))
+	require.NoError(t, f.WriteFile("bin/data", []byte{0xff, 0x00}, 0o600))
+	require.NoError(t, f.MkdirAll("empty", 0o700))
+	return f
+}
+
+func requireSnapshotFixture(t *testing.T, f fs.ReadOnlyFS) {
+	t.Helper()
+
+	files, err := fs.List(f, fs.WithListDirs())
+	require.NoError(t, err)
+
+	modes := map[string]iofs.FileMode{}
+	for _, info := range files {
+		modes[info.Name()] = info.Mode()
+	}
+	require.Equal(t, map[string]iofs.FileMode{
+		"a.txt":      0o644,
+		"bin":        iofs.ModeDir | 0o755,
+		"bin/data":   0o600,
+		"bin/run.sh": 0o755,
+		"empty":      iofs.ModeDir | 0o700,
+	}, modes)
+
+	data, err := fs.ReadFile(f, "bin/data")
+	require.NoError(t, err)
+	require.Equal(t, []byte{0xff, 0x00}, data)
+}
+
+func TestSnapshot(t *testing.T) {
+	fixture := newSnapshotFixture(t)
+	s, err := fs.NewSnapshot(fixture)
+	require.NoError(t, err)
+
+	for _, name := range []string{"first", "second"} {
+		t.Run(name, func(t *testing.T) {
+			f, err := s.FS()
+			require.NoError(t, err)
+			requireSnapshotFixture(t, f)
+
+			require.NoError(t, f.RemoveAll("bin"))
+			require.NoError(t, f.WriteFile("new.txt", nil, 0o644))
+		})
+	}
+
+	clone, err := fs.Clone(fixture)
+	require.NoError(t, err)
+	require.NoError(t, clone.Remove("a.txt"))
+	requireSnapshotFixture(t, fixture)
+	require.NoError(t, s.Restore(clone))
+	requireSnapshotFixture(t, clone)
+
+	require.NoError(t, fixture.WriteFile("extra.txt", nil, 0o644))
+	require.NoError(t, fixture.RemoveAll("bin"))
+	require.NoError(t, s.Restore(fixture))
+	requireSnapshotFixture(t, fixture)
+}
+
+func TestSnapshotRestoreRealFS(t *testing.T) {
+	s, err := fs.NewSnapshot(newSnapshotFixture(t))
+	require.NoError(t, err)
+
+	f := fs.NewFS(fs.NewRealFS(), fs.WithBaseDir(t.TempDir()))
+	require.NoError(t, f.WriteFile("old.txt", nil, 0o644))
+	require.NoError(t, s.Restore(f))
+	requireSnapshotFixture(t, f)
+}
+
+func TestSnapshotRestoreWatch(t *testing.T) {
+	s, err := fs.NewSnapshot(newSnapshotFixture(t))
+	require.NoError(t, err)
+
+	f := fs.NewMapFS()
+	require.NoError(t, f.WriteFile("old.txt", nil, 0o644))
+	events, err := fs.Watch(t.Context(), f, ".")
+	require.NoError(t, err)
+
+	require.NoError(t, s.Restore(f))
+	requireSnapshotFixture(t, f)
+	require.Equal(t, []string{"remove old.txt", "create a.txt"}, receiveEvents(t, events, 2))
+}
+
+func TestSnapshotJSON(t *testing.T) {
+	s, err := fs.NewSnapshot(newSnapshotFixture(t))
+	require.NoError(t, err)
+
+	data, err := json.Marshal(s)
+	require.NoError(t, err)
+	require.JSONEq(t, `[
+		{"path": "a.txt", "mode": "0644", "content": "a\n"},
+		{"path": "bin", "dir": true, "mode": "0755"},
+		{"path": "bin/data", "mode": "0600", "content": "/wA=", "encoding": "base64"},
+		{"path": "bin/run.sh", "mode": "0755", "content": "#!/bin/sh\n"},
+		{"path": "empty", "dir": true, "mode": "0700"}
+	]`, string(data))
+
+	var parsed fs.Snapshot
+	require.NoError(t, json.Unmarshal(data, &parsed))
+	f, err := parsed.FS()
+	require.NoError(t, err)
+	requireSnapshotFixture(t, f)
+
+	err = json.Unmarshal([]byte(`[{"path": "a", "mode": "rw"}]`), &parsed)
+	require.ErrorIs(t, err, fs.ErrInvalidSnapshot)
+	err = json.Unmarshal([]byte(`[{"path": "a", "mode": "0644", "encoding": "hex"}]`), &parsed)
+	require.ErrorIs(t, err, fs.ErrInvalidSnapshot)
+}
+
+func TestSnapshotTxtar(t *testing.T) {
+	s, err := fs.NewSnapshot(newSnapshotFixture(t))
+	require.NoError(t, err)
+
+	data := s.Txtar()
+	require.Equal(t, "dir 0755 bin\nmode 0600 bin/data\nmode 0755 bin/run.sh\ndir 0700 empty\n"+
+		"-- a.txt --\na\n-- bin/data --\n\xff\x00\n-- bin/run.sh --\n#!/bin/sh\n", string(data))
+
+	parsed, err := fs.ParseTxtarSnapshot(data)
+	require.NoError(t, err)
+	f, err := parsed.FS()
+	require.NoError(t, err)
+
+	// A newline is added to the content of bin/data.
+	require.NoError(t, f.WriteFile("bin/data", []byte{0xff, 0x00}, os.ModePerm))
+	requireSnapshotFixture(t, f)
+
+	// The last file is empty and has no trailing newline.
+	parsed, err = fs.ParseTxtarSnapshot([]byte("-- a.txt --\na\n-- empty --"))
+	require.NoError(t, err)
+	require.Equal(t, "-- a.txt --\na\n-- empty --\n", string(parsed.Txtar()))
+
+	_, err = fs.ParseTxtarSnapshot([]byte("dir 9 x\n"))
+	require.ErrorIs(t, err, fs.ErrInvalidSnapshot)
+}
+
+func TestTree(t *testing.T) {
+	s, err := fs.NewSnapshot(newSnapshotFixture(t))
+	require.NoError(t, err)
+	f, err := s.FS()
+	require.NoError(t, err)
+	require.NoError(t, f.MkdirAll("/abs", 0o755))
+	require.NoError(t, f.WriteFile("/abs/x.txt", []byte("x"), 0o644))
+
+	require.Equal(t, `.
+├── /
+│   └── abs (drwxr-xr-x)
+│       └── x.txt (-rw-r--r--, 1 B)
+├── a.txt (-rw-r--r--, 2 B)
+├── bin (drwxr-xr-x)
+│   ├── data (-rw-------, 2 B)
+│   └── run.sh (-rwxr-xr-x, 10 B)
+└── empty (drwx------)
+`, fs.Tree(f))
+}
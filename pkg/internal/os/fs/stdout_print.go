@@ -1,15 +1,17 @@
 package fs
 
 import (
+	"context"
 	"fmt"
 	"io/fs"
 	"os"
+	"time"
 
 	"go.mws.cloud/util-toolset/pkg/utils/consterr"
 )
 
 type stdoutPrint struct {
-	FS
+	wrapped
 }
 
 // WithStdoutPrint is an option for NewFS that makes [WriteOnlyFS] WriteFile
@@ -17,7 +19,7 @@ type stdoutPrint struct {
 func WithStdoutPrint() Option {
 	return func(fs FS) FS {
 		return &stdoutPrint{
-			FS: fs,
+			wrapped: wrapped{fs},
 		}
 	}
 }
@@ -32,3 +34,26 @@ func (*stdoutPrint) WriteFile(_ string, data []byte, _ fs.FileMode) error {
 	_, err := fmt.Fprint(os.Stdout, string(data))
 	return err
 }
+
+// Chmod does nothing, as no files are written.
+func (*stdoutPrint) Chmod(string, fs.FileMode) error {
+	return nil
+}
+
+// Chtimes does nothing, as no files are written.
+func (*stdoutPrint) Chtimes(string, time.Time, time.Time) error {
+	return nil
+}
+
+// Lock does nothing, as no files are written.
+func (*stdoutPrint) Lock(context.Context, string, LockMode) (Unlocker, error) {
+	return unlockFunc(func() error { return nil }), nil
+}
+
+func (*stdoutPrint) ownCapabilities() capability {
+	return capChmod | capChtimes | capLock
+}
+
+func (*stdoutPrint) String() string {
+	return "WithStdoutPrint()"
+}
@@ -0,0 +1,350 @@
+package fs
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"maps"
+	"path"
+	"slices"
+	"strings"
+)
+
+// SyncCompare defines how [Sync] decides whether a file has to be updated.
+type SyncCompare int
+
+const (
+	// SyncCompareSizeModTime treats a file as changed if its size or
+	// modification time differs, like rsync does. The modification time of
+	// the source is set on the written files, so they are not copied again.
+	SyncCompareSizeModTime SyncCompare = iota
+	// SyncCompareContent treats a file as changed if SHA-256 hashes of the
+	// source and destination contents differ.
+	SyncCompareContent
+)
+
+// SyncOp is a kind of change made (or planned) by [Sync].
+type SyncOp int
+
+// Possible [SyncOp] values.
+const (
+	SyncCreate SyncOp = iota + 1
+	SyncUpdate
+	SyncDelete
+)
+
+func (op SyncOp) String() string {
+	switch op {
+	case SyncCreate:
+		return "create"
+	case SyncUpdate:
+		return "update"
+	case SyncDelete:
+		return "delete"
+	default:
+		return "unknown"
+	}
+}
+
+// SyncChange describes a single change made (or planned in dry-run mode) by [Sync].
+type SyncChange struct {
+	Op    SyncOp
+	Path  string
+	IsDir bool
+}
+
+func (c SyncChange) String() string {
+	if c.IsDir {
+		return c.Op.String() + " " + c.Path + "/"
+	}
+	return c.Op.String() + " " + c.Path
+}
+
+// SyncOption is an option for [Sync].
+type SyncOption func(*syncConfig)
+
+type syncConfig struct {
+	compare SyncCompare
+	dryRun  bool
+	delete  bool
+	exclude []string
+	patch   io.Writer
+}
+
+// WithSyncCompare sets the way files are compared, the default is [SyncCompareSizeModTime].
+func WithSyncCompare(c SyncCompare) SyncOption {
+	return func(cfg *syncConfig) {
+		cfg.compare = c
+	}
+}
+
+// WithSyncDryRun makes [Sync] only report the changes without applying them.
+func WithSyncDryRun() SyncOption {
+	return func(cfg *syncConfig) {
+		cfg.dryRun = true
+	}
+}
+
+// WithSyncPatch makes [Sync] write every change as a git-style patch to w
+// before applying it. Along with [WithSyncDryRun] it shows what would be
+// changed, e.g. to check that generated files are up to date.
+func WithSyncPatch(w io.Writer) SyncOption {
+	return func(cfg *syncConfig) {
+		cfg.patch = w
+	}
+}
+
+// WithSyncDelete makes [Sync] delete entries of dst which do not exist in src.
+func WithSyncDelete() SyncOption {
+	return func(cfg *syncConfig) {
+		cfg.delete = true
+	}
+}
+
+// WithSyncExclude excludes entries matching any of the [path.Match] patterns.
+// A pattern is matched against both the slash-separated path relative to the
+// root and the base name of the entry. Excluded entries are neither copied
+// nor deleted, excluded directories are skipped entirely.
+func WithSyncExclude(patterns ...string) SyncOption {
+	return func(cfg *syncConfig) {
+		cfg.exclude = append(cfg.exclude, patterns...)
+	}
+}
+
+// Sync makes the dst tree match the src tree: it creates missing files and
+// directories, updates changed files and, with [WithSyncDelete], removes
+// extraneous entries. The changes are returned in the order they are applied:
+// creations and updates sorted by path, then deletions of extraneous entries,
+// children before their directories.
+//
+// Only regular files and directories are supported, other file types cause
+// an error. File permissions are taken from src.
+func Sync(dst FS, src ReadOnlyFS, options ...SyncOption) ([]SyncChange, error) {
+	var cfg syncConfig
+	for _, o := range options {
+		o(&cfg)
+	}
+
+	srcEntries, _, err := cfg.walk(src)
+	if err != nil {
+		return nil, err
+	}
+	dstEntries, kept, err := cfg.walk(dst)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	s := &syncer{cfg: &cfg, dst: dst, src: src, dstEntries: dstEntries, kept: kept, removed: map[string]bool{}}
+	for _, p := range slices.Sorted(maps.Keys(srcEntries)) {
+		if err = s.syncEntry(p, srcEntries[p], dstEntries[p]); err != nil {
+			return s.changes, err
+		}
+	}
+
+	if cfg.delete {
+		// Entries are deleted one by one, children before their directories,
+		// so the directories holding excluded entries are kept.
+		for _, p := range slices.Backward(slices.Sorted(maps.Keys(dstEntries))) {
+			if _, ok := srcEntries[p]; ok || s.removed[p] || kept[p] {
+				continue
+			}
+			if err = s.apply(SyncChange{Op: SyncDelete, Path: p, IsDir: dstEntries[p].IsDir()}); err != nil {
+				return s.changes, err
+			}
+		}
+	}
+
+	return s.changes, nil
+}
+
+type syncer struct {
+	cfg        *syncConfig
+	dst        FS
+	src        ReadOnlyFS
+	dstEntries map[string]fs.DirEntry
+	// kept are the directories of dst holding excluded entries.
+	kept    map[string]bool
+	changes []SyncChange
+	removed map[string]bool
+}
+
+func (s *syncer) syncEntry(p string, srcEntry, dstEntry fs.DirEntry) error {
+	if dstEntry != nil && dstEntry.IsDir() != srcEntry.IsDir() {
+		// Type of entry has changed, the old one has to be removed first.
+		if err := s.removeTree(p); err != nil {
+			return err
+		}
+		dstEntry = nil
+	}
+
+	switch {
+	case dstEntry == nil:
+		return s.apply(SyncChange{Op: SyncCreate, Path: p, IsDir: srcEntry.IsDir()})
+	case srcEntry.IsDir():
+		return nil
+	}
+
+	changed, err := s.changed(p, srcEntry, dstEntry)
+	if err != nil || !changed {
+		return err
+	}
+	return s.apply(SyncChange{Op: SyncUpdate, Path: p})
+}
+
+// removeTree deletes the named entry of dst along with its children. None of
+// them holds excluded entries unless the entry itself does.
+func (s *syncer) removeTree(p string) error {
+	if s.kept[p] {
+		return &fs.PathError{Op: "sync", Path: p, Err: errors.New("directory holds excluded entries")}
+	}
+
+	for _, child := range slices.Backward(slices.Sorted(maps.Keys(s.dstEntries))) {
+		if !strings.HasPrefix(child, p+"/") {
+			continue
+		}
+		if err := s.apply(SyncChange{Op: SyncDelete, Path: child, IsDir: s.dstEntries[child].IsDir()}); err != nil {
+			return err
+		}
+	}
+	return s.apply(SyncChange{Op: SyncDelete, Path: p, IsDir: s.dstEntries[p].IsDir()})
+}
+
+func (s *syncer) changed(p string, srcEntry, dstEntry fs.DirEntry) (bool, error) {
+	if s.cfg.compare == SyncCompareContent {
+		srcSum, err := hashFile(s.src, p)
+		if err != nil {
+			return false, err
+		}
+		dstSum, err := hashFile(s.dst, p)
+		if err != nil {
+			return false, err
+		}
+		return !bytes.Equal(srcSum, dstSum), nil
+	}
+
+	srcInfo, err := srcEntry.Info()
+	if err != nil {
+		return false, err
+	}
+	dstInfo, err := dstEntry.Info()
+	if err != nil {
+		return false, err
+	}
+	return srcInfo.Size() != dstInfo.Size() || !srcInfo.ModTime().Equal(dstInfo.ModTime()), nil
+}
+
+func (s *syncer) apply(c SyncChange) error {
+	s.changes = append(s.changes, c)
+	if c.Op == SyncDelete {
+		s.removed[c.Path] = true
+	}
+	if s.cfg.patch != nil {
+		if err := s.writePatch(c); err != nil {
+			return err
+		}
+	}
+	if s.cfg.dryRun {
+		return nil
+	}
+
+	switch {
+	case c.Op == SyncDelete:
+		return s.dst.Remove(c.Path)
+	case c.IsDir:
+		return s.dst.MkdirAll(c.Path, fs.ModePerm)
+	}
+
+	info, err := fs.Stat(s.src, c.Path)
+	if err != nil {
+		return err
+	}
+	if err = copyFile(s.dst, s.src, c.Path); err != nil {
+		return err
+	}
+	// The mode of an existing file is kept by copyFile.
+	if err = Chmod(s.dst, c.Path, info.Mode().Perm()); err != nil && !errors.Is(err, errors.ErrUnsupported) {
+		return err
+	}
+	if err = Chtimes(s.dst, c.Path, info.ModTime(), info.ModTime()); errors.Is(err, errors.ErrUnsupported) {
+		return nil
+	}
+	return err
+}
+
+func (s *syncer) writePatch(c SyncChange) error {
+	if c.IsDir {
+		return nil
+	}
+
+	p := filePatch{name: c.Path}
+	var err error
+	if c.Op != SyncCreate {
+		if p.oldData, p.oldMode, err = readPatchFile(s.dst, c.Path); err != nil {
+			return err
+		}
+	}
+	if c.Op != SyncDelete {
+		if p.newData, p.newMode, err = readPatchFile(s.src, c.Path); err != nil {
+			return err
+		}
+	}
+	if c.Op == SyncUpdate && p.oldMode == p.newMode && bytes.Equal(p.oldData, p.newData) {
+		// Only the modification time differs, there is nothing to patch.
+		return nil
+	}
+	return p.write(s.cfg.patch)
+}
+
+// readPatchFile returns the content and the mode of the file for [filePatch].
+func readPatchFile(f ReadOnlyFS, name string) ([]byte, fs.FileMode, error) {
+	info, err := fs.Stat(f, name)
+	if err != nil {
+		return nil, 0, err
+	}
+	data, err := readTreeFile(f, name)
+	return data, info.Mode(), err
+}
+
+// walk returns the entries of f which are not excluded, and the directories
+// holding excluded entries.
+func (cfg *syncConfig) walk(f ReadOnlyFS) (map[string]fs.DirEntry, map[string]bool, error) {
+	entries, kept := map[string]fs.DirEntry{}, map[string]bool{}
+	err := fs.WalkDir(f, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == "." {
+			return nil
+		}
+		if cfg.excluded(p) {
+			for dir := path.Dir(p); dir != "."; dir = path.Dir(dir) {
+				kept[dir] = true
+			}
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !d.IsDir() && !d.Type().IsRegular() {
+			return &fs.PathError{Op: "sync", Path: p, Err: fs.ErrInvalid}
+		}
+
+		entries[p] = d
+		return nil
+	})
+
+	return entries, kept, err
+}
+
+func (cfg *syncConfig) excluded(p string) bool {
+	for _, pattern := range cfg.exclude {
+		if ok, _ := path.Match(pattern, p); ok {
+			return true
+		}
+		if ok, _ := path.Match(pattern, path.Base(p)); ok {
+			return true
+		}
+	}
+	return false
+}
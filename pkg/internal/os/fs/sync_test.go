@@ -0,0 +1,207 @@
+package fs_test
+
+import (
+	"bytes"
+	iofs "io/fs"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"go.mws.cloud/util-toolset/pkg/internal/os/fs"
+)
+
+func syncSource(t *testing.T, f fs.FS) {
+	t.Helper()
+
+	require.NoError(t, f.MkdirAll("empty", os.ModePerm))
+	require.NoError(t, f.WriteFile("a.txt", []byte("a"), 0o644))
+	require.NoError(t, f.WriteFile("dir/b.txt", []byte("b"), 0o644))
+	require.NoError(t, f.WriteFile("dir/skip.log", []byte("log"), 0o644))
+}
+
+func TestSync(t *testing.T) {
+	src := fs.NewFS(fs.NewMapFS(), fs.WithDirCreate(os.ModePerm))
+	syncSource(t, src)
+
+	dst := fs.NewFS(fs.NewRealFS(), fs.WithBaseDir(t.TempDir()), fs.WithDirCreate(os.ModePerm))
+	require.NoError(t, dst.WriteFile("a.txt", []byte("old"), 0o644))
+	require.NoError(t, dst.WriteFile("extra/c.txt", []byte("c"), 0o644))
+	require.NoError(t, dst.WriteFile("keep.log", []byte("log"), 0o644))
+
+	changes, err := fs.Sync(dst, src, fs.WithSyncDelete(), fs.WithSyncExclude("*.log"))
+	require.NoError(t, err)
+	require.Equal(t, []fs.SyncChange{
+		{Op: fs.SyncUpdate, Path: "a.txt"},
+		{Op: fs.SyncCreate, Path: "dir", IsDir: true},
+		{Op: fs.SyncCreate, Path: "dir/b.txt"},
+		{Op: fs.SyncCreate, Path: "empty", IsDir: true},
+		{Op: fs.SyncDelete, Path: "extra/c.txt"},
+		{Op: fs.SyncDelete, Path: "extra", IsDir: true},
+	}, changes)
+	require.Equal(t, []string{"a.txt", "dir/b.txt", "keep.log"}, collectElements(t, dst))
+
+	data, err := fs.ReadFile(dst, "a.txt")
+	require.NoError(t, err)
+	require.Equal(t, []byte("a"), data)
+
+	changes, err = fs.Sync(dst, src, fs.WithSyncDelete(), fs.WithSyncExclude("*.log"))
+	require.NoError(t, err)
+	require.Empty(t, changes)
+}
+
+func TestSyncDryRun(t *testing.T) {
+	src := fs.NewFS(fs.NewMapFS(), fs.WithDirCreate(os.ModePerm))
+	syncSource(t, src)
+	dst := fs.NewFS(fs.NewMapFS(), fs.WithDirCreate(os.ModePerm))
+	require.NoError(t, dst.WriteFile("z.txt", nil, 0o644))
+
+	changes, err := fs.Sync(dst, src, fs.WithSyncDryRun(), fs.WithSyncDelete())
+	require.NoError(t, err)
+	require.Equal(t, []fs.SyncChange{
+		{Op: fs.SyncCreate, Path: "a.txt"},
+		{Op: fs.SyncCreate, Path: "dir", IsDir: true},
+		{Op: fs.SyncCreate, Path: "dir/b.txt"},
+		{Op: fs.SyncCreate, Path: "dir/skip.log"},
+		{Op: fs.SyncCreate, Path: "empty", IsDir: true},
+		{Op: fs.SyncDelete, Path: "z.txt"},
+	}, changes)
+	require.Equal(t, []string{"z.txt"}, collectElements(t, dst))
+}
+
+func TestSyncPatch(t *testing.T) {
+	src := fs.NewFS(fs.NewMapFS(), fs.WithDirCreate(os.ModePerm))
+	require.NoError(t, src.WriteFile("a.txt", []byte("1\n2\n3\n"), 0o644))
+	require.NoError(t, src.WriteFile("dir/b.txt", []byte("b\n"), 0o644))
+	dst := fs.NewFS(fs.NewMapFS(), fs.WithDirCreate(os.ModePerm))
+	require.NoError(t, dst.WriteFile("a.txt", []byte("1\nTWO\n3\n"), 0o644))
+	require.NoError(t, dst.WriteFile("old/c.txt", []byte("c\n"), 0o644))
+
+	var b bytes.Buffer
+	_, err := fs.Sync(dst, src, fs.WithSyncDryRun(), fs.WithSyncDelete(), fs.WithSyncCompare(fs.SyncCompareContent), fs.WithSyncPatch(&b))
+	require.NoError(t, err)
+	require.Equal(t, `diff --git a/a.txt b/a.txt
+--- a/a.txt
++++ b/a.txt
+@@ -1,3 +1,3 @@
+ 1
+-TWO
++2
+ 3
+diff --git a/dir/b.txt b/dir/b.txt
+new file mode 100644
+--- /dev/null
++++ b/dir/b.txt
+@@ -0,0 +1 @@
++b
+diff --git a/old/c.txt b/old/c.txt
+deleted file mode 100644
+--- a/old/c.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-c
+`, b.String())
+	require.Equal(t, []string{"a.txt", "old/c.txt"}, collectElements(t, dst))
+}
+
+func TestSyncPatchModes(t *testing.T) {
+	src := fs.NewMapFS()
+	require.NoError(t, src.WriteFile("empty.txt", nil, 0o644))
+	require.NoError(t, src.WriteFile("same.txt", []byte("x\n"), 0o644))
+	require.NoError(t, src.WriteFile("run.sh", []byte("y\n"), 0o755))
+	require.NoError(t, fs.Chtimes(src, "same.txt", time.Unix(200, 0), time.Unix(200, 0)))
+	dst := fs.NewMapFS()
+	require.NoError(t, dst.WriteFile("same.txt", []byte("x\n"), 0o644))
+	require.NoError(t, dst.WriteFile("run.sh", []byte("y\n"), 0o644))
+	require.NoError(t, fs.Chtimes(dst, "run.sh", time.Unix(100, 0), time.Unix(100, 0)))
+
+	// The empty file gets a mode header, so git applies the patch, and the
+	// file with only another modification time is omitted.
+	var b bytes.Buffer
+	changes, err := fs.Sync(dst, src, fs.WithSyncDryRun(), fs.WithSyncPatch(&b))
+	require.NoError(t, err)
+	require.Len(t, changes, 3)
+	require.Equal(t, `diff --git a/empty.txt b/empty.txt
+new file mode 100644
+diff --git a/run.sh b/run.sh
+old mode 100644
+new mode 100755
+`, b.String())
+
+	_, err = fs.Sync(dst, src)
+	require.NoError(t, err)
+	info, err := iofs.Stat(dst, "run.sh")
+	require.NoError(t, err)
+	require.Equal(t, iofs.FileMode(0o755), info.Mode())
+}
+
+func TestSyncCompare(t *testing.T) {
+	src := fs.NewMapFS()
+	dst := fs.NewMapFS()
+	require.NoError(t, dst.WriteFile("a.txt", []byte("x"), 0o644))
+	require.NoError(t, src.WriteFile("a.txt", []byte("x"), 0o644))
+	require.NoError(t, fs.Chtimes(src, "a.txt", time.Unix(200, 0), time.Unix(200, 0)))
+
+	// Modification times differ, so it is treated as changed.
+	changes, err := fs.Sync(dst, src, fs.WithSyncDryRun())
+	require.NoError(t, err)
+	require.Equal(t, []fs.SyncChange{{Op: fs.SyncUpdate, Path: "a.txt"}}, changes)
+
+	changes, err = fs.Sync(dst, src, fs.WithSyncCompare(fs.SyncCompareContent))
+	require.NoError(t, err)
+	require.Empty(t, changes)
+
+	// The destination is edited after the sync, it is older but still differs.
+	require.NoError(t, dst.WriteFile("a.txt", []byte("y"), 0o644))
+	require.NoError(t, fs.Chtimes(dst, "a.txt", time.Unix(100, 0), time.Unix(100, 0)))
+	changes, err = fs.Sync(dst, src)
+	require.NoError(t, err)
+	require.Equal(t, []fs.SyncChange{{Op: fs.SyncUpdate, Path: "a.txt"}}, changes)
+
+	changes, err = fs.Sync(dst, src)
+	require.NoError(t, err)
+	require.Empty(t, changes)
+}
+
+func TestSyncTypeChange(t *testing.T) {
+	src := fs.NewFS(fs.NewMapFS(), fs.WithDirCreate(os.ModePerm))
+	require.NoError(t, src.WriteFile("x", []byte("file"), 0o644))
+	dst := fs.NewFS(fs.NewMapFS(), fs.WithDirCreate(os.ModePerm))
+	require.NoError(t, dst.WriteFile("x/y.txt", nil, 0o644))
+
+	changes, err := fs.Sync(dst, src, fs.WithSyncDelete())
+	require.NoError(t, err)
+	require.Equal(t, []fs.SyncChange{
+		{Op: fs.SyncDelete, Path: "x/y.txt"},
+		{Op: fs.SyncDelete, Path: "x", IsDir: true},
+		{Op: fs.SyncCreate, Path: "x"},
+	}, changes)
+	require.Equal(t, []string{"x"}, collectElements(t, dst))
+}
+
+func TestSyncDeleteExclude(t *testing.T) {
+	src := fs.NewMapFS()
+	dst := fs.NewFS(fs.NewMapFS(), fs.WithDirCreate(os.ModePerm))
+	require.NoError(t, dst.WriteFile("extra/c.txt", []byte("c\n"), 0o644))
+	require.NoError(t, dst.WriteFile("extra/keep.log", nil, 0o644))
+	require.NoError(t, dst.WriteFile("extra/sub/d.txt", []byte("d\n"), 0o644))
+	require.NoError(t, dst.WriteFile("logs/x.log/e.txt", nil, 0o644))
+
+	var b bytes.Buffer
+	changes, err := fs.Sync(dst, src, fs.WithSyncDelete(), fs.WithSyncExclude("*.log"), fs.WithSyncPatch(&b))
+	require.NoError(t, err)
+	require.Equal(t, []fs.SyncChange{
+		{Op: fs.SyncDelete, Path: "extra/sub/d.txt"},
+		{Op: fs.SyncDelete, Path: "extra/sub", IsDir: true},
+		{Op: fs.SyncDelete, Path: "extra/c.txt"},
+	}, changes)
+	require.Equal(t, []string{"extra/keep.log", "logs/x.log/e.txt"}, collectElements(t, dst))
+	require.NotContains(t, b.String(), "keep.log")
+
+	// A directory holding excluded entries is not replaced with a file.
+	require.NoError(t, src.WriteFile("extra", nil, 0o644))
+	_, err = fs.Sync(dst, src, fs.WithSyncExclude("*.log"))
+	require.ErrorContains(t, err, "excluded")
+	require.Equal(t, []string{"extra/keep.log", "logs/x.log/e.txt"}, collectElements(t, dst))
+}
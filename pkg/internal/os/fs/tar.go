@@ -0,0 +1,134 @@
+package fs
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+
+	"github.com/spf13/afero"
+)
+
+type tarFormat struct {
+	tw *tar.Writer
+	gw *gzip.Writer
+}
+
+// NewTarWriter returns [ArchiveWriter] that writes files and directories
+// into w as a tar archive, compressed with gzip if [WithArchiveGzip] is used.
+// All the entries get the same modification time, see [WithArchiveModTime],
+// and no owner information, while only permission bits of the file modes
+// are kept, so the archive is reproducible. The entries are kept in
+// memory and written sorted by path on Close, unless [WithArchiveStreaming]
+// is used.
+//
+// Data written to w cannot be changed, so writing the same path twice fails
+// with [fs.ErrExist], while Rename, Remove and RemoveAll fail with
+// [errors.ErrUnsupported].
+func NewTarWriter(w io.Writer, options ...ArchiveOption) ArchiveWriter {
+	a := newArchiveWriter(nil, options)
+
+	f := &tarFormat{}
+	if a.cfg.gzip {
+		// Zero gzip header has no time and file name, so the output is reproducible.
+		f.gw = gzip.NewWriter(w)
+		w = f.gw
+	}
+	f.tw = tar.NewWriter(w)
+	a.format = f
+
+	return a
+}
+
+func (t *tarFormat) writeEntry(e archiveEntry) error {
+	h := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     e.name,
+		Mode:     int64(e.perm),
+		Size:     int64(len(e.data)),
+		ModTime:  e.modTime,
+	}
+	if e.data == nil {
+		h.Typeflag = tar.TypeDir
+		h.Name = archiveDirName(e.name)
+	}
+
+	if err := t.tw.WriteHeader(h); err != nil {
+		return err
+	}
+	_, err := t.tw.Write(e.data)
+	return err
+}
+
+func (t *tarFormat) close() error {
+	err := t.tw.Close()
+	if t.gw != nil {
+		err = errors.Join(err, t.gw.Close())
+	}
+	return err
+}
+
+// NewTarFS reads the whole tar archive, compressed with gzip or not, and
+// returns read-only [FS] with its regular files and directories, keeping
+// their permissions and modification times, see [NewReadOnlyFS]. Other entry
+// types are skipped.
+func NewTarFS(r io.Reader) (_ FS, rErr error) {
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(2); bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		defer closeWithErr(gr, &rErr)
+		r = gr
+	} else {
+		r = br
+	}
+
+	m := &aferoFS{a: afero.NewMemMapFs()}
+	tr := tar.NewReader(r)
+	for {
+		h, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return NewReadOnlyFS(&mapFS{aferoFS: m}), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if err = addTarEntry(m, tr, h); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func addTarEntry(m *aferoFS, r io.Reader, h *tar.Header) error {
+	p := cleanRelPath(h.Name)
+	perm := fs.FileMode(h.Mode).Perm()
+
+	var err error
+	switch h.Typeflag {
+	case tar.TypeDir:
+		err = m.MkdirAll(p, perm)
+	case tar.TypeReg:
+		var data []byte
+		if data, err = io.ReadAll(r); err != nil {
+			return err
+		}
+		if err = m.MkdirAll(path.Dir(p), fs.ModePerm); err != nil {
+			return err
+		}
+		err = m.WriteFile(p, data, perm)
+	default:
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return m.a.Chtimes(p, h.ModTime, h.ModTime)
+}
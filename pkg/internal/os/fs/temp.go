@@ -0,0 +1,58 @@
+package fs
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// TempFS is [FS] built on top of OS file system and confined to a fresh
+// temporary directory, see [NewTempFS]. The directory is removed by Close.
+type TempFS struct {
+	wrapped
+	path      string
+	closeOnce sync.Once
+	closeErr  error
+}
+
+var (
+	_ ChmodFS   = (*TempFS)(nil)
+	_ ChtimesFS = (*TempFS)(nil)
+	_ LockFS    = (*TempFS)(nil)
+	_ WatchFS   = (*TempFS)(nil)
+)
+
+// NewTempFS creates a new temporary directory with [os.MkdirTemp] in the
+// default directory for temporary files, prefix is used as in [os.MkdirTemp].
+// It returns [TempFS] confined to the directory with [WithBaseDir] and the
+// given options, which are applied inside the base directory.
+func NewTempFS(prefix string, options ...Option) (*TempFS, error) {
+	dir, err := os.MkdirTemp("", prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TempFS{
+		wrapped: wrapped{NewFS(NewRealFS(), append([]Option{WithBaseDir(dir)}, options...)...)},
+		path:    dir,
+	}, nil
+}
+
+// Path returns the absolute path of the temporary directory,
+// e.g. to pass it to a subprocess.
+func (t *TempFS) Path() string {
+	return t.path
+}
+
+// Close removes the temporary directory with all its contents.
+// Subsequent calls return the result of the first one.
+func (t *TempFS) Close() error {
+	t.closeOnce.Do(func() {
+		t.closeErr = os.RemoveAll(t.path)
+	})
+	return t.closeErr
+}
+
+func (t *TempFS) String() string {
+	return fmt.Sprintf("TempFS(%q)", t.path)
+}
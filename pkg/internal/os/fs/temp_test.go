@@ -0,0 +1,30 @@
+package fs_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go.mws.cloud/util-toolset/pkg/internal/os/fs"
+)
+
+func TestTempFS(t *testing.T) {
+	f, err := fs.NewTempFS("temp-fs-test-", fs.WithDirCreate(os.ModePerm))
+	require.NoError(t, err)
+	require.True(t, filepath.IsAbs(f.Path()))
+	require.True(t, strings.HasPrefix(filepath.Base(f.Path()), "temp-fs-test-"))
+
+	require.NoError(t, f.WriteFile("dir/a.txt", []byte("a"), 0o644))
+	data, err := os.ReadFile(filepath.Join(f.Path(), "dir", "a.txt"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("a"), data)
+
+	require.NoError(t, fs.Chmod(f, "dir/a.txt", 0o600))
+
+	require.NoError(t, f.Close())
+	require.NoDirExists(t, f.Path())
+	require.NoError(t, f.Close())
+}
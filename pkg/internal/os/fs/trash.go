@@ -0,0 +1,303 @@
+package fs
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mws.cloud/util-toolset/pkg/utils/consterr"
+)
+
+// ErrProtectedPath is returned when removing a path which is protected by
+// [WithTrash]: the root, a path outside of it, the trash directory or a path
+// containing a protected path.
+const ErrProtectedPath = consterr.Error("refusing to remove protected path")
+
+const (
+	defaultTrashDir = ".trash"
+	// trashInfoName and trashDataName are the names of the file with the
+	// original path and of the removed entry in an item directory.
+	trashInfoName = "info"
+	trashDataName = "data"
+)
+
+// TrashOption is an option for [NewTrash].
+type TrashOption func(*Trash)
+
+// WithTrashDir sets the trash directory, which is a path in the wrapped [FS],
+// the default is ".trash". It must be on the same file system as the removed
+// files, as they are moved there with Rename.
+func WithTrashDir(dir string) TrashOption {
+	return func(t *Trash) {
+		t.dir = path.Clean(dir)
+	}
+}
+
+// WithTrashProtect refuses removing the paths and the directories containing
+// them, e.g. the directories of inputs next to the generated files.
+func WithTrashProtect(paths ...string) TrashOption {
+	return func(t *Trash) {
+		for _, p := range paths {
+			t.protected = append(t.protected, path.Clean(p))
+		}
+	}
+}
+
+// TrashItem is a file or a directory moved to the trash.
+type TrashItem struct {
+	// Path is the original path of the item in the wrapped [FS].
+	Path string
+	// Time is the time the item was removed.
+	Time time.Time
+
+	dir string
+}
+
+// Trash keeps the files and directories removed through [WithTrash] and
+// restores or purges them. A trash must be used by a single [FS].
+type Trash struct {
+	dir       string
+	protected []string
+	now       func() time.Time
+
+	binding
+	// mu guards protected and serializes choosing the item directories.
+	mu sync.Mutex
+}
+
+// NewTrash returns [Trash] with the options.
+func NewTrash(options ...TrashOption) *Trash {
+	t := &Trash{dir: defaultTrashDir, now: time.Now}
+	for _, o := range options {
+		o(t)
+	}
+	return t
+}
+
+// Items returns the items in the trash, the newest first.
+func (t *Trash) Items() ([]TrashItem, error) {
+	f, err := t.bound()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := f.ReadDir(t.dir)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return nil, nil
+	case err != nil:
+		return nil, err
+	}
+
+	var items []TrashItem
+	for _, e := range entries {
+		stamp, err := time.Parse(versionTimeLayout, e.Name())
+		if !e.IsDir() || err != nil {
+			continue
+		}
+		dir := path.Join(t.dir, e.Name())
+		p, err := fs.ReadFile(f, path.Join(dir, trashInfoName))
+		if err != nil {
+			continue
+		}
+		items = append(items, TrashItem{Path: string(p), Time: stamp, dir: dir})
+	}
+	slices.SortFunc(items, func(l, r TrashItem) int {
+		return r.Time.Compare(l.Time)
+	})
+	return items, nil
+}
+
+// Restore moves the item back to its original path, creating the parent
+// directories. It fails with [fs.ErrExist] if the path exists.
+func (t *Trash) Restore(item TrashItem) error {
+	f, err := t.bound()
+	if err != nil {
+		return err
+	}
+	if _, err := fs.Stat(f, item.Path); err == nil {
+		return &fs.PathError{Op: "restore", Path: item.Path, Err: fs.ErrExist}
+	}
+	if dir := path.Dir(item.Path); dir != "." && dir != "/" {
+		if err := f.MkdirAll(dir, os.ModePerm); err != nil {
+			return err
+		}
+	}
+	if err := f.Rename(path.Join(item.dir, trashDataName), item.Path); err != nil {
+		return err
+	}
+	return f.RemoveAll(item.dir)
+}
+
+// Purge permanently removes the items removed more than olderThan ago,
+// zero purges all the items.
+func (t *Trash) Purge(olderThan time.Duration) error {
+	f, err := t.bound()
+	if err != nil {
+		return err
+	}
+	items, err := t.Items()
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, item := range items {
+		if t.now().Sub(item.Time) >= olderThan {
+			errs = append(errs, f.RemoveAll(item.dir))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// check refuses removing the protected paths.
+func (t *Trash) check(op, name string) error {
+	p := path.Clean(name)
+	if p == "." || p == "/" || p == ".." || strings.HasPrefix(p, "../") ||
+		removes(p, t.dir) || removes(t.dir, p) {
+		return &fs.PathError{Op: op, Path: name, Err: ErrProtectedPath}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, protected := range t.protected {
+		if removes(p, protected) {
+			return &fs.PathError{Op: op, Path: name, Err: ErrProtectedPath}
+		}
+	}
+	return nil
+}
+
+// protect adds p to the protected paths.
+func (t *Trash) protect(p string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !slices.Contains(t.protected, p) {
+		t.protected = append(t.protected, p)
+	}
+}
+
+// removes reports whether removing dir removes p.
+func removes(dir, p string) bool {
+	return p == dir || dir == "/" && path.IsAbs(p) || strings.HasPrefix(p, dir+"/")
+}
+
+// move moves the named file or directory of f to a new item directory.
+func (t *Trash) move(f FS, name string) error {
+	dir, err := t.itemDir(f)
+	if err != nil {
+		return err
+	}
+	if err := f.WriteFile(path.Join(dir, trashInfoName), []byte(path.Clean(name)), 0o644); err != nil {
+		return errors.Join(err, f.RemoveAll(dir))
+	}
+	if err := f.Rename(name, path.Join(dir, trashDataName)); err != nil {
+		return errors.Join(err, f.RemoveAll(dir))
+	}
+	return nil
+}
+
+// itemDir creates a new directory named by the current time.
+func (t *Trash) itemDir(f FS) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for now := t.now().UTC(); ; now = now.Add(time.Nanosecond) {
+		dir := path.Join(t.dir, now.Format(versionTimeLayout))
+		if _, err := fs.Stat(f, dir); err == nil {
+			continue
+		}
+		return dir, f.MkdirAll(dir, 0o700)
+	}
+}
+
+// basePather is implemented by the wrappers confining all paths to a directory
+// of the wrapped [FS], e.g. [WithBaseDir].
+type basePather interface {
+	basePath() string
+}
+
+// protectRoot makes the [WithTrash] layers of inner refuse removing the root
+// of f, if f confines all paths to a directory of inner. The paths of the
+// base directories between them are joined.
+func protectRoot(f, inner ReadOnlyFS) {
+	b, ok := baseLayer(f).(basePather)
+	if !ok {
+		return
+	}
+	root := b.basePath()
+	for l := inner; l != nil && root != "."; l = Unwrap(l) {
+		switch l := baseLayer(l).(type) {
+		case basePather:
+			root = path.Join(l.basePath(), root)
+		case *trashRemove:
+			l.trash.protect(root)
+		}
+	}
+}
+
+type trashRemove struct {
+	wrapped
+	trash *Trash
+	// err is [ErrAlreadyBound] if the trash is used by another [FS].
+	err error
+}
+
+// WithTrash is an option for [NewFS] that wraps the [FS] so that Remove and
+// RemoveAll move files and directories to the trash instead of deleting them.
+// Removing the root of the [FS], a path outside of it, the trash directory or
+// a path protected with [WithTrashProtect] fails with [ErrProtectedPath].
+//
+// Removing the root of [WithBaseDir] is refused whether the option is applied
+// outside or inside of it.
+//
+// A trash is bound to the first [FS] the option is applied to; removing with
+// other ones fails with [ErrAlreadyBound].
+func WithTrash(t *Trash) Option {
+	return func(fs FS) FS {
+		return &trashRemove{wrapped: wrapped{fs}, trash: t, err: t.bind(fs)}
+	}
+}
+
+// Remove moves the named file to the trash, empty directories are removed.
+func (t *trashRemove) Remove(name string) error {
+	if err := t.check("remove", name); err != nil {
+		return err
+	}
+	info, err := fs.Stat(t.FS, name)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return t.FS.Remove(name)
+	}
+	return t.trash.move(t.FS, name)
+}
+
+// RemoveAll moves the named file or directory to the trash.
+func (t *trashRemove) RemoveAll(name string) error {
+	if err := t.check("removeall", name); err != nil {
+		return err
+	}
+	if _, err := fs.Stat(t.FS, name); errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return t.trash.move(t.FS, name)
+}
+
+func (t *trashRemove) check(op, name string) error {
+	if t.err != nil {
+		return &fs.PathError{Op: op, Path: name, Err: t.err}
+	}
+	return t.trash.check(op, name)
+}
+
+func (*trashRemove) String() string {
+	return "WithTrash()"
+}
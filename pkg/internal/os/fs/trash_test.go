@@ -0,0 +1,145 @@
+package fs_test
+
+import (
+	iofs "io/fs"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"go.mws.cloud/util-toolset/pkg/internal/os/fs"
+	"go.mws.cloud/util-toolset/pkg/internal/testing/fstest"
+)
+
+func TestTrash(t *testing.T) {
+	fstest.RunFS(t, func(t *testing.T, base fs.FS) {
+		c := newClock()
+		trash := fs.NewTrash(fs.WithTrashClock(c.Now))
+		f := fs.NewFS(base, fs.WithTrash(trash))
+
+		require.NoError(t, f.WriteFile("dir/sub/a.txt", []byte("a"), 0o644))
+		require.NoError(t, f.WriteFile("b.txt", []byte("b"), 0o644))
+		require.NoError(t, f.MkdirAll("empty", os.ModePerm))
+
+		require.NoError(t, f.Remove("b.txt"))
+		c.Advance(time.Minute)
+		require.NoError(t, f.RemoveAll("dir/sub"))
+		require.NoError(t, f.RemoveAll("missing"))
+		require.NoError(t, f.Remove("empty"))
+		require.Error(t, f.Remove("dir/missing"))
+
+		_, err := iofs.Stat(f, "dir/sub")
+		require.ErrorIs(t, err, iofs.ErrNotExist)
+		_, err = iofs.Stat(f, "empty")
+		require.ErrorIs(t, err, iofs.ErrNotExist)
+
+		items, err := trash.Items()
+		require.NoError(t, err)
+		require.Len(t, items, 2)
+		require.Equal(t, "dir/sub", items[0].Path)
+		require.Equal(t, "b.txt", items[1].Path)
+		require.Equal(t, c.Now(), items[0].Time)
+		require.Equal(t, c.Now().Add(-time.Minute), items[1].Time)
+
+		c.Advance(time.Minute)
+		require.NoError(t, f.RemoveAll("dir"))
+		require.NoError(t, trash.Restore(items[0]))
+		require.Equal(t, "a", readString(t, f, "dir/sub/a.txt"))
+
+		require.NoError(t, f.WriteFile("b.txt", []byte("new"), 0o644))
+		require.ErrorIs(t, trash.Restore(items[1]), iofs.ErrExist)
+
+		c.Advance(30 * time.Minute)
+		require.NoError(t, trash.Purge(time.Hour))
+		items, err = trash.Items()
+		require.NoError(t, err)
+		require.Len(t, items, 2)
+
+		require.NoError(t, trash.Purge(31*time.Minute))
+		items, err = trash.Items()
+		require.NoError(t, err)
+		require.Len(t, items, 1)
+		require.Equal(t, "dir", items[0].Path)
+
+		require.NoError(t, trash.Purge(0))
+		items, err = trash.Items()
+		require.NoError(t, err)
+		require.Empty(t, items)
+	}, fs.WithDirCreate(os.ModePerm))
+}
+
+func TestTrashProtected(t *testing.T) {
+	base := fs.NewFS(fs.NewMapFS(), fs.WithDirCreate(os.ModePerm))
+	f := fs.NewFS(base, fs.WithTrash(fs.NewTrash(fs.WithTrashDir("trash"), fs.WithTrashProtect("keep/root"))))
+	require.NoError(t, f.WriteFile("keep/root/a.txt", []byte("a"), 0o644))
+	require.NoError(t, f.WriteFile("trash/x", []byte("x"), 0o644))
+
+	for _, name := range []string{".", "", "/", "..", "../x", "a/../..", "trash", "trash/x", "keep", "keep/root", "keep/./root/"} {
+		require.ErrorIs(t, f.RemoveAll(name), fs.ErrProtectedPath, name)
+		require.ErrorIs(t, f.Remove(name), fs.ErrProtectedPath, name)
+	}
+	require.NoError(t, f.Remove("keep/root/a.txt"))
+}
+
+func TestTrashBaseDir(t *testing.T) {
+	base := fs.NewFS(fs.NewMapFS(), fs.WithDirCreate(os.ModePerm))
+	f := fs.NewFS(base, fs.WithTrash(fs.NewTrash()), fs.WithBaseDir("out"))
+	require.NoError(t, f.WriteFile("a.txt", []byte("a"), 0o644))
+
+	require.ErrorIs(t, f.RemoveAll("."), fs.ErrProtectedPath)
+	require.NoError(t, f.Remove("a.txt"))
+
+	entries, err := base.ReadDir("out")
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, ".trash", entries[0].Name())
+}
+
+func TestTrashInsideBaseDir(t *testing.T) {
+	base := fs.NewFS(fs.NewMapFS(), fs.WithDirCreate(os.ModePerm))
+	f := fs.NewFS(base, fs.WithBaseDir("out"), fs.WithTrash(fs.NewTrash()))
+	require.NoError(t, f.WriteFile("a.txt", []byte("a"), 0o644))
+
+	for _, name := range []string{".", "", "/", "dir/.."} {
+		require.ErrorIs(t, f.RemoveAll(name), fs.ErrProtectedPath, name)
+		require.ErrorIs(t, f.Remove(name), fs.ErrProtectedPath, name)
+	}
+	require.Equal(t, "a", readString(t, base, "out/a.txt"))
+	require.NoError(t, f.Remove("a.txt"))
+}
+
+func TestTrashNestedBaseDir(t *testing.T) {
+	base := fs.NewFS(fs.NewMapFS(), fs.WithDirCreate(os.ModePerm))
+	f := fs.NewFS(base, fs.WithBaseDir("sub"), fs.WithBaseDir("out"), fs.WithTrash(fs.NewTrash()))
+	require.NoError(t, f.WriteFile("a.txt", []byte("a"), 0o644))
+
+	require.ErrorIs(t, f.RemoveAll("."), fs.ErrProtectedPath)
+	require.Equal(t, "a", readString(t, base, "out/sub/a.txt"))
+
+	sub, err := fs.Sub(f, "dir")
+	require.NoError(t, err)
+	require.NoError(t, sub.WriteFile("b.txt", []byte("b"), 0o644))
+	require.ErrorIs(t, sub.RemoveAll("."), fs.ErrProtectedPath)
+	require.NoError(t, sub.Remove("b.txt"))
+	require.NoError(t, f.Remove("a.txt"))
+}
+
+func TestTrashBinding(t *testing.T) {
+	trash := fs.NewTrash()
+	_, err := trash.Items()
+	require.ErrorIs(t, err, fs.ErrNotBound)
+	require.ErrorIs(t, trash.Restore(fs.TrashItem{Path: "a.txt"}), fs.ErrNotBound)
+	require.ErrorIs(t, trash.Purge(0), fs.ErrNotBound)
+
+	f := fs.NewFS(fs.NewMapFS(), fs.WithTrash(trash))
+	other := fs.NewFS(fs.NewMapFS(), fs.WithTrash(trash))
+	require.NoError(t, f.WriteFile("a.txt", nil, 0o644))
+	require.NoError(t, other.WriteFile("a.txt", nil, 0o644))
+	require.NoError(t, f.Remove("a.txt"))
+	require.ErrorIs(t, other.Remove("a.txt"), fs.ErrAlreadyBound)
+
+	items, err := trash.Items()
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+}
@@ -0,0 +1,87 @@
+package fs
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// treeNode is a file or a directory printed by [WriteTree].
+type treeNode struct {
+	name     string
+	info     fs.FileInfo
+	children []*treeNode
+}
+
+// WriteTree writes a listing of all the files and directories of f to w
+// in the format of the tree utility, with modes and sizes of the files:
+//
+//	.
+//	├── a.txt (-rw-r--r--, 5 B)
+//	└── dir (drwxr-xr-x)
+//	    └── b.txt (-rwxr-xr-x, 0 B)
+func WriteTree(w io.Writer, f ReadOnlyFS) error {
+	files, err := List(f, WithListDirs())
+	if err != nil {
+		return err
+	}
+
+	root := &treeNode{name: "."}
+	nodes := map[string]*treeNode{".": root}
+	for _, info := range files {
+		node := &treeNode{name: path.Base(info.Name()), info: info}
+		nodes[info.Name()] = node
+
+		dir := path.Dir(info.Name())
+		parent, ok := nodes[dir]
+		if !ok {
+			// Absolute paths of the map file system, see [ListDir].
+			parent = &treeNode{name: dir}
+			nodes[dir] = parent
+			root.children = append(root.children, parent)
+		}
+		parent.children = append(parent.children, node)
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, root.name)
+	writeTreeChildren(bw, root, "")
+	return bw.Flush()
+}
+
+func writeTreeChildren(w io.Writer, node *treeNode, indent string) {
+	for i, child := range node.children {
+		branch, next := "├── ", "│   "
+		if i == len(node.children)-1 {
+			branch, next = "└── ", "    "
+		}
+
+		fmt.Fprintf(w, "%s%s%s%s\n", indent, branch, child.name, describeTreeNode(child.info))
+		writeTreeChildren(w, child, indent+next)
+	}
+}
+
+func describeTreeNode(info fs.FileInfo) string {
+	switch {
+	case info == nil:
+		return ""
+	case info.IsDir():
+		return fmt.Sprintf(" (%s)", info.Mode())
+	default:
+		return fmt.Sprintf(" (%s, %d B)", info.Mode(), info.Size())
+	}
+}
+
+// Tree returns the listing of f written by [WriteTree]. If f cannot be
+// listed, the error is returned in place of the listing, so it can be
+// used in test failure messages.
+func Tree(f ReadOnlyFS) string {
+	var b strings.Builder
+	if err := WriteTree(&b, f); err != nil {
+		return err.Error()
+	}
+	return b.String()
+}
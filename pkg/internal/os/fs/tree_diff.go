@@ -0,0 +1,205 @@
+package fs
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"strings"
+
+	"go.mws.cloud/util-toolset/pkg/internal/diff"
+)
+
+// TreeChangeKind is a set of differences of an entry reported by [DiffTree].
+type TreeChangeKind uint32
+
+const (
+	// TreeAdded is reported for an entry which exists only in the new tree.
+	TreeAdded TreeChangeKind = 1 << iota
+	// TreeRemoved is reported for an entry which exists only in the old tree.
+	TreeRemoved
+	// TreeModified is reported for a file which content differs.
+	TreeModified
+	// TreeModeChanged is reported for an entry which permissions differ.
+	TreeModeChanged
+)
+
+var treeChangeKindNames = []string{"added", "removed", "modified", "mode"}
+
+// Has reports whether k contains all the differences of o.
+func (k TreeChangeKind) Has(o TreeChangeKind) bool {
+	return k&o == o
+}
+
+func (k TreeChangeKind) String() string {
+	var names []string
+	for i, name := range treeChangeKindNames {
+		if k.Has(1 << i) {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, "|")
+}
+
+// TreeChange is a difference of a single entry between two trees.
+type TreeChange struct {
+	Path  string
+	Kind  TreeChangeKind
+	IsDir bool
+	// OldMode and NewMode are the modes of the entry in the old and the new
+	// tree, the mode is zero on the side where the entry does not exist.
+	OldMode, NewMode fs.FileMode
+}
+
+func (c TreeChange) String() string {
+	if c.IsDir {
+		return c.Kind.String() + " " + c.Path + "/"
+	}
+	return c.Kind.String() + " " + c.Path
+}
+
+// DiffTree compares two trees and returns the differences sorted by path.
+// Every entry of an added or removed directory is reported. If the type of
+// an entry has changed, it is reported as removed and then added.
+func DiffTree(oldFS, newFS ReadOnlyFS) ([]TreeChange, error) {
+	oldEntries, err := List(oldFS, WithListDirs())
+	if err != nil {
+		return nil, err
+	}
+	newEntries, err := List(newFS, WithListDirs())
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []TreeChange
+	for len(oldEntries) > 0 || len(newEntries) > 0 {
+		var o, n fs.FileInfo
+		switch {
+		case len(newEntries) == 0 || len(oldEntries) > 0 && oldEntries[0].Name() < newEntries[0].Name():
+			o, oldEntries = oldEntries[0], oldEntries[1:]
+		case len(oldEntries) == 0 || newEntries[0].Name() < oldEntries[0].Name():
+			n, newEntries = newEntries[0], newEntries[1:]
+		default:
+			o, n, oldEntries, newEntries = oldEntries[0], newEntries[0], oldEntries[1:], newEntries[1:]
+		}
+
+		switch {
+		case n == nil:
+			changes = append(changes, TreeChange{Path: o.Name(), Kind: TreeRemoved, IsDir: o.IsDir(), OldMode: o.Mode()})
+		case o == nil:
+			changes = append(changes, TreeChange{Path: n.Name(), Kind: TreeAdded, IsDir: n.IsDir(), NewMode: n.Mode()})
+		case o.IsDir() != n.IsDir():
+			changes = append(changes,
+				TreeChange{Path: o.Name(), Kind: TreeRemoved, IsDir: o.IsDir(), OldMode: o.Mode()},
+				TreeChange{Path: n.Name(), Kind: TreeAdded, IsDir: n.IsDir(), NewMode: n.Mode()},
+			)
+		default:
+			c, err := compareEntries(oldFS, newFS, o, n)
+			if err != nil {
+				return nil, err
+			}
+			if c.Kind != 0 {
+				changes = append(changes, c)
+			}
+		}
+	}
+	return changes, nil
+}
+
+// TreeDiffOption is an option for [WriteTreeDiff].
+type TreeDiffOption func(*treeDiffConfig)
+
+type treeDiffConfig struct {
+	format  []diff.Option
+	noModes bool
+}
+
+// WithTreeDiffFormat passes the options to [diff.Unified] for every file.
+func WithTreeDiffFormat(options ...diff.Option) TreeDiffOption {
+	return func(c *treeDiffConfig) {
+		c.format = append(c.format, options...)
+	}
+}
+
+// WithTreeDiffNoModes makes [WriteTreeDiff] ignore the modes: the files which
+// differ only in mode are omitted, and no mode headers are written.
+func WithTreeDiffNoModes() TreeDiffOption {
+	return func(c *treeDiffConfig) {
+		c.noModes = true
+	}
+}
+
+// WriteTreeDiff writes the differences between the trees as a git-style
+// patch. Directories are omitted, as in git.
+func WriteTreeDiff(w io.Writer, oldFS, newFS ReadOnlyFS, options ...TreeDiffOption) error {
+	var cfg treeDiffConfig
+	for _, o := range options {
+		o(&cfg)
+	}
+
+	changes, err := DiffTree(oldFS, newFS)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range changes {
+		if c.IsDir || cfg.noModes && c.Kind == TreeModeChanged {
+			continue
+		}
+
+		p := filePatch{name: c.Path, oldMode: c.OldMode, newMode: c.NewMode}
+		if cfg.noModes {
+			p.oldMode, p.newMode = 0, 0
+		}
+		if !c.Kind.Has(TreeAdded) {
+			if p.oldData, err = readTreeFile(oldFS, c.Path); err != nil {
+				return err
+			}
+		}
+		if !c.Kind.Has(TreeRemoved) {
+			if p.newData, err = readTreeFile(newFS, c.Path); err != nil {
+				return err
+			}
+		}
+		if err = p.write(w, cfg.format...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func compareEntries(oldFS, newFS ReadOnlyFS, o, n fs.FileInfo) (TreeChange, error) {
+	c := TreeChange{Path: o.Name(), IsDir: o.IsDir(), OldMode: o.Mode(), NewMode: n.Mode()}
+	if o.Mode() != n.Mode() {
+		c.Kind |= TreeModeChanged
+	}
+	if o.IsDir() {
+		return c, nil
+	}
+
+	modified := o.Size() != n.Size()
+	if !modified {
+		oldData, err := readTreeFile(oldFS, c.Path)
+		if err != nil {
+			return c, err
+		}
+		newData, err := readTreeFile(newFS, c.Path)
+		if err != nil {
+			return c, err
+		}
+		modified = !bytes.Equal(oldData, newData)
+	}
+	if modified {
+		c.Kind |= TreeModified
+	}
+	return c, nil
+}
+
+// readTreeFile reads the file and never returns nil data, as nil means the
+// file does not exist for the patch.
+func readTreeFile(f ReadOnlyFS, name string) ([]byte, error) {
+	data, err := fs.ReadFile(f, name)
+	if data == nil && err == nil {
+		data = []byte{}
+	}
+	return data, err
+}
@@ -0,0 +1,90 @@
+package fs_test
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go.mws.cloud/util-toolset/pkg/internal/diff"
+	"go.mws.cloud/util-toolset/pkg/internal/os/fs"
+)
+
+func TestDiffTree(t *testing.T) {
+	oldFS := fs.NewFS(fs.NewMapFS(), fs.WithDirCreate(os.ModePerm))
+	require.NoError(t, oldFS.WriteFile("same.txt", []byte("same\n"), 0o644))
+	require.NoError(t, oldFS.WriteFile("changed.txt", []byte("old\n"), 0o644))
+	require.NoError(t, oldFS.WriteFile("mode.sh", []byte("echo\n"), 0o644))
+	require.NoError(t, oldFS.WriteFile("removed/r.txt", []byte("r\n"), 0o644))
+	require.NoError(t, oldFS.WriteFile("kind", []byte("file\n"), 0o644))
+
+	newFS := fs.NewFS(fs.NewMapFS(), fs.WithDirCreate(os.ModePerm))
+	require.NoError(t, newFS.WriteFile("same.txt", []byte("same\n"), 0o644))
+	require.NoError(t, newFS.WriteFile("changed.txt", []byte("new\n"), 0o644))
+	require.NoError(t, newFS.WriteFile("mode.sh", []byte("echo\n"), 0o755))
+	require.NoError(t, newFS.WriteFile("added.txt", []byte("a\n"), 0o644))
+	require.NoError(t, newFS.MkdirAll("kind", os.ModePerm))
+
+	changes, err := fs.DiffTree(oldFS, newFS)
+	require.NoError(t, err)
+
+	var names []string
+	for _, c := range changes {
+		names = append(names, c.String())
+	}
+	require.Equal(t, []string{
+		"added added.txt",
+		"modified changed.txt",
+		"removed kind",
+		"added kind/",
+		"mode mode.sh",
+		"removed removed/",
+		"removed removed/r.txt",
+	}, names)
+	require.Equal(t, os.FileMode(0o644), changes[4].OldMode)
+	require.Equal(t, os.FileMode(0o755), changes[4].NewMode)
+
+	var b bytes.Buffer
+	require.NoError(t, fs.WriteTreeDiff(&b, oldFS, newFS, fs.WithTreeDiffFormat(diff.WithContext(0))))
+	require.Equal(t, `diff --git a/added.txt b/added.txt
+new file mode 100644
+--- /dev/null
++++ b/added.txt
+@@ -0,0 +1 @@
++a
+diff --git a/changed.txt b/changed.txt
+--- a/changed.txt
++++ b/changed.txt
+@@ -1 +1 @@
+-old
++new
+diff --git a/kind b/kind
+deleted file mode 100644
+--- a/kind
++++ /dev/null
+@@ -1 +0,0 @@
+-file
+diff --git a/mode.sh b/mode.sh
+old mode 100644
+new mode 100755
+diff --git a/removed/r.txt b/removed/r.txt
+deleted file mode 100644
+--- a/removed/r.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-r
+`, b.String())
+
+	b.Reset()
+	require.NoError(t, fs.WriteTreeDiff(&b, oldFS, newFS, fs.WithTreeDiffNoModes()))
+	require.NotContains(t, b.String(), "mode")
+	require.Contains(t, b.String(), "diff --git a/added.txt b/added.txt\n--- /dev/null\n")
+}
+
+func TestTreeChangeKind(t *testing.T) {
+	k := fs.TreeModified | fs.TreeModeChanged
+	require.True(t, k.Has(fs.TreeModified))
+	require.False(t, k.Has(fs.TreeAdded))
+	require.Equal(t, "modified|mode", k.String())
+}
@@ -0,0 +1,125 @@
+package fs
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// Txtar archive format is described in https://pkg.go.dev/golang.org/x/tools/txtar.
+// An archive consists of a comment followed by files, each file starts
+// with a "-- NAME --" marker line. Directories are not stored.
+var (
+	txtarMarker    = []byte("-- ")
+	txtarMarkerEnd = []byte(" --")
+	newline        = []byte("\n")
+)
+
+// txtarFileMode is the mode of the files loaded from txtar archives.
+const txtarFileMode fs.FileMode = 0o644
+
+// NewTxtarFS returns [FS] built with [NewMapFS] containing all the files
+// of the txtar archive. The archive comment is ignored.
+func NewTxtarFS(data []byte) (FS, error) {
+	f := NewMapFS()
+	_, name, data := findTxtarMarker(data)
+	for name != "" {
+		var fileName string
+		var content []byte
+		content, fileName, data = findTxtarMarker(data)
+
+		p := cleanRelPath(name)
+		if dir := path.Dir(p); dir != "." {
+			if err := f.MkdirAll(dir, fs.ModePerm); err != nil {
+				return nil, err
+			}
+		}
+		if err := f.WriteFile(p, content, txtarFileMode); err != nil {
+			return nil, err
+		}
+		name = fileName
+	}
+
+	return f, nil
+}
+
+// WriteTxtar writes all the regular files of src to w as a txtar archive,
+// the files are sorted by path.
+func WriteTxtar(w io.Writer, src fs.FS) error {
+	return CopyFS(NewTxtarWriter(w), src)
+}
+
+type txtarFormat struct{ w io.Writer }
+
+// NewTxtarWriter returns [WriteOnlyFS] that streams files written with
+// WriteFile or OpenFile into w as a txtar archive. Files are written in the
+// order of WriteFile calls or closing of the files opened with OpenFile.
+//
+// A newline is added to the content not ending with it. MkdirAll writes
+// nothing, as txtar does not store directories. Data written to w cannot be
+// changed, so writing the same path twice fails with [fs.ErrExist], while
+// Rename, Remove and RemoveAll fail with [errors.ErrUnsupported].
+func NewTxtarWriter(w io.Writer) WriteOnlyFS {
+	return newArchiveWriter(&txtarFormat{w: w}, []ArchiveOption{WithArchiveStreaming()})
+}
+
+func (t *txtarFormat) writeEntry(e archiveEntry) error {
+	if e.data == nil {
+		return nil
+	}
+
+	var b bytes.Buffer
+	b.Write(txtarMarker)
+	b.WriteString(e.name)
+	b.Write(txtarMarkerEnd)
+	b.Write(newline)
+	b.Write(e.data)
+	if len(e.data) > 0 && !bytes.HasSuffix(e.data, newline) {
+		b.Write(newline)
+	}
+
+	_, err := t.w.Write(b.Bytes())
+	return err
+}
+
+func (*txtarFormat) close() error {
+	return nil
+}
+
+// findTxtarMarker finds the next marker line in data and returns the data
+// before it, the file name from the marker and the data after it.
+// If there is no marker, name is empty and before is the whole data.
+func findTxtarMarker(data []byte) (before []byte, name string, after []byte) {
+	var i int
+	for {
+		if name, after = isTxtarMarker(data[i:]); name != "" {
+			return data[:i], name, after
+		}
+		j := bytes.Index(data[i:], []byte("\n-- "))
+		if j < 0 {
+			return data, "", nil
+		}
+		i += j + 1
+	}
+}
+
+// isTxtarMarker checks whether data begins with a marker line and returns
+// the name from it and the data after the line.
+func isTxtarMarker(data []byte) (name string, after []byte) {
+	if !bytes.HasPrefix(data, txtarMarker) {
+		return "", nil
+	}
+
+	line := data
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		line, after = data[:i], data[i+1:]
+	}
+	line = bytes.TrimSuffix(line, []byte("\r"))
+	if !bytes.HasSuffix(line, txtarMarkerEnd) || len(line) < len(txtarMarker)+len(txtarMarkerEnd) {
+		return "", nil
+	}
+
+	return strings.TrimSpace(string(line[len(txtarMarker) : len(line)-len(txtarMarkerEnd)])), after
+}
@@ -0,0 +1,64 @@
+package fs_test
+
+import (
+	"bytes"
+	iofs "io/fs"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go.mws.cloud/util-toolset/pkg/internal/os/fs"
+)
+
+const testTxtar = `comment is ignored
+-- b/c.txt --
+c
+-- a.txt --
+a
+-- empty --
+-- no_newline --
+x`
+
+func TestTxtarFS(t *testing.T) {
+	f, err := fs.NewTxtarFS([]byte(testTxtar))
+	require.NoError(t, err)
+	require.Equal(t, []string{"a.txt", "b/c.txt", "empty", "no_newline"}, collectElements(t, f))
+
+	data, err := fs.ReadFile(f, "b/c.txt")
+	require.NoError(t, err)
+	require.Equal(t, []byte("c\n"), data)
+
+	data, err = fs.ReadFile(f, "no_newline")
+	require.NoError(t, err)
+	require.Equal(t, []byte("x"), data)
+
+	var b bytes.Buffer
+	require.NoError(t, fs.WriteTxtar(&b, f))
+	require.Equal(t, `-- a.txt --
+a
+-- b/c.txt --
+c
+-- empty --
+-- no_newline --
+x
+`, b.String())
+}
+
+func TestTxtarWriter(t *testing.T) {
+	var b bytes.Buffer
+	w := fs.NewTxtarWriter(&b)
+
+	require.NoError(t, w.MkdirAll("dir", os.ModePerm))
+	require.NoError(t, w.WriteFile("/dir/x.txt", []byte("x\n"), 0o644))
+
+	f, err := w.OpenFile("y.txt", os.O_CREATE|os.O_WRONLY, 0o644)
+	require.NoError(t, err)
+	_, err = f.WriteString("y")
+	require.NoError(t, err)
+	require.Empty(t, b.String()[len("-- dir/x.txt --\nx\n"):])
+	require.NoError(t, f.Close())
+
+	require.ErrorIs(t, w.WriteFile("dir/x.txt", nil, 0o644), iofs.ErrExist)
+	require.Equal(t, "-- dir/x.txt --\nx\n-- y.txt --\ny\n", b.String())
+}
@@ -1,7 +1,13 @@
 package fs
 
 import (
+	"fmt"
 	"io/fs"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"slices"
+	"strings"
 	"sync"
 )
 
@@ -9,29 +15,61 @@ import (
 // with a name that already exists in the unique wrapper.
 type NonUniqueError struct {
 	Name string
+	// First is the path written first, it differs from Name when a file
+	// conflicts with a directory, e.g. "a" with "a/b.txt".
+	First string
+	// Op is the operation of the first write, e.g. "WriteFile".
+	Op string
+	// Location is the file and the line of the first write outside of this
+	// package, e.g. "/src/gen/main.go:42".
+	Location string
 }
 
 func (e *NonUniqueError) Error() string {
-	return "trying to write " + e.Name + " more than once"
+	msg := "trying to write " + e.Name + " more than once"
+	if e.First != "" && e.First != e.Name {
+		msg = "trying to write " + e.Name + " which conflicts with " + e.First
+	}
+	if e.Op != "" {
+		msg += ", first written by " + e.Op
+	}
+	if e.Location != "" {
+		msg += " at " + e.Location
+	}
+	return msg
+}
+
+// uniqueEntry is a path written through the unique wrapper.
+type uniqueEntry struct {
+	dir      bool
+	op       string
+	location string
 }
 
 // unique option doesn't allow to create more than one file with same name.
 type unique struct {
-	FS
+	wrapped
 
 	mu    sync.Mutex
-	files map[string]struct{}
+	files map[string]uniqueEntry
+	// parents counts the written entries under every directory, so that
+	// a file conflicting with them is found without a scan.
+	parents map[string]int
 }
 
-// WithUnique is an option for [NewFS] that wraps the [FS] so that MkdirAll,
-// WriteFile, and Rename return an error if the target path was already used by
-// this [FS] instance.
+// WithUnique is an option for [NewFS] that wraps the [FS] so that every path is
+// written at most once by this [FS] instance: MkdirAll, WriteFile, Rename and
+// OpenFile with write flags return [*NonUniqueError] if the target path was
+// already written, or if it is a file conflicting with a written directory or
+// the other way around. Paths are compared after [filepath.Clean]. Remove,
+// RemoveAll and Rename release the removed and moved paths.
 func WithUnique() Option {
 	return func(fs FS) FS {
 		return &unique{
-			FS: fs,
+			wrapped: wrapped{fs},
 
-			files: make(map[string]struct{}),
+			files:   make(map[string]uniqueEntry),
+			parents: make(map[string]int),
 		}
 	}
 }
@@ -40,14 +78,15 @@ func (u *unique) MkdirAll(path string, perm fs.FileMode) error {
 	u.mu.Lock()
 	defer u.mu.Unlock()
 
-	if _, ok := u.files[path]; ok {
-		return &NonUniqueError{Name: path}
+	p := filepath.Clean(path)
+	if err := u.check(path, p, true); err != nil {
+		return err
 	}
 	if err := u.FS.MkdirAll(path, perm); err != nil {
 		return err
 	}
 
-	u.files[path] = struct{}{}
+	u.add(p, uniqueEntry{dir: true, op: "MkdirAll", location: callerLocation()})
 	return nil
 }
 
@@ -55,30 +94,63 @@ func (u *unique) WriteFile(name string, data []byte, perm fs.FileMode) error {
 	u.mu.Lock()
 	defer u.mu.Unlock()
 
-	if _, ok := u.files[name]; ok {
-		return &NonUniqueError{Name: name}
+	p := filepath.Clean(name)
+	if err := u.check(name, p, false); err != nil {
+		return err
 	}
 	if err := u.FS.WriteFile(name, data, perm); err != nil {
 		return err
 	}
 
-	u.files[name] = struct{}{}
+	u.add(p, uniqueEntry{op: "WriteFile", location: callerLocation()})
 	return nil
 }
 
+func (u *unique) OpenFile(name string, flag int, perm fs.FileMode) (WritableFile, error) {
+	if flag&writeFlags == 0 {
+		return u.FS.OpenFile(name, flag, perm)
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	p := filepath.Clean(name)
+	if err := u.check(name, p, false); err != nil {
+		return nil, err
+	}
+	f, err := u.FS.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+
+	u.add(p, uniqueEntry{op: "OpenFile", location: callerLocation()})
+	return f, nil
+}
+
 func (u *unique) Rename(src, dst string) error {
 	u.mu.Lock()
 	defer u.mu.Unlock()
 
-	if _, ok := u.files[dst]; ok {
-		return &NonUniqueError{Name: dst}
+	s, d := filepath.Clean(src), filepath.Clean(dst)
+	moved := u.subtree(s)
+	entry, ok := u.files[s]
+	if err := u.check(dst, d, ok && entry.dir || len(moved) > 1); err != nil {
+		return err
 	}
 	if err := u.FS.Rename(src, dst); err != nil {
 		return err
 	}
 
-	delete(u.files, src)
-	u.files[dst] = struct{}{}
+	location := callerLocation()
+	for _, p := range moved {
+		e := u.files[p]
+		u.remove(p)
+		e.op, e.location = "Rename", location
+		u.add(d+strings.TrimPrefix(p, s), e)
+	}
+	if !ok {
+		u.add(d, uniqueEntry{dir: len(moved) > 0, op: "Rename", location: location})
+	}
 	return nil
 }
 
@@ -88,7 +160,106 @@ func (u *unique) Remove(name string) error {
 	}
 
 	u.mu.Lock()
-	delete(u.files, name)
+	u.remove(filepath.Clean(name))
 	u.mu.Unlock()
 	return nil
 }
+
+func (u *unique) RemoveAll(name string) error {
+	if err := u.FS.RemoveAll(name); err != nil {
+		return err
+	}
+
+	u.mu.Lock()
+	for _, p := range u.subtree(filepath.Clean(name)) {
+		u.remove(p)
+	}
+	u.mu.Unlock()
+	return nil
+}
+
+// check returns [*NonUniqueError] if the path was written, or conflicts with
+// a written file or directory.
+func (u *unique) check(name, p string, dir bool) error {
+	conflict := func(first string) error {
+		e := u.files[first]
+		return &NonUniqueError{Name: name, First: first, Op: e.op, Location: e.location}
+	}
+
+	if _, ok := u.files[p]; ok {
+		return conflict(p)
+	}
+	for parent := filepath.Dir(p); parent != p; p, parent = parent, filepath.Dir(parent) {
+		if e, ok := u.files[parent]; ok && !e.dir {
+			return conflict(parent)
+		}
+	}
+	if children := u.subtree(filepath.Clean(name)); !dir && len(children) > 0 {
+		return conflict(slices.Min(children))
+	}
+	return nil
+}
+
+// subtree returns the written path and the paths under it.
+func (u *unique) subtree(p string) []string {
+	var paths []string
+	if _, ok := u.files[p]; ok {
+		paths = append(paths, p)
+	}
+	if u.parents[p] == 0 {
+		return paths
+	}
+	prefix := p + string(filepath.Separator)
+	for f := range u.files {
+		if strings.HasPrefix(f, prefix) {
+			paths = append(paths, f)
+		}
+	}
+	return paths
+}
+
+func (u *unique) add(p string, e uniqueEntry) {
+	if _, ok := u.files[p]; !ok {
+		u.forParents(p, 1)
+	}
+	u.files[p] = e
+}
+
+func (u *unique) remove(p string) {
+	if _, ok := u.files[p]; ok {
+		u.forParents(p, -1)
+		delete(u.files, p)
+	}
+}
+
+func (u *unique) forParents(p string, delta int) {
+	for parent := filepath.Dir(p); parent != p; p, parent = parent, filepath.Dir(parent) {
+		if u.parents[parent] += delta; u.parents[parent] == 0 {
+			delete(u.parents, parent)
+		}
+	}
+}
+
+// packagePath is the import path of this package, its frames are skipped
+// by callerLocation.
+var packagePath = reflect.TypeFor[unique]().PkgPath()
+
+// callerLocation returns the file and the line of the first caller outside
+// of this package.
+func callerLocation() string {
+	pcs := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, packagePath+".") {
+			return fmt.Sprintf("%s:%d", frame.File, frame.Line)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
+
+func (*unique) String() string {
+	return "WithUnique()"
+}
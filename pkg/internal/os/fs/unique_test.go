@@ -4,6 +4,7 @@ import (
 	"os"
 	"testing"
 
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 	"go.uber.org/mock/gomock"
 
@@ -91,3 +92,44 @@ func (s *uniqueTestSuite) nonUniqueError(err error) {
 	var nue *fs.NonUniqueError
 	s.ErrorAs(err, &nue)
 }
+
+func TestUniqueComplete(t *testing.T) {
+	f := fs.NewFS(fs.NewMapFS(), fs.WithUnique(), fs.WithDirCreate(os.ModePerm))
+
+	require.NoError(t, f.WriteFile("dir/a.txt", nil, os.ModePerm))
+	err := f.WriteFile("dir/./sub/../a.txt", nil, os.ModePerm)
+	var nue *fs.NonUniqueError
+	require.ErrorAs(t, err, &nue)
+	require.Equal(t, "dir/a.txt", nue.First)
+	require.Equal(t, "WriteFile", nue.Op)
+	require.Regexp(t, `unique_test\.go:\d+$`, nue.Location)
+	require.Regexp(t, `^trying to write dir/\./sub/\.\./a\.txt which conflicts with dir/a\.txt, first written by WriteFile at .*unique_test\.go:\d+$`, err.Error())
+
+	// A file conflicts with a written directory and the other way around.
+	require.ErrorAs(t, f.WriteFile("dir", nil, os.ModePerm), &nue)
+	require.Equal(t, "dir/a.txt", nue.First)
+	require.ErrorAs(t, f.MkdirAll("dir/a.txt/sub", os.ModePerm), &nue)
+	require.Equal(t, "dir/a.txt", nue.First)
+	require.NoError(t, f.MkdirAll("dir/sub", os.ModePerm))
+	require.ErrorAs(t, f.MkdirAll("dir/sub/", os.ModePerm), &nue)
+	require.Equal(t, "MkdirAll", nue.Op)
+
+	w, err := f.OpenFile("dir/b.txt", os.O_CREATE|os.O_WRONLY, os.ModePerm)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	_, err = f.OpenFile("dir/b.txt", os.O_WRONLY|os.O_APPEND, os.ModePerm)
+	require.ErrorAs(t, err, &nue)
+	require.Equal(t, "OpenFile", nue.Op)
+	r, err := f.OpenFile("dir/b.txt", os.O_RDONLY, 0)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+
+	require.NoError(t, f.Rename("dir", "moved"))
+	require.NoError(t, f.WriteFile("dir/a.txt", nil, os.ModePerm))
+	require.ErrorAs(t, f.WriteFile("moved/b.txt", nil, os.ModePerm), &nue)
+	require.Equal(t, "Rename", nue.Op)
+
+	require.NoError(t, f.RemoveAll("moved"))
+	require.NoError(t, f.WriteFile("moved/b.txt", nil, os.ModePerm))
+	require.NoError(t, f.WriteFile("moved/sub", nil, os.ModePerm))
+}
@@ -0,0 +1,332 @@
+package fs
+
+//go:generate mockgen -source=watch.go -destination=mock/mockwatch.go
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WatchOp is a set of operations reported by [Watch].
+type WatchOp uint32
+
+const (
+	// WatchCreate is reported when a file or a directory is created or moved
+	// to the path, e.g. by Rename of [WithAtomicWrite].
+	WatchCreate WatchOp = 1 << iota
+	// WatchWrite is reported when a file opened for writing is closed.
+	WatchWrite
+	// WatchRemove is reported when a file or a directory is removed.
+	WatchRemove
+	// WatchRename is reported when a file or a directory is moved from the path.
+	WatchRename
+)
+
+var watchOpNames = []string{"create", "write", "remove", "rename"}
+
+// Has reports whether op contains all the operations of o.
+func (op WatchOp) Has(o WatchOp) bool {
+	return op&o == o
+}
+
+func (op WatchOp) String() string {
+	var names []string
+	for i, name := range watchOpNames {
+		if op.Has(1 << i) {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, "|")
+}
+
+// WatchEvent is a change of a file or a directory reported by [Watch].
+type WatchEvent struct {
+	// Path is the path of the changed file in the watched [FS].
+	Path string
+	// Op is the set of operations, it has several bits if the events were
+	// merged by [WithWatchDebounce].
+	Op WatchOp
+}
+
+func (e WatchEvent) String() string {
+	return e.Op.String() + " " + e.Path
+}
+
+// WatchFS is an optional interface that can be implemented by [FS] implementations
+// which report changes of files. Use [Watch] instead of calling it directly.
+//
+// [FS] returned by [NewRealFS] uses inotify on Linux. [FS] returned by [NewMapFS]
+// queues the events synchronously within the write operations, so they are
+// received in order once the operations return. The options of this package
+// forward watches, translating the paths.
+type WatchFS interface {
+	// Watch reports changes of the entries of the directory and, if recursive
+	// is set, of its subdirectories, including the ones created later. The
+	// channel is closed when the context is done.
+	Watch(ctx context.Context, name string, recursive bool) (<-chan WatchEvent, error)
+}
+
+// WatchOption is an option for [Watch].
+type WatchOption func(*watchConfig)
+
+type watchConfig struct {
+	recursive bool
+	debounce  time.Duration
+	globs     []string
+}
+
+// WithWatchRecursive makes [Watch] report changes in all the subdirectories.
+func WithWatchRecursive() WatchOption {
+	return func(c *watchConfig) {
+		c.recursive = true
+	}
+}
+
+// WithWatchDebounce makes [Watch] collect events until there are none for
+// the duration d, and then report them merged by path in order of the first
+// events. It is useful when a change is a burst of writes, e.g. a build.
+func WithWatchDebounce(d time.Duration) WatchOption {
+	return func(c *watchConfig) {
+		c.debounce = d
+	}
+}
+
+// WithWatchGlob makes [Watch] report only the paths matching any of the
+// patterns, see [path.Match]. The patterns are matched against the path
+// relative to the watched directory; a pattern without slashes is also matched
+// against the base name, so "*.go" matches Go files in all the subdirectories.
+func WithWatchGlob(patterns ...string) WatchOption {
+	return func(c *watchConfig) {
+		c.globs = append(c.globs, patterns...)
+	}
+}
+
+// Watch reports changes of the named file or directory of f until the context
+// is done, when the channel is closed. For a directory, the changes of its
+// entries are reported. f must implement [WatchFS], otherwise [errors.ErrUnsupported]
+// is returned.
+//
+// Events are sent as they come unless [WithWatchDebounce] is used, so the
+// channel must be read until it is closed.
+func Watch(ctx context.Context, f ReadOnlyFS, name string, options ...WatchOption) (<-chan WatchEvent, error) {
+	var cfg watchConfig
+	for _, o := range options {
+		o(&cfg)
+	}
+	for _, pattern := range cfg.globs {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, &fs.PathError{Op: "watch", Path: pattern, Err: err}
+		}
+	}
+
+	info, err := fs.Stat(f, name)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, file := name, ""
+	if !info.IsDir() {
+		// A file is watched through its directory, so that replacing it,
+		// e.g. with [WithAtomicWrite], is reported as well.
+		dir, file = filepath.Dir(name), name
+	}
+
+	events, err := watchFS(ctx, f, dir, cfg.recursive && file == "")
+	if err != nil {
+		return nil, err
+	}
+
+	filter := func(e WatchEvent) bool {
+		if file != "" {
+			rel, ok := relWatchPath(file, e.Path)
+			return ok && rel == "."
+		}
+		return matchWatchGlobs(cfg.globs, dir, e.Path)
+	}
+
+	out := make(chan WatchEvent)
+	go func() {
+		defer close(out)
+		if cfg.debounce > 0 {
+			debounceEvents(ctx, events, out, filter, cfg.debounce)
+			return
+		}
+		for e := range events {
+			if filter(e) && !sendEvent(ctx, out, e) {
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// watchFS calls Watch of f if it implements [WatchFS].
+func watchFS(ctx context.Context, f ReadOnlyFS, name string, recursive bool) (<-chan WatchEvent, error) {
+	if w, ok := f.(WatchFS); ok {
+		return w.Watch(ctx, name, recursive)
+	}
+	return nil, &fs.PathError{Op: "watch", Path: name, Err: errors.ErrUnsupported}
+}
+
+func matchWatchGlobs(globs []string, dir, p string) bool {
+	if len(globs) == 0 {
+		return true
+	}
+
+	rel, ok := relWatchPath(dir, p)
+	if !ok {
+		return false
+	}
+	for _, pattern := range globs {
+		if ok, _ := path.Match(pattern, rel); ok {
+			return true
+		}
+		if !strings.Contains(pattern, "/") {
+			if ok, _ := path.Match(pattern, path.Base(rel)); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// relWatchPath returns p relative to dir as a slash-separated path.
+// Leading slashes are ignored, as [FS] returned by [NewMapFS] does not keep them.
+func relWatchPath(dir, p string) (string, bool) {
+	d, q := cleanRelPath(filepath.ToSlash(dir)), cleanRelPath(filepath.ToSlash(p))
+	switch {
+	case d == ".":
+		return q, true
+	case q == d:
+		return ".", true
+	case strings.HasPrefix(q, d+"/"):
+		return q[len(d)+1:], true
+	default:
+		return "", false
+	}
+}
+
+// mapWatchEvents forwards the events transformed by fn, events for which
+// fn returns false are dropped.
+func mapWatchEvents(ctx context.Context, events <-chan WatchEvent, fn func(*WatchEvent) bool) <-chan WatchEvent {
+	out := make(chan WatchEvent)
+	go func() {
+		defer close(out)
+		for e := range events {
+			if fn(&e) && !sendEvent(ctx, out, e) {
+				return
+			}
+		}
+	}()
+	return out
+}
+
+func sendEvent(ctx context.Context, out chan<- WatchEvent, e WatchEvent) bool {
+	select {
+	case out <- e:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// debounceEvents collects events, merging them by path, and sends them when
+// there are no events for the duration d or the events channel is closed.
+func debounceEvents(ctx context.Context, events <-chan WatchEvent, out chan<- WatchEvent, filter func(WatchEvent) bool, d time.Duration) {
+	var pending []WatchEvent
+	index := map[string]int{}
+	timer := time.NewTimer(d)
+	timer.Stop()
+	defer timer.Stop()
+
+	flush := func() bool {
+		for _, e := range pending {
+			if !sendEvent(ctx, out, e) {
+				return false
+			}
+		}
+		pending, index = nil, map[string]int{}
+		return true
+	}
+
+	for {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				flush()
+				return
+			}
+			if !filter(e) {
+				continue
+			}
+			if i, ok := index[e.Path]; ok {
+				pending[i].Op |= e.Op
+			} else {
+				index[e.Path] = len(pending)
+				pending = append(pending, e)
+			}
+			timer.Reset(d)
+		case <-timer.C:
+			if !flush() {
+				return
+			}
+		}
+	}
+}
+
+// eventQueue is an unbounded queue of events, so that events can be queued
+// synchronously without waiting for the reader.
+type eventQueue struct {
+	mu     sync.Mutex
+	events []WatchEvent
+	notify chan struct{}
+}
+
+func newEventQueue() *eventQueue {
+	return &eventQueue{notify: make(chan struct{}, 1)}
+}
+
+func (q *eventQueue) push(e WatchEvent) {
+	q.mu.Lock()
+	q.events = append(q.events, e)
+	q.mu.Unlock()
+
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+// run sends the queued events to the returned channel until the context is done.
+func (q *eventQueue) run(ctx context.Context, done func()) <-chan WatchEvent {
+	out := make(chan WatchEvent)
+	go func() {
+		defer close(out)
+		defer done()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-q.notify:
+			}
+
+			q.mu.Lock()
+			events := q.events
+			q.events = nil
+			q.mu.Unlock()
+
+			for _, e := range events {
+				if !sendEvent(ctx, out, e) {
+					return
+				}
+			}
+		}
+	}()
+	return out
+}
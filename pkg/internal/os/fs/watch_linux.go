@@ -0,0 +1,154 @@
+//go:build linux
+
+package fs
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// inotifyMask is the set of inotify events reported by [watchOS].
+const inotifyMask = syscall.IN_CREATE | syscall.IN_CLOSE_WRITE | syscall.IN_DELETE |
+	syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO | syscall.IN_ONLYDIR
+
+// inotifyBufferSize fits many events, every event takes
+// syscall.SizeofInotifyEvent bytes plus the name.
+const inotifyBufferSize = 64 << 10
+
+type inotify struct {
+	file      *os.File
+	fd        int
+	recursive bool
+	// dirs are watched directories by the watch descriptors.
+	dirs map[int]string
+}
+
+// watchOS watches the directory of OS file system with inotify.
+func watchOS(ctx context.Context, name string, recursive bool) (<-chan WatchEvent, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, &fs.PathError{Op: "watch", Path: name, Err: err}
+	}
+	// The file is non-blocking, so Read is interrupted by Close.
+	w := &inotify{file: os.NewFile(uintptr(fd), "inotify"), fd: fd, recursive: recursive, dirs: map[int]string{}}
+
+	if err := w.add(name); err != nil {
+		return nil, errors.Join(err, w.file.Close())
+	}
+	if recursive {
+		if err := w.addSubdirs(name, nil); err != nil {
+			return nil, errors.Join(err, w.file.Close())
+		}
+	}
+
+	go func() {
+		<-ctx.Done()
+		_ = w.file.Close()
+	}()
+
+	out := make(chan WatchEvent)
+	go func() {
+		defer close(out)
+		w.read(ctx, out)
+	}()
+	return out, nil
+}
+
+func (w *inotify) add(dir string) error {
+	wd, err := syscall.InotifyAddWatch(w.fd, dir, inotifyMask)
+	if err != nil {
+		return &fs.PathError{Op: "watch", Path: dir, Err: err}
+	}
+	w.dirs[wd] = dir
+	return nil
+}
+
+// addSubdirs watches all the subdirectories of dir. If events is not nil,
+// the entries found are reported as created, as they could be created
+// before the watch was added.
+func (w *inotify) addSubdirs(dir string, events *[]WatchEvent) error {
+	return filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			// Removed while walking.
+			return nil
+		case err != nil:
+			return err
+		case p == dir:
+			return nil
+		}
+
+		if events != nil {
+			*events = append(*events, WatchEvent{Path: p, Op: WatchCreate})
+		}
+		if d.IsDir() {
+			return w.add(p)
+		}
+		return nil
+	})
+}
+
+func (w *inotify) read(ctx context.Context, out chan<- WatchEvent) {
+	buf := make([]byte, inotifyBufferSize)
+	for {
+		n, err := w.file.Read(buf)
+		if err != nil {
+			return
+		}
+
+		for _, e := range w.parse(buf[:n]) {
+			if !sendEvent(ctx, out, e) {
+				return
+			}
+		}
+	}
+}
+
+// parse parses the events read from inotify, see inotify(7).
+func (w *inotify) parse(buf []byte) []WatchEvent {
+	var events []WatchEvent
+	for len(buf) >= syscall.SizeofInotifyEvent {
+		wd := int(int32(binary.NativeEndian.Uint32(buf[0:])))
+		mask := binary.NativeEndian.Uint32(buf[4:])
+		nameLen := int(binary.NativeEndian.Uint32(buf[12:]))
+		if len(buf) < syscall.SizeofInotifyEvent+nameLen {
+			break
+		}
+		name := strings.TrimRight(string(buf[syscall.SizeofInotifyEvent:syscall.SizeofInotifyEvent+nameLen]), "\x00")
+		buf = buf[syscall.SizeofInotifyEvent+nameLen:]
+
+		dir, ok := w.dirs[wd]
+		if mask&syscall.IN_IGNORED != 0 {
+			delete(w.dirs, wd)
+			continue
+		}
+		if !ok || name == "" {
+			continue
+		}
+
+		p := filepath.Join(dir, name)
+		switch {
+		case mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0:
+			events = append(events, WatchEvent{Path: p, Op: WatchCreate})
+			if w.recursive && mask&syscall.IN_ISDIR != 0 {
+				// Errors are ignored, the directory may be already removed.
+				if w.add(p) == nil {
+					_ = w.addSubdirs(p, &events)
+				}
+			}
+		case mask&syscall.IN_CLOSE_WRITE != 0:
+			events = append(events, WatchEvent{Path: p, Op: WatchWrite})
+		case mask&syscall.IN_DELETE != 0:
+			events = append(events, WatchEvent{Path: p, Op: WatchRemove})
+		case mask&syscall.IN_MOVED_FROM != 0:
+			events = append(events, WatchEvent{Path: p, Op: WatchRename})
+		}
+	}
+	return events
+}
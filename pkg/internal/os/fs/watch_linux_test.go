@@ -0,0 +1,48 @@
+//go:build linux
+
+package fs_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"go.mws.cloud/util-toolset/pkg/internal/os/fs"
+)
+
+func TestWatchInotify(t *testing.T) {
+	// The channel is closed on timeout, if an event is missing.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	dir := t.TempDir()
+	f := fs.NewFS(fs.NewRealFS(), fs.WithBaseDir(dir), fs.WithAtomicWrite(), fs.WithDirCreate(os.ModePerm))
+	require.NoError(t, f.MkdirAll("old", os.ModePerm))
+
+	events, err := fs.Watch(ctx, f, ".", fs.WithWatchRecursive())
+	require.NoError(t, err)
+
+	require.NoError(t, f.WriteFile("old/a.txt", []byte("a"), 0o644))
+	require.Equal(t, []string{"create old/a.txt"}, receiveEvents(t, events, 1))
+
+	require.NoError(t, f.MkdirAll("new", os.ModePerm))
+	require.Equal(t, []string{"create new"}, receiveEvents(t, events, 1))
+
+	// Files written bypassing the wrappers are reported as well.
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "new", "b.txt"), nil, 0o644))
+	require.Equal(t, []string{"create new/b.txt", "write new/b.txt"}, receiveEvents(t, events, 2))
+
+	require.NoError(t, f.Rename("new/b.txt", "old/b.txt"))
+	require.NoError(t, f.RemoveAll("old"))
+	require.ElementsMatch(t, []string{
+		"rename new/b.txt", "create old/b.txt", "remove old/a.txt", "remove old/b.txt", "remove old",
+	}, receiveEvents(t, events, 5))
+
+	cancel()
+	for range events {
+	}
+}
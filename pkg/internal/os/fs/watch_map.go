@@ -0,0 +1,185 @@
+package fs
+
+import (
+	"context"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// memWatchers are the watchers of [FS] returned by [NewMapFS].
+type memWatchers struct {
+	mu       sync.Mutex
+	watchers map[*memWatcher]struct{}
+}
+
+type memWatcher struct {
+	dir       string
+	recursive bool
+	queue     *eventQueue
+}
+
+func (w *memWatchers) watch(ctx context.Context, name string, recursive bool) <-chan WatchEvent {
+	watcher := &memWatcher{dir: cleanRelPath(name), recursive: recursive, queue: newEventQueue()}
+
+	w.mu.Lock()
+	if w.watchers == nil {
+		w.watchers = map[*memWatcher]struct{}{}
+	}
+	w.watchers[watcher] = struct{}{}
+	w.mu.Unlock()
+
+	return watcher.queue.run(ctx, func() {
+		w.mu.Lock()
+		delete(w.watchers, watcher)
+		w.mu.Unlock()
+	})
+}
+
+// active reports whether there are any watchers, so that the state before
+// an operation is looked up only when it is needed.
+func (w *memWatchers) active() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return len(w.watchers) > 0
+}
+
+// emit queues the event for the watchers of the directory containing it.
+func (w *memWatchers) emit(op WatchOp, name string) {
+	p := cleanRelPath(name)
+	if p == "." {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for watcher := range w.watchers {
+		dir := path.Dir(p)
+		if dir == watcher.dir || watcher.recursive &&
+			(watcher.dir == "." || strings.HasPrefix(dir, watcher.dir+"/")) {
+			watcher.queue.push(WatchEvent{Path: p, Op: op})
+		}
+	}
+}
+
+// Watch queues events synchronously within the write operations.
+func (m *mapFS) Watch(ctx context.Context, name string, recursive bool) (<-chan WatchEvent, error) {
+	info, err := m.a.Stat(path.Clean(name))
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, &fs.PathError{Op: "watch", Path: name, Err: syscall.ENOTDIR}
+	}
+	return m.watchers.watch(ctx, name, recursive), nil
+}
+
+func (m *mapFS) exists(name string) bool {
+	_, err := m.a.Stat(path.Clean(name))
+	return err == nil
+}
+
+func (m *mapFS) OpenFile(name string, flag int, perm fs.FileMode) (WritableFile, error) {
+	if flag&writeFlags == 0 || !m.watchers.active() {
+		return m.aferoFS.OpenFile(name, flag, perm)
+	}
+
+	existed := m.exists(name)
+	f, err := m.aferoFS.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	if !existed {
+		m.watchers.emit(WatchCreate, name)
+	}
+
+	return &closeHookFile{WritableFile: f, onClose: func() error {
+		m.watchers.emit(WatchWrite, name)
+		return nil
+	}}, nil
+}
+
+func (m *mapFS) MkdirAll(name string, perm fs.FileMode) error {
+	if !m.watchers.active() {
+		return m.aferoFS.MkdirAll(name, perm)
+	}
+
+	var created []string
+	for dir := path.Clean(name); dir != "." && dir != "/" && !m.exists(dir); dir = path.Dir(dir) {
+		created = append(created, dir)
+	}
+	if err := m.aferoFS.MkdirAll(name, perm); err != nil {
+		return err
+	}
+	for _, dir := range slices.Backward(created) {
+		m.watchers.emit(WatchCreate, dir)
+	}
+	return nil
+}
+
+func (m *mapFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	if !m.watchers.active() {
+		return m.aferoFS.WriteFile(name, data, perm)
+	}
+
+	existed := m.exists(name)
+	if err := m.aferoFS.WriteFile(name, data, perm); err != nil {
+		return err
+	}
+	if !existed {
+		m.watchers.emit(WatchCreate, name)
+	}
+	m.watchers.emit(WatchWrite, name)
+	return nil
+}
+
+func (m *mapFS) Rename(src, dst string) error {
+	if err := m.aferoFS.Rename(src, dst); err != nil {
+		return err
+	}
+	m.watchers.emit(WatchRename, src)
+	m.watchers.emit(WatchCreate, dst)
+	return nil
+}
+
+func (m *mapFS) Remove(name string) error {
+	if err := m.aferoFS.Remove(name); err != nil {
+		return err
+	}
+	m.watchers.emit(WatchRemove, name)
+	return nil
+}
+
+func (m *mapFS) RemoveAll(name string) error {
+	if !m.watchers.active() {
+		return m.aferoFS.RemoveAll(name)
+	}
+
+	var removed []string
+	if files, err := listDir(m.aferoFS, path.Clean(name), listConfig{dirs: true}); err == nil {
+		for _, f := range files {
+			removed = append(removed, f.Name())
+		}
+	}
+	// The walk includes the root only if it is a file.
+	if m.exists(name) && !slices.Contains(removed, path.Clean(name)) {
+		removed = append(removed, name)
+	}
+
+	if err := m.aferoFS.RemoveAll(name); err != nil {
+		return err
+	}
+	// Entries are removed before their directories.
+	slices.SortStableFunc(removed, func(l, r string) int {
+		return strings.Count(r, "/") - strings.Count(l, "/")
+	})
+	for _, p := range removed {
+		m.watchers.emit(WatchRemove, p)
+	}
+	return nil
+}
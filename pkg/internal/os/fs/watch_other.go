@@ -0,0 +1,14 @@
+//go:build !linux
+
+package fs
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+)
+
+// watchOS is not supported on this platform.
+func watchOS(_ context.Context, name string, _ bool) (<-chan WatchEvent, error) {
+	return nil, &fs.PathError{Op: "watch", Path: name, Err: errors.ErrUnsupported}
+}
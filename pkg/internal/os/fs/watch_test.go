@@ -0,0 +1,212 @@
+package fs_test
+
+import (
+	"context"
+	"errors"
+	iofs "io/fs"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"go.mws.cloud/util-toolset/pkg/internal/os/fs"
+)
+
+// receiveEvents receives n events from the channel. Events of [fs.NewMapFS]
+// are queued synchronously within the write operations, so they are already
+// there once the operations return.
+func receiveEvents(t *testing.T, events <-chan fs.WatchEvent, n int) []string {
+	t.Helper()
+
+	var result []string
+	for len(result) < n {
+		e, ok := <-events
+		require.True(t, ok, "channel closed after %v", result)
+		result = append(result, e.String())
+	}
+	return result
+}
+
+// requireNoEvents checks that there are no more events in the channel before
+// the ones of the marker file written to f.
+func requireNoEvents(t *testing.T, f fs.FS, events <-chan fs.WatchEvent, marker string) {
+	t.Helper()
+
+	require.NoError(t, f.WriteFile(marker, nil, 0o644))
+	e, ok := <-events
+	require.True(t, ok, "channel closed")
+	require.Equal(t, marker, e.Path, "unexpected event %s", e)
+}
+
+func TestWatchMapFS(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	f := fs.NewMapFS()
+	events, err := fs.Watch(ctx, f, ".", fs.WithWatchRecursive())
+	require.NoError(t, err)
+
+	require.NoError(t, f.WriteFile("a.txt", []byte("a"), 0o644))
+	require.NoError(t, f.WriteFile("a.txt", []byte("b"), 0o644))
+	require.NoError(t, f.MkdirAll("dir/sub", os.ModePerm))
+	w, err := f.OpenFile("dir/sub/b.txt", os.O_CREATE|os.O_WRONLY, 0o644)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	require.NoError(t, f.Rename("a.txt", "dir/c.txt"))
+	require.NoError(t, f.Remove("dir/c.txt"))
+	require.NoError(t, f.RemoveAll("dir/sub/b.txt"))
+	require.NoError(t, f.RemoveAll("dir"))
+
+	require.Equal(t, []string{
+		"create a.txt",
+		"write a.txt",
+		"write a.txt",
+		"create dir",
+		"create dir/sub",
+		"create dir/sub/b.txt",
+		"write dir/sub/b.txt",
+		"rename a.txt",
+		"create dir/c.txt",
+		"remove dir/c.txt",
+		"remove dir/sub/b.txt",
+		"remove dir/sub",
+		"remove dir",
+	}, receiveEvents(t, events, 13))
+	requireNoEvents(t, f, events, "end.txt")
+
+	cancel()
+	_, ok := <-events
+	require.False(t, ok)
+}
+
+func TestWatchOptions(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	f := fs.NewFS(fs.NewMapFS(), fs.WithDirCreate(os.ModePerm))
+	require.NoError(t, f.MkdirAll("dir/sub", os.ModePerm))
+
+	flat, err := fs.Watch(ctx, f, "dir")
+	require.NoError(t, err)
+	globs, err := fs.Watch(ctx, f, "dir", fs.WithWatchRecursive(), fs.WithWatchGlob("*.go", "sub/*.txt"))
+	require.NoError(t, err)
+	sub, err := fs.Watch(ctx, f, "dir/sub")
+	require.NoError(t, err)
+
+	require.NoError(t, f.WriteFile("dir/main.go", nil, 0o644))
+	require.NoError(t, f.WriteFile("dir/sub/x.go", nil, 0o644))
+	require.NoError(t, f.WriteFile("dir/sub/x.txt", nil, 0o644))
+	require.NoError(t, f.WriteFile("dir/y.txt", nil, 0o644))
+
+	require.Equal(t, []string{
+		"create dir/main.go", "write dir/main.go", "create dir/y.txt", "write dir/y.txt",
+	}, receiveEvents(t, flat, 4))
+	require.Equal(t, []string{
+		"create dir/main.go", "write dir/main.go",
+		"create dir/sub/x.go", "write dir/sub/x.go",
+		"create dir/sub/x.txt", "write dir/sub/x.txt",
+	}, receiveEvents(t, globs, 6))
+	require.Len(t, receiveEvents(t, sub, 4), 4)
+
+	_, err = fs.Watch(ctx, f, "dir", fs.WithWatchGlob("["))
+	require.Error(t, err)
+	_, err = fs.Watch(ctx, f, "missing")
+	require.ErrorIs(t, err, os.ErrNotExist)
+	_, err = fs.Watch(ctx, noLockFS{f}, "dir")
+	require.ErrorIs(t, err, errors.ErrUnsupported)
+}
+
+func TestWatchWrappers(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	m := fs.NewMapFS()
+	f := fs.NewFS(m, fs.WithBaseDir("out"), fs.WithAtomicWrite(), fs.WithDirCreate(os.ModePerm))
+	require.NoError(t, f.WriteFile("a.txt", []byte("a"), 0o644))
+	require.NoError(t, f.WriteFile("b.txt", []byte("b"), 0o644))
+
+	// A single file is watched, and the temporary files are not reported.
+	events, err := fs.Watch(ctx, f, "a.txt")
+	require.NoError(t, err)
+	require.NoError(t, f.WriteFile("b.txt", []byte("bb"), 0o644))
+	require.NoError(t, f.WriteFile("a.txt", []byte("aa"), 0o644))
+	require.Equal(t, []string{"create a.txt"}, receiveEvents(t, events, 1))
+	requireNoEvents(t, f, events, "a.txt")
+
+	mount := fs.NewMountFS(map[string]fs.FS{"/mnt": f})
+	events, err = fs.Watch(ctx, mount, "mnt", fs.WithWatchRecursive())
+	require.NoError(t, err)
+	require.NoError(t, mount.WriteFile("mnt/dir/c.txt", nil, 0o644))
+	require.Equal(t, []string{"create mnt/dir", "create mnt/dir/c.txt"}, receiveEvents(t, events, 2))
+}
+
+func TestWatchAtomicWrite(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	m := fs.NewMapFS()
+	f := fs.NewFS(m, fs.WithAtomicWrite())
+	events, err := fs.Watch(ctx, f, ".")
+	require.NoError(t, err)
+
+	// Files named NAME.tmp which are not written by the wrapper are reported.
+	require.NoError(t, f.WriteFile("a.txt", nil, 0o644))
+	require.NoError(t, m.WriteFile("cache.tmp", nil, 0o644))
+	require.NoError(t, f.WriteFile("b.txt", nil, 0o644))
+	require.Equal(t, []string{
+		"create a.txt", "create cache.tmp", "write cache.tmp", "create b.txt",
+	}, receiveEvents(t, events, 4))
+	requireNoEvents(t, m, events, "end.txt")
+
+	require.NoError(t, m.MkdirAll("tmp", os.ModePerm))
+	f = fs.NewFS(m, fs.WithAtomicWriteCustomDir("tmp"))
+	events, err = fs.Watch(ctx, f, ".", fs.WithWatchRecursive())
+	require.NoError(t, err)
+	require.NoError(t, f.WriteFile("b.tmp", nil, 0o644))
+	require.Equal(t, []string{"create b.tmp"}, receiveEvents(t, events, 1))
+	requireNoEvents(t, m, events, "end.txt")
+}
+
+func TestWatchOverlay(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	base, upper := fs.NewMapFS(), fs.NewMapFS()
+	require.NoError(t, base.MkdirAll("dir/sub", os.ModePerm))
+	o := fs.NewOverlayFS(base, upper)
+
+	// The directory exists only in the base, and it is not created by Watch.
+	flat, err := fs.Watch(ctx, o, "dir")
+	require.NoError(t, err)
+	recursive, err := fs.Watch(ctx, o, "dir", fs.WithWatchRecursive())
+	require.NoError(t, err)
+	_, err = iofs.Stat(upper, "dir")
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	require.NoError(t, o.WriteFile("dir/sub/a.txt", nil, 0o644))
+	require.NoError(t, o.WriteFile("dir/b.txt", nil, 0o644))
+	require.Equal(t, []string{"create dir/sub", "create dir/b.txt", "write dir/b.txt"}, receiveEvents(t, flat, 3))
+	require.Equal(t, []string{
+		"create dir/sub", "create dir/sub/a.txt", "write dir/sub/a.txt", "create dir/b.txt", "write dir/b.txt",
+	}, receiveEvents(t, recursive, 5))
+	requireNoEvents(t, o, flat, "dir/end.txt")
+}
+
+func TestWatchDebounce(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	f := fs.NewMapFS()
+	events, err := fs.Watch(ctx, f, ".", fs.WithWatchDebounce(50*time.Millisecond))
+	require.NoError(t, err)
+
+	for range 3 {
+		require.NoError(t, f.WriteFile("a.txt", nil, 0o644))
+		require.NoError(t, f.WriteFile("b.txt", nil, 0o644))
+	}
+	require.NoError(t, f.Remove("b.txt"))
+
+	require.Equal(t, []string{"create|write a.txt", "create|write|remove b.txt"}, receiveEvents(t, events, 2))
+	requireNoEvents(t, f, events, "c.txt")
+}
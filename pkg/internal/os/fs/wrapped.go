@@ -0,0 +1,135 @@
+package fs
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"time"
+)
+
+// wrapped is embedded by the wrappers of this package in place of [FS]. It
+// forwards the optional interfaces and [Wrapper] to the wrapped [FS], so a
+// wrapper defines only the methods it changes. [NewFS] hides the optional
+// interfaces the wrapped [FS] does not implement, see [withCapabilities].
+type wrapped struct {
+	FS
+}
+
+func (w wrapped) Chmod(name string, mode fs.FileMode) error {
+	return Chmod(w.FS, name, mode)
+}
+
+func (w wrapped) Chtimes(name string, atime, mtime time.Time) error {
+	return Chtimes(w.FS, name, atime, mtime)
+}
+
+func (w wrapped) Lock(ctx context.Context, name string, mode LockMode) (Unlocker, error) {
+	return Lock(ctx, w.FS, name, mode)
+}
+
+func (w wrapped) Watch(ctx context.Context, name string, recursive bool) (<-chan WatchEvent, error) {
+	return watchFS(ctx, w.FS, name, recursive)
+}
+
+func (w wrapped) Unwrap() FS {
+	return w.FS
+}
+
+// forwards marks the wrappers embedding wrapped, see [withCapabilities].
+func (wrapped) forwards() {}
+
+//go:generate go run gen_capabilities.go
+
+// capability is a set of the optional interfaces of this package.
+type capability uint8
+
+const (
+	capChmod capability = 1 << iota
+	capChtimes
+	capLock
+	capWatch
+)
+
+// capabilities implements all the optional interfaces of this package.
+type capabilities interface {
+	FS
+	ChmodFS
+	ChtimesFS
+	LockFS
+	WatchFS
+}
+
+// ownCapabilities is implemented by the wrappers which implement some of the
+// optional interfaces by themselves, regardless of the wrapped [FS].
+type ownCapabilities interface {
+	ownCapabilities() capability
+}
+
+// shell embeds the layer restricted by [narrow], hiding its optional interfaces.
+type shell struct {
+	FS
+}
+
+func (s shell) base() ReadOnlyFS {
+	return s.FS
+}
+
+func (s shell) Unwrap() FS {
+	return Unwrap(s.FS)
+}
+
+func (s shell) String() string {
+	if str, ok := s.FS.(fmt.Stringer); ok {
+		return str.String()
+	}
+	return fmt.Sprintf("%T", s.FS)
+}
+
+func capabilitiesOf(f ReadOnlyFS) capability {
+	var c capability
+	if _, ok := f.(ChmodFS); ok {
+		c |= capChmod
+	}
+	if _, ok := f.(ChtimesFS); ok {
+		c |= capChtimes
+	}
+	if _, ok := f.(LockFS); ok {
+		c |= capLock
+	}
+	if _, ok := f.(WatchFS); ok {
+		c |= capWatch
+	}
+	return c
+}
+
+// withCapabilities restricts the optional interfaces of the wrapper f of this
+// package to the ones implemented by the wrapped inner and by f itself, so
+// type assertions on f report only them. Other [FS] are returned as is.
+func withCapabilities(f, inner FS) FS {
+	l, ok := f.(capabilities)
+	if _, forwards := f.(interface{ forwards() }); !ok || !forwards {
+		return f
+	}
+
+	c := capabilitiesOf(inner)
+	if o, ok := f.(ownCapabilities); ok {
+		c |= o.ownCapabilities()
+	}
+	return narrow(l, c)
+}
+
+// wrap returns the wrapper f of inner restricted by [withCapabilities], and
+// makes the layers of inner refuse removing the root f confines paths to,
+// see [protectRoot].
+func wrap(f, inner FS) FS {
+	protectRoot(f, inner)
+	return withCapabilities(f, inner)
+}
+
+// baseLayer returns the layer restricted by [withCapabilities], or f itself.
+func baseLayer(f ReadOnlyFS) ReadOnlyFS {
+	if s, ok := f.(interface{ base() ReadOnlyFS }); ok {
+		return s.base()
+	}
+	return f
+}
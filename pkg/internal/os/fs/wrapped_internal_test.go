@@ -0,0 +1,48 @@
+package fs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithCapabilities(t *testing.T) {
+	full, ok := NewMapFS().(capabilities)
+	require.True(t, ok)
+
+	options := map[string]Option{
+		"atomic write": WithAtomicWrite(),
+		"base dir":     WithBaseDir("out"),
+		"cache":        WithCache(NewCache()),
+		"changed only": WithChangedOnly(),
+		"dir create":   WithDirCreate(os.ModePerm),
+		"interceptor":  WithInterceptor(Interceptor{}),
+		"report":       WithReport(NewReport()),
+		"unique":       WithUnique(),
+	}
+	for c := range capChmod | capChtimes | capLock | capWatch + 1 {
+		inner := narrow(full, c)
+		require.Equal(t, c, capabilitiesOf(inner))
+
+		for name, o := range options {
+			t.Run(fmt.Sprintf("%s %04b", name, c), func(t *testing.T) {
+				f := NewFS(inner, o)
+				require.Equal(t, c, capabilitiesOf(f))
+				require.Equal(t, inner, Unwrap(f))
+				require.NotContains(t, Describe(f), "shell")
+
+				if c&capLock != 0 {
+					u, err := Lock(context.Background(), f, "x.lock", LockExclusive)
+					require.NoError(t, err)
+					require.NoError(t, u.Unlock())
+				}
+			})
+		}
+
+		f := NewFS(inner, WithStdoutPrint())
+		require.Equal(t, c|capChmod|capChtimes|capLock, capabilitiesOf(f))
+	}
+}
@@ -0,0 +1,89 @@
+package fs
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+)
+
+// bufferFile is a [WritableFile] which collects written data in memory and
+// passes it to onClose when closed. Read operations are not supported.
+type bufferFile struct {
+	buf     bytes.Buffer
+	name    string
+	onClose func(data []byte) error
+	closed  bool
+}
+
+var _ WritableFile = (*bufferFile)(nil)
+
+func (f *bufferFile) Name() string {
+	return f.name
+}
+
+func (f *bufferFile) Close() error {
+	if f.closed {
+		return f.error("close", fs.ErrClosed)
+	}
+
+	f.closed = true
+	return f.onClose(f.buf.Bytes())
+}
+
+func (f *bufferFile) Write(p []byte) (int, error) {
+	if f.closed {
+		return 0, f.error("write", fs.ErrClosed)
+	}
+	return f.buf.Write(p)
+}
+
+func (f *bufferFile) WriteString(s string) (int, error) {
+	if f.closed {
+		return 0, f.error("write", fs.ErrClosed)
+	}
+	return f.buf.WriteString(s)
+}
+
+func (f *bufferFile) Read([]byte) (int, error) {
+	return 0, f.error("read", errors.ErrUnsupported)
+}
+
+func (f *bufferFile) ReadAt([]byte, int64) (int, error) {
+	return 0, f.error("read", errors.ErrUnsupported)
+}
+
+func (f *bufferFile) Seek(int64, int) (int64, error) {
+	return 0, f.error("seek", errors.ErrUnsupported)
+}
+
+func (f *bufferFile) WriteAt([]byte, int64) (int, error) {
+	return 0, f.error("write", errors.ErrUnsupported)
+}
+
+func (f *bufferFile) Readdir(int) ([]fs.FileInfo, error) {
+	return nil, f.error("readdir", errors.ErrUnsupported)
+}
+
+func (f *bufferFile) Readdirnames(int) ([]string, error) {
+	return nil, f.error("readdir", errors.ErrUnsupported)
+}
+
+func (f *bufferFile) Stat() (fs.FileInfo, error) {
+	return nil, f.error("stat", errors.ErrUnsupported)
+}
+
+func (*bufferFile) Sync() error {
+	return nil
+}
+
+func (f *bufferFile) Truncate(size int64) error {
+	if size < 0 || size > int64(f.buf.Len()) {
+		return f.error("truncate", fs.ErrInvalid)
+	}
+	f.buf.Truncate(int(size))
+	return nil
+}
+
+func (f *bufferFile) error(op string, err error) error {
+	return &fs.PathError{Op: op, Path: f.name, Err: err}
+}
@@ -0,0 +1,55 @@
+package fs
+
+import (
+	"archive/zip"
+	"io"
+	"io/fs"
+)
+
+type zipFormat struct{ w *zip.Writer }
+
+// NewZipWriter returns [ArchiveWriter] that writes files and directories
+// into w as a zip archive. All the entries get the same modification time,
+// see [WithArchiveModTime], and only permission bits of the file modes are
+// kept, so the archive is reproducible. The entries are kept in
+// memory and written sorted by path on Close, unless [WithArchiveStreaming]
+// is used.
+//
+// Data written to w cannot be changed, so writing the same path twice fails
+// with [fs.ErrExist], while Rename, Remove and RemoveAll fail with
+// [errors.ErrUnsupported].
+func NewZipWriter(w io.Writer, options ...ArchiveOption) ArchiveWriter {
+	return newArchiveWriter(&zipFormat{w: zip.NewWriter(w)}, options)
+}
+
+func (z *zipFormat) writeEntry(e archiveEntry) error {
+	h := &zip.FileHeader{Name: e.name, Method: zip.Deflate, Modified: e.modTime}
+	if e.data == nil {
+		h.Name = archiveDirName(e.name)
+		h.Method = zip.Store
+		h.SetMode(fs.ModeDir | e.perm)
+	} else {
+		h.SetMode(e.perm)
+	}
+
+	w, err := z.w.CreateHeader(h)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(e.data)
+	return err
+}
+
+func (z *zipFormat) close() error {
+	return z.w.Close()
+}
+
+// NewZipFS returns read-only [FS] with the contents of the zip archive,
+// see [NewReadOnlyFS].
+func NewZipFS(r io.ReaderAt, size int64) (FS, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	return NewReadOnlyFS(zr), nil
+}
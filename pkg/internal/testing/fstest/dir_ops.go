@@ -6,9 +6,11 @@ import (
 	iofs "io/fs"
 	"path"
 	"path/filepath"
+	"strings"
 
 	"github.com/stretchr/testify/require"
 
+	"go.mws.cloud/util-toolset/pkg/internal/diff"
 	"go.mws.cloud/util-toolset/pkg/internal/os/fs"
 	"go.mws.cloud/util-toolset/pkg/utils/consterr"
 )
@@ -130,16 +132,33 @@ func CompareFiles(t TestingT, expectedFs, actualFs fs.FS, expPath, actualPath st
 	actualContent, err := fs.ReadFile(actualFs, actualPath)
 	require.NoError(t, err, "compare files error, actualFs ('%s'): %s", actualPath, err)
 
-	require.Equal(
-		t,
-		string(expContent),
-		string(actualContent),
-		"compare files error, files paths: expected: '%s', actual: '%s', contents: expected: %s; actual: %s",
-		expPath,
-		actualPath,
-		expContent,
-		actualContent,
-	)
+	if d := diff.Unified(expPath, actualPath, expContent, actualContent); d != "" {
+		require.Fail(t, "compare files error, files differ:\n"+d)
+	}
+}
+
+// CompareTrees compares all the files and directories of two file systems
+// and reports every difference at once as a git-style patch. Modes of the
+// entries are not compared.
+func CompareTrees(t TestingT, expectedFs, actualFs fs.ReadOnlyFS) {
+	t.Helper()
+
+	changes, err := fs.DiffTree(expectedFs, actualFs)
+	require.NoError(t, err, "compare trees error: %s", err)
+
+	var failed []string
+	for _, c := range changes {
+		if c.Kind != fs.TreeModeChanged {
+			failed = append(failed, c.String())
+		}
+	}
+	if len(failed) == 0 {
+		return
+	}
+
+	var patch strings.Builder
+	require.NoError(t, fs.WriteTreeDiff(&patch, expectedFs, actualFs, fs.WithTreeDiffNoModes()))
+	require.Fail(t, "compare trees error, trees differ:\n"+strings.Join(failed, "\n")+"\n"+patch.String())
 }
 
 func findUniqueNames(expEntries, actualEntries []iofs.DirEntry) (uniqueExp, uniqueActual []string) {
@@ -1,6 +1,7 @@
 package fstest
 
 import (
+	"fmt"
 	iofs "io/fs"
 	"path"
 	"path/filepath"
@@ -22,10 +23,13 @@ const (
 )
 
 type stubT struct {
-	failed bool
+	failed  bool
+	message string
 }
 
-func (*stubT) Errorf(string, ...any) {}
+func (s *stubT) Errorf(format string, args ...any) {
+	s.message += fmt.Sprintf(format, args...)
+}
 
 func (s *stubT) FailNow() {
 	s.failed = true
@@ -179,6 +183,53 @@ func TestCompareDirsError(t *testing.T) {
 	}
 }
 
+func TestCompareTrees(t *testing.T) {
+	for _, baseDir := range []string{"one_file", "complex"} {
+		t.Run(baseDir, func(t *testing.T) {
+			gFS := fs.NewRecommendedReal(fs.WithBaseDir(path.Join(testDir, compareDirs, goldenDir, baseDir)))
+			actualFS := fs.NewRecommendedReal(fs.WithBaseDir(path.Join(testDir, compareDirs, inputDir, baseDir)))
+
+			CompareTrees(t, gFS, actualFS)
+		})
+	}
+}
+
+func TestCompareTreesError(t *testing.T) {
+	for _, baseDir := range []string{
+		"error_files_names_differ",
+		"error_files_contents_differ",
+		"error_expected_has_unique",
+		"error_actual_has_unique",
+		"error_both_have_unique",
+	} {
+		t.Run(baseDir, func(t *testing.T) {
+			expFS := fs.NewRecommendedReal(fs.WithBaseDir(path.Join(testDir, compareDirs, expectedDir, baseDir)))
+			sutFS := fs.NewRecommendedReal(fs.WithBaseDir(path.Join(testDir, compareDirs, inputDir, baseDir)))
+
+			stub := stubT{}
+			CompareTrees(&stub, expFS, sutFS)
+
+			require.True(t, stub.failed, "error was expected")
+		})
+	}
+}
+
+func TestCompareTreesModes(t *testing.T) {
+	expFS, sutFS := fs.NewMapFS(), fs.NewMapFS()
+	require.NoError(t, expFS.WriteFile("a.txt", []byte("old\n"), 0o644))
+	require.NoError(t, expFS.WriteFile("b.sh", []byte("echo\n"), 0o644))
+	require.NoError(t, sutFS.WriteFile("a.txt", []byte("new\n"), 0o600))
+	require.NoError(t, sutFS.WriteFile("b.sh", []byte("echo\n"), 0o755))
+
+	stub := stubT{}
+	CompareTrees(&stub, expFS, sutFS)
+
+	require.True(t, stub.failed, "error was expected")
+	require.Contains(t, stub.message, "-old")
+	require.NotContains(t, stub.message, "b.sh")
+	require.NotContains(t, stub.message, "old mode")
+}
+
 func validateDir(t *testing.T, goldenDir *golden.Dir, toFS, fromFS fs.FS) {
 	t.Helper()
 
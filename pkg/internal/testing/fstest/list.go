@@ -9,26 +9,21 @@ import (
 	"go.mws.cloud/util-toolset/pkg/internal/os/fs"
 )
 
-// List returns all the files in the FS.
-// Usable only on FS that implements ListFS.
-func List(t testing.T, f fs.FS) []iofs.FileInfo {
+// List returns all the files in the FS, see [fs.List].
+func List(t testing.T, f fs.ReadOnlyFS, options ...fs.ListOption) []iofs.FileInfo {
 	t.Helper()
 
-	listFS, ok := f.(fs.ListFS)
-	require.True(t, ok)
-
-	list, err := listFS.List()
+	list, err := fs.List(f, options...)
 	require.NoError(t, err)
 
 	return list
 }
 
-// Names returns full names of all the files in the FS.
-// Usable only on FS that implements ListFS.
-func Names(t testing.T, f fs.FS) []string {
+// Names returns full names of all the files in the FS, see [fs.List].
+func Names(t testing.T, f fs.ReadOnlyFS, options ...fs.ListOption) []string {
 	t.Helper()
 
-	files := List(t, f)
+	files := List(t, f, options...)
 	names := make([]string, len(files))
 	for i, file := range files {
 		names[i] = file.Name()
@@ -36,3 +31,15 @@ func Names(t testing.T, f fs.FS) []string {
 
 	return names
 }
+
+// LogTreeOnFailure logs the listing of the FS written by [fs.WriteTree]
+// at the end of the test, if the test has failed.
+func LogTreeOnFailure(t testing.T, f fs.ReadOnlyFS) {
+	t.Helper()
+
+	t.Cleanup(func() {
+		if t.Failed() {
+			t.Logf("file system at the end of the test:\n%s", fs.Tree(f))
+		}
+	})
+}
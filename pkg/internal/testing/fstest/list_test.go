@@ -27,3 +27,14 @@ func TestList(t *testing.T) {
 	names := fstest.Names(t, f)
 	require.Equal(t, []string{"/baz/qux/v.txt", "/y.txt", "empty", "foo/bar/z.txt", "x.txt"}, names)
 }
+
+func TestListRealFS(t *testing.T) {
+	dir := t.TempDir()
+	f := fs.NewFS(fs.NewRealFS(), fs.WithBaseDir(dir), fs.WithDirCreate(os.ModePerm))
+	require.NoError(t, f.WriteFile("b/c.txt", nil, 0o644))
+	require.NoError(t, f.WriteFile("a.txt", nil, 0o644))
+	require.NoError(t, f.MkdirAll("empty", os.ModePerm))
+
+	require.Equal(t, []string{"a.txt", "b/c.txt"}, fstest.Names(t, f))
+	require.Equal(t, []string{"a.txt", "b", "b/c.txt", "empty"}, fstest.Names(t, f, fs.WithListDirs()))
+}
@@ -0,0 +1,20 @@
+package fstest
+
+import (
+	"testing"
+
+	"go.mws.cloud/util-toolset/pkg/internal/os/fs"
+)
+
+// RunFS runs the test as the subtests "map" and "real", with [fs.NewMapFS]
+// and with [TempFS], both wrapped with the options.
+func RunFS(t *testing.T, test func(t *testing.T, f fs.FS), options ...fs.Option) {
+	t.Helper()
+
+	t.Run("map", func(t *testing.T) {
+		test(t, fs.NewFS(fs.NewMapFS(), options...))
+	})
+	t.Run("real", func(t *testing.T) {
+		test(t, TempFS(t, WithFSOptions(options...)))
+	})
+}
@@ -0,0 +1,20 @@
+package fstest
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go.mws.cloud/util-toolset/pkg/internal/os/fs"
+)
+
+func TestRunFS(t *testing.T) {
+	var names []string
+	RunFS(t, func(t *testing.T, f fs.FS) {
+		names = append(names, t.Name())
+		require.NoError(t, f.WriteFile("dir/a.txt", nil, 0o644))
+		require.Equal(t, []string{"dir/a.txt"}, Names(t, f))
+	}, fs.WithDirCreate(os.ModePerm))
+	require.Equal(t, []string{"TestRunFS/map", "TestRunFS/real"}, names)
+}
@@ -0,0 +1,61 @@
+package fstest
+
+import (
+	"regexp"
+
+	"github.com/mitchellh/go-testing-interface"
+	"github.com/stretchr/testify/require"
+
+	"go.mws.cloud/util-toolset/pkg/internal/os/fs"
+)
+
+// TempOption is an option for [TempFS].
+type TempOption func(*tempConfig)
+
+type tempConfig struct {
+	keepOnFailure bool
+	options       []fs.Option
+}
+
+// WithKeepOnFailure keeps the temporary directory if the test has failed,
+// its path is logged so that it can be inspected.
+func WithKeepOnFailure() TempOption {
+	return func(c *tempConfig) {
+		c.keepOnFailure = true
+	}
+}
+
+// WithFSOptions sets the options passed to [fs.NewTempFS].
+func WithFSOptions(options ...fs.Option) TempOption {
+	return func(c *tempConfig) {
+		c.options = append(c.options, options...)
+	}
+}
+
+// unsafePrefixChars matches characters of test names which should not
+// be used in names of temporary directories.
+var unsafePrefixChars = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+// TempFS returns [fs.TempFS] named after the test, which is removed when
+// the test and all its subtests complete.
+func TempFS(t testing.T, options ...TempOption) *fs.TempFS {
+	t.Helper()
+
+	var cfg tempConfig
+	for _, o := range options {
+		o(&cfg)
+	}
+
+	f, err := fs.NewTempFS(unsafePrefixChars.ReplaceAllString(t.Name(), "_")+"-", cfg.options...)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		if cfg.keepOnFailure && t.Failed() {
+			t.Logf("keeping temporary directory of the failed test: %s", f.Path())
+			return
+		}
+		require.NoError(t, f.Close())
+	})
+
+	return f
+}
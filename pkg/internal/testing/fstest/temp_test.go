@@ -0,0 +1,57 @@
+package fstest_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go.mws.cloud/util-toolset/pkg/internal/os/fs"
+	"go.mws.cloud/util-toolset/pkg/internal/testing/fstest"
+)
+
+// cleanupT collects cleanup functions and reports the test as failed,
+// if failed is set.
+type cleanupT struct {
+	*testing.T
+	failed   bool
+	cleanups []func()
+}
+
+func (c *cleanupT) Cleanup(f func()) {
+	c.cleanups = append(c.cleanups, f)
+}
+
+func (c *cleanupT) Failed() bool {
+	return c.failed
+}
+
+func (c *cleanupT) runCleanups() {
+	for i := len(c.cleanups) - 1; i >= 0; i-- {
+		c.cleanups[i]()
+	}
+}
+
+func TestTempFS(t *testing.T) {
+	ct := &cleanupT{T: t}
+	f := fstest.TempFS(ct, fstest.WithFSOptions(fs.WithDirCreate(0o755)))
+	require.NoError(t, f.WriteFile("dir/a.txt", nil, 0o644))
+	require.Equal(t, []string{"dir/a.txt"}, fstest.Names(t, f))
+
+	ct.runCleanups()
+	require.NoDirExists(t, f.Path())
+}
+
+func TestTempFSKeepOnFailure(t *testing.T) {
+	for _, failed := range []bool{false, true} {
+		ct := &cleanupT{T: t, failed: failed}
+		f := fstest.TempFS(ct, fstest.WithKeepOnFailure())
+
+		ct.runCleanups()
+		if failed {
+			require.DirExists(t, f.Path())
+			require.NoError(t, f.Close())
+		} else {
+			require.NoDirExists(t, f.Path())
+		}
+	}
+}
@@ -0,0 +1,18 @@
+package fstest
+
+import (
+	"github.com/stretchr/testify/require"
+
+	"go.mws.cloud/util-toolset/pkg/internal/os/fs"
+)
+
+// Txtar returns [fs.FS] containing the files of the txtar archive,
+// the result can be used with [CopyDir] and [CompareDirs].
+func Txtar(t TestingT, archive string) fs.FS {
+	t.Helper()
+
+	f, err := fs.NewTxtarFS([]byte(archive))
+	require.NoError(t, err, "parsing txtar archive: %s", err)
+
+	return f
+}
@@ -0,0 +1,24 @@
+package fstest
+
+import (
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go.mws.cloud/util-toolset/pkg/internal/os/fs"
+)
+
+func TestTxtar(t *testing.T) {
+	inputPath := path.Join(testDir, copyDir, inputDir, "complex")
+	fromFS := fs.NewRecommendedReal(fs.WithBaseDir(inputPath))
+
+	var archive strings.Builder
+	CopyDir(t, fromFS, fs.NewTxtarWriter(&archive))
+	require.True(t, strings.HasPrefix(archive.String(), "-- first.txt --\n"))
+
+	toFS := Txtar(t, archive.String())
+	require.Equal(t, []string{"first.txt", "first_dir/second.txt"}, Names(t, toFS))
+	CompareDirs(t, fromFS, toFS, ".", ".")
+}
@@ -2,7 +2,6 @@
 package golden
 
 import (
-	"bytes"
 	"encoding/json"
 	"os"
 	"path"
@@ -85,7 +84,7 @@ func (d *Dir) Bytes(t testing.T, fn string, actual []byte) {
 		return
 	}
 	expected := readFileFromFS(t, d.fs, fileName)
-	require.True(t, bytes.Equal(expected, actual), updateMessage)
+	requireEqual(t, fileName, expected, actual)
 }
 
 // String requires that actual is equal to file content or updates file content with actual when flag -update is used.
@@ -99,7 +98,7 @@ func (d *Dir) String(t testing.T, fn, actual string) {
 		return
 	}
 	expected := readFileFromFS(t, d.fs, fileName)
-	require.Equal(t, string(expected), actual, updateMessage)
+	requireEqual(t, fileName, expected, []byte(actual))
 }
 
 // JSONBytes formats actual json and requires that result is equal to the contents of the file.
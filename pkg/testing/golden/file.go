@@ -7,6 +7,7 @@ import (
 	"github.com/mitchellh/go-testing-interface"
 	"github.com/stretchr/testify/require"
 
+	"go.mws.cloud/util-toolset/pkg/internal/diff"
 	"go.mws.cloud/util-toolset/pkg/internal/os/fs"
 )
 
@@ -25,6 +26,15 @@ func readFile(t testing.T, fileName string) []byte {
 	return readFileFromFS(t, fs.NewRealFS(), fileName)
 }
 
+// requireEqual fails the test with the unified diff of the golden and the
+// actual data if they differ.
+func requireEqual(t testing.T, fileName string, expected, actual []byte) {
+	t.Helper()
+	if !bytes.Equal(expected, actual) {
+		require.Fail(t, updateMessage+"\n"+diff.Unified(fileName, "actual", expected, actual))
+	}
+}
+
 // Bytes requires that actual is equal to file content or updates file content with actual when flag -update is used.
 //
 //	golden.Bytes(t, "expected.txt", myBytes)
@@ -35,7 +45,7 @@ func Bytes(t testing.T, fileName string, actual []byte) {
 		return
 	}
 	expected := readFile(t, fileName)
-	require.True(t, bytes.Equal(expected, actual), updateMessage)
+	requireEqual(t, fileName, expected, actual)
 }
 
 // String requires that actual is equal to file content or updates file content with actual when flag -update is used.
@@ -48,5 +58,5 @@ func String(t testing.T, fileName, actual string) {
 		return
 	}
 	expected := readFile(t, fileName)
-	require.Equal(t, string(expected), actual, updateMessage)
+	requireEqual(t, fileName, expected, []byte(actual))
 }
@@ -1,8 +1,13 @@
 package golden
 
 import (
+	"fmt"
 	"path"
+	"runtime"
 	"testing"
+
+	testinginterface "github.com/mitchellh/go-testing-interface"
+	"github.com/stretchr/testify/require"
 )
 
 func TestBytes(t *testing.T) {
@@ -30,3 +35,41 @@ func TestString(t *testing.T) {
 	bContents := string(readFile(t, b))
 	String(t, b, bContents)
 }
+
+// failureT records the failure of the test instead of failing it.
+type failureT struct {
+	testinginterface.T
+	message string
+}
+
+func (t *failureT) Helper() {}
+
+func (t *failureT) Name() string {
+	return "failureT"
+}
+
+func (t *failureT) Errorf(format string, args ...any) {
+	t.message = fmt.Sprintf(format, args...)
+}
+
+func (t *failureT) FailNow() {
+	runtime.Goexit()
+}
+
+func TestStringMismatch(t *testing.T) {
+	a := path.Join("testdata/file", "a.txt")
+	expected := string(readFile(t, a))
+
+	ft := &failureT{}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		String(ft, a, expected+"extra\n")
+	}()
+	<-done
+
+	require.Contains(t, ft.message, updateMessage)
+	require.Contains(t, ft.message, "--- "+a)
+	require.Contains(t, ft.message, "+++ actual")
+	require.Contains(t, ft.message, "+extra")
+}
@@ -7,7 +7,7 @@ import (
 var updateFlag = flag.Bool("update", false, "update golden test files")
 
 const (
-	updateMessage = "Actual golden data differs from Expected one. Run with -update to see the diff"
+	updateMessage = "Actual golden data differs from Expected one. Run with -update to update the golden files"
 )
 
 // IsUpdate returns true if -update flag is set.